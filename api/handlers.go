@@ -3,32 +3,224 @@ Package api provides HTTP handlers and routing for interacting with the blockcha
 This package exposes endpoints that allow clients to subscribe to addresses, retrieve
 current block information, and fetch transactions related to subscribed addresses.
 
+Addresses are 20 bytes hex encoded, case insensitive unless mixed case, in which case they must match their
+EIP-55 checksum. They are normalized to lowercase in responses.
+
 Available Endpoints:
 
 - /currentBlock: Retrieves the latest block number parsed by the system.
                  Method: GET
                  Response: { "currentBlock": <block number> }
 
+- /sync-status: Reports how far the parser is behind the network while catching up on missed blocks.
+                Method: GET
+                Response: { "currentBlock": <block number>, "networkBlock": <block number>, "lag": <blocks>, "stuckBlocks": <blocks> }
+
+- /stuck-blocks: Lists the blocks that could not be fetched, which hold the current block back until they are retried.
+                 Method: GET
+                 Response: JSON array of failed blocks with their attempts, last error and next retry time.
+
+- /endpoints: Reports the health of the JSON-RPC endpoints, in the order requests are tried on them.
+              Method: GET
+              Response: JSON array of endpoints with their priority, head block, latency, error rate and quarantine state.
+
+- /metrics: Exposes the parser metrics in the Prometheus text format.
+            Method: GET
+
 - /subscribe: Subscribes an address for monitoring inbound or outbound transactions.
               Method: GET
               Query Parameters:
               - address: The Ethereum address to subscribe.
+              - fromBlock (optional): Backfill the transactions of the address starting from this block, or
+                "genesis" to start from the first block the address sent a transaction or held ether in, which
+                needs an archive node. Every block is fetched for the backfill, a start further back than the
+                maximum number of backfilled blocks is rejected.
+              - label (optional): A name given to the subscription.
+              - owner (optional): Who the subscription belongs to.
               Response: "Subscribed successfully" or "Address already subscribed"
 
+- /unsubscribe: Stops monitoring an address.
+                Method: POST or DELETE
+                Query Parameters:
+                - address: The Ethereum address to unsubscribe.
+                - purge (optional): "true" to discard the stored transactions of the address, they are retained otherwise.
+                Response: "Unsubscribed successfully" or "Address not subscribed"
+
+- /subscriptions: Lists the monitored addresses along with the subscription details.
+                  Method: GET
+                  Query Parameters:
+                  - owner (optional): Only list the subscriptions of this owner.
+                  Response: JSON array of subscriptions.
+
+- /backfills: Reports the progress of the backfill jobs of addresses subscribed with a starting block.
+              Method: GET
+              Query Parameters:
+              - address (optional): Only report the jobs of this address.
+              Response: JSON array of backfill jobs. A job starting from the first activity of an address is
+              "unsupported" when it could not be located, and "approximate" when it may have started later.
+
 - /transactions: Fetches transactions associated with a subscribed address.
                  Method: GET
                  Query Parameters:
                  - address: The Ethereum address to fetch transactions for.
-                 Response: JSON array of transactions.
+                 - status (optional, repeatable): Only return transactions with the given status,
+                   one of "pending", "pending-confirmation", "confirmed" or "finalized". Pending transactions
+                   are waiting in the mempool, they are only reported when the parser watches it.
+                 - view (optional): "full" to include the complete transaction as "details", with its type, chain id,
+                   nonce, gas, fees, raw input, access list and blob versioned hashes.
+                 - fromBlock, toBlock (optional): Only return the transactions of the blocks in this range.
+                 - fromTime, toTime (optional): Only return the transactions of the blocks produced in this time
+                   range, as RFC 3339 times such as "2024-03-13T13:55:35Z".
+                 - direction (optional): "incoming", "outgoing" or "self" to only return the transactions received,
+                   sent to another address, or sent to itself by the address.
+                 - minValue (optional): Only return the transactions transferring at least this amount of wei,
+                   hex encoded or decimal.
+                 - order (optional): "asc" to list the oldest transactions first, the default, or "desc".
+                 - limit (optional): The maximum number of transactions returned, 100 by default and at most 1000.
+                 - cursor (optional): Resume after the previous page, with the cursor of its "X-Next-Cursor" header.
+                 Response: JSON array of transactions, ordered by block and by hash within a block, the pending ones
+                 last. The "timestamp" of a transaction is the time of its block. The "X-Next-Cursor" header is set
+                 when more transactions may follow, a page scans a bounded number of transactions, so a page of a
+                 selective filter may hold less transactions than the limit, or none. The "executionStatus" of a
+                 transaction is "success" or "reverted", a reverted transaction transferred no value although its "fee"
+                 was paid.
+
+- /pending-transactions: Fetches the transactions involving a subscribed address seen in the mempool.
+                         Method: GET
+                         Query Parameters:
+                         - address: The Ethereum address to fetch pending transactions for.
+                         Response: JSON array of transactions with the time they were first seen and their status,
+                         "pending" until mined, "replaced" by the transaction of the same sender and nonce given in
+                         "replacedBy", or "dropped" when they left the mempool without being mined.
+
+- /withdrawals: Fetches the beacon chain withdrawals credited to a subscribed address.
+                Method: GET
+                Query Parameters:
+                - address: The Ethereum address to fetch withdrawals for.
+                - status (optional, repeatable): Only return withdrawals with the given status, as for /transactions.
+                Response: JSON array of withdrawals with their index, validator index, address and amount in gwei.
+
+- /activity: Fetches the transactions and withdrawals of a subscribed address, ordered by block.
+             Method: GET
+             Query Parameters:
+             - address: The Ethereum address to fetch the activity of.
+             - status (optional, repeatable): Only return entries with the given status, as for /transactions.
+             Response: JSON array of entries with their type, "transaction" or "withdrawal", block number, status
+             and either the transaction or the withdrawal.
+
+- /token-transfers: Fetches the ERC-20 token transfers sent or received by a subscribed address.
+                    Method: GET
+                    Query Parameters:
+                    - address: The Ethereum address to fetch token transfers for.
+                    - status (optional, repeatable): Only return transfers with the given status, as for /transactions.
+                    Response: JSON array of token transfers with their token contract, sender, recipient and raw amount.
+
+- /nft-transfers: Fetches the ERC-721 and ERC-1155 transfers sent or received by a subscribed address.
+                  Method: GET
+                  Query Parameters:
+                  - address: The Ethereum address to fetch NFT transfers for.
+                  - status (optional, repeatable): Only return transfers with the given status, as for /transactions.
+                  Response: JSON array of NFT transfers with their standard, token contract, sender, recipient,
+                  token id and amount.
+
+- /internal-transactions: Fetches the ether sent to or from a subscribed address by contract calls, which requires
+                          the parser to trace the blocks.
+                          Method: GET
+                          Query Parameters:
+                          - address: The Ethereum address to fetch internal transactions for.
+                          - status (optional, repeatable): Only return calls with the given status, as for /transactions.
+                          Response: JSON array of internal transactions with their transaction hash, call path,
+                          call type, sender, recipient and value.
+
+- /stream: Streams the transactions of subscribed addresses as Server-Sent Events, as soon as they are recorded.
+           Every transaction is sent as a "transaction" event holding its JSON encoding, with its sequence number as
+           the event id. A client reconnecting with the "Last-Event-ID" header resumes after the given event, the
+           stream starts with the transactions recorded from then on otherwise. The transactions backfilled for
+           an address subscribed with a starting block are not streamed, and are marked "backfilled" elsewhere.
+           Method: GET
+           Query Parameters:
+           - address: The Ethereum address to stream transactions for, repeatable or comma separated.
+           - lastEventId (optional): Resume after the given event, for clients unable to set the header.
+           Response: text/event-stream of transaction events, with a comment sent every 15 seconds on idle streams.
+
+- /register-webhook: Registers a webhook notified of the activity of a subscribed address. Every event is posted as
+                     JSON with its id, type, address, block number and data, along with the "X-Txparser-Event" and
+                     "X-Txparser-Delivery" headers and the "X-Txparser-Signature" header, "sha256=" followed by the
+                     hex encoded HMAC-SHA256 of the body keyed with the secret. A delivery not answered with a 2xx
+                     status is retried with an increasing delay, and moved to the dead letters once it ran out of
+                     attempts. The events of a block are only delivered once the block is confirmed, those of a block
+                     orphaned by a reorganization before then are dropped. No retraction is sent for the rare
+                     reorganizations deeper than the confirmations.
+                     Method: POST
+                     Body: A JSON object with the fields below, "events" being an array, or the same fields form
+                     encoded with "event" repeated. The secret is rejected when sent in the query, where it would
+                     end up in access logs.
+                     - address: The subscribed Ethereum address.
+                     - url: The http or https endpoint the events are posted to.
+                     - events (optional): Only deliver the events of the given types, among "transaction",
+                       "token-transfer", "internal-transaction" and "withdrawal". Every type is delivered by default.
+                     - secret (optional): The key the payloads are signed with, a random one is generated by default.
+                     Response: The webhook with its id and secret, the secret is not reported afterwards.
+
+- /unregister-webhook: Removes a webhook, the webhooks of an address are also removed when it is unsubscribed.
+                       Method: POST or DELETE
+                       Query Parameters:
+                       - id: The id of the webhook.
+                       Response: "Webhook removed" or "Webhook not found"
+
+- /webhooks: Lists the registered webhooks, without their secrets.
+             Method: GET
+             Query Parameters:
+             - address (optional): Only list the webhooks of this address.
+             Response: JSON array of webhooks.
+
+- /webhook-deliveries: Reports the latest delivery attempts of a webhook, the most recent first.
+                       Method: GET
+                       Query Parameters:
+                       - id: The id of the webhook.
+                       Response: JSON array of attempts with their delivery, event, status code, error and state.
+
+- /dead-letters: Lists the deliveries to a webhook that failed every attempt.
+                 Method: GET
+                 Query Parameters:
+                 - id: The id of the webhook.
+                 Response: JSON array of deliveries with their event, attempts and last error.
+
+- /replay-dead-letters: Delivers the dead letters of a webhook again, with a fresh set of attempts.
+                        Method: POST
+                        Query Parameters:
+                        - id: The id of the webhook.
+                        - delivery (optional): Only replay the dead letter with this delivery id.
+                        Response: { "replayed": <deliveries> }
 */
 
 package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mo-mohamed/txparser/parser"
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+const (
+	// defaultPageLimit is the number of transactions returned by /transactions when no limit is given.
+	defaultPageLimit = 100
+	// maxPageLimit caps the number of transactions returned by /transactions.
+	maxPageLimit = 1000
+	// streamKeepAlive is the delay between two comments keeping an idle /stream open.
+	streamKeepAlive = 15 * time.Second
+	// maxWebhookRequestSize caps the size of the JSON body of a /register-webhook request.
+	maxWebhookRequestSize = 64 << 10
 )
 
 // CurrentBlockHandler handles the /currentBlock endpoint.
@@ -43,6 +235,67 @@ func CurrentBlockHandler(p parser.Parser) http.HandlerFunc {
 	}
 }
 
+// SyncStatusHandler handles the /sync-status endpoint.
+func SyncStatusHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		json.NewEncoder(w).Encode(p.GetSyncStatus())
+	}
+}
+
+// StuckBlocksHandler handles the /stuck-blocks endpoint.
+func StuckBlocksHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		json.NewEncoder(w).Encode(p.GetStuckBlocks())
+	}
+}
+
+// EndpointsHandler handles the /endpoints endpoint.
+func EndpointsHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		json.NewEncoder(w).Encode(p.GetEndpointHealth())
+	}
+}
+
+// MetricsHandler handles the /metrics endpoint.
+func MetricsHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		status := p.GetSyncStatus()
+		healthyEndpoints := 0
+		for _, endpoint := range p.GetEndpointHealth() {
+			if endpoint.Healthy {
+				healthyEndpoints++
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeGauge(w, "txparser_current_block", "Most recently parsed block.", status.CurrentBlock)
+		writeGauge(w, "txparser_network_block", "Latest block seen on the network.", status.NetworkBlock)
+		writeGauge(w, "txparser_block_lag", "Number of blocks the parser is behind the network.", status.Lag)
+		writeGauge(w, "txparser_stuck_blocks", "Number of blocks that could not be fetched and wait to be retried.", status.StuckBlocks)
+		writeGauge(w, "txparser_healthy_endpoints", "Number of JSON-RPC endpoints neither failing nor quarantined.", healthyEndpoints)
+	}
+}
+
+// writeGauge writes a gauge metric in the Prometheus text format.
+func writeGauge(w http.ResponseWriter, name string, help string, value int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+}
+
 // SubscribeHandler handles the /subscribe endpoint.
 func SubscribeHandler(p parser.Parser) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -50,12 +303,39 @@ func SubscribeHandler(p parser.Parser) http.HandlerFunc {
 			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		address := r.URL.Query().Get("address")
-		if address == "" {
-			http.Error(w, "Address is required", http.StatusBadRequest)
+		address, ok := addressParam(w, r)
+		if !ok {
 			return
 		}
-		if p.Subscribe(address) {
+		options := parser.SubscribeOptions{
+			Label: r.URL.Query().Get("label"),
+			Owner: r.URL.Query().Get("owner"),
+		}
+		if fromBlock := r.URL.Query().Get("fromBlock"); fromBlock == "genesis" {
+			options.FromGenesis = true
+		} else if fromBlock != "" {
+			block, err := strconv.Atoi(fromBlock)
+			if err != nil || block < 1 {
+				http.Error(w, "Invalid fromBlock", http.StatusBadRequest)
+				return
+			}
+			options.FromBlock = block
+		}
+		subscribed, err := p.SubscribeWithOptions(address, options)
+		if errors.Is(err, parser.ErrBackfillUnsupported) {
+			http.Error(w, "Backfilling from genesis is not supported", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, parser.ErrBackfillTooLong) {
+			http.Error(w, "The backfill starts too far back", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Println("Error subscribing address:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if subscribed {
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte("Subscribed successfully"))
 		} else {
@@ -65,6 +345,63 @@ func SubscribeHandler(p parser.Parser) http.HandlerFunc {
 	}
 }
 
+// UnsubscribeHandler handles the /unsubscribe endpoint.
+func UnsubscribeHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		address, ok := addressParam(w, r)
+		if !ok {
+			return
+		}
+		purge := r.URL.Query().Get("purge") == "true"
+		unsubscribed, err := p.Unsubscribe(address, purge)
+		if err != nil {
+			log.Println("Error unsubscribing address:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if unsubscribed {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Unsubscribed successfully"))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("Address not subscribed"))
+		}
+	}
+}
+
+// SubscriptionsHandler handles the /subscriptions endpoint.
+func SubscriptionsHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		json.NewEncoder(w).Encode(p.GetSubscriptions(r.URL.Query().Get("owner")))
+	}
+}
+
+// BackfillsHandler handles the /backfills endpoint.
+func BackfillsHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var address store.Address
+		if r.URL.Query().Get("address") != "" {
+			var ok bool
+			if address, ok = addressParam(w, r); !ok {
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(p.GetBackfillJobs(address))
+	}
+}
+
 // TransactionsHandler handles the /transactions endpoint.
 func TransactionsHandler(p parser.Parser) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -72,21 +409,519 @@ func TransactionsHandler(p parser.Parser) http.HandlerFunc {
 			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		address := r.URL.Query().Get("address")
-		if address == "" {
+		address, ok := addressParam(w, r)
+		if !ok {
+			return
+		}
+		statuses, ok := statusParams(w, r)
+		if !ok {
+			return
+		}
+		full := false
+		switch r.URL.Query().Get("view") {
+		case "", "slim":
+		case "full":
+			full = true
+		default:
+			http.Error(w, "Invalid view", http.StatusBadRequest)
+			return
+		}
+		query, ok := transactionQueryParams(w, r)
+		if !ok {
+			return
+		}
+		query.Address = address
+		page, err := p.QueryTransactions(query, statuses...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !full {
+			for i := range page.Transactions {
+				page.Transactions[i].Details = nil
+			}
+		}
+		if page.NextCursor != "" {
+			w.Header().Set("X-Next-Cursor", page.NextCursor)
+		}
+		json.NewEncoder(w).Encode(page.Transactions)
+	}
+}
+
+// PendingTransactionsHandler handles the /pending-transactions endpoint.
+func PendingTransactionsHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		address, ok := addressParam(w, r)
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(p.GetPendingTransactions(address))
+	}
+}
+
+// WithdrawalsHandler handles the /withdrawals endpoint.
+func WithdrawalsHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		address, ok := addressParam(w, r)
+		if !ok {
+			return
+		}
+		statuses, ok := statusParams(w, r)
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(p.GetWithdrawals(address, statuses...))
+	}
+}
+
+// ActivityHandler handles the /activity endpoint.
+func ActivityHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		address, ok := addressParam(w, r)
+		if !ok {
+			return
+		}
+		statuses, ok := statusParams(w, r)
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(p.GetActivity(address, statuses...))
+	}
+}
+
+// TokenTransfersHandler handles the /token-transfers endpoint.
+func TokenTransfersHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		address, ok := addressParam(w, r)
+		if !ok {
+			return
+		}
+		statuses, ok := statusParams(w, r)
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(p.GetTokenTransfers(address, statuses...))
+	}
+}
+
+// NFTTransfersHandler handles the /nft-transfers endpoint.
+func NFTTransfersHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		address, ok := addressParam(w, r)
+		if !ok {
+			return
+		}
+		statuses, ok := statusParams(w, r)
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(p.GetNFTTransfers(address, statuses...))
+	}
+}
+
+// InternalTransactionsHandler handles the /internal-transactions endpoint.
+func InternalTransactionsHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		address, ok := addressParam(w, r)
+		if !ok {
+			return
+		}
+		statuses, ok := statusParams(w, r)
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(p.GetInternalTransactions(address, statuses...))
+	}
+}
+
+// StreamHandler handles the /stream endpoint.
+func StreamHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		subscribed := make(map[store.Address]bool)
+		for _, subscription := range p.GetSubscriptions("") {
+			subscribed[subscription.Address] = true
+		}
+		var addresses []store.Address
+		for _, param := range r.URL.Query()["address"] {
+			for _, value := range strings.Split(param, ",") {
+				address, err := store.ParseAddress(value)
+				if err != nil {
+					http.Error(w, "Invalid address: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+				if !subscribed[address] {
+					http.Error(w, "Address not subscribed: "+string(address), http.StatusNotFound)
+					return
+				}
+				addresses = append(addresses, address)
+			}
+		}
+		if len(addresses) == 0 {
+			http.Error(w, "Address is required", http.StatusBadRequest)
+			return
+		}
+		sequence := p.GetLatestSequence()
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("lastEventId")
+		}
+		if lastEventID != "" {
+			var err error
+			if sequence, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+				http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		stream := p.StreamTransactions(r.Context(), addresses, sequence)
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case tx, ok := <-stream:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(tx)
+				if err != nil {
+					log.Println(err.Error())
+					continue
+				}
+				fmt.Fprintf(w, "id: %d\nevent: transaction\ndata: %s\n\n", tx.Sequence, data)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// RegisterWebhookHandler handles the /register-webhook endpoint.
+func RegisterWebhookHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Query().Has("secret") {
+			http.Error(w, "The secret must be sent in the request body", http.StatusBadRequest)
+			return
+		}
+		request, err := webhookRequestBody(w, r)
+		if err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Address == "" {
 			http.Error(w, "Address is required", http.StatusBadRequest)
 			return
 		}
-		transactions := p.GetTransactions(address)
-		json.NewEncoder(w).Encode(transactions)
+		address, err := store.ParseAddress(request.Address)
+		if err != nil {
+			http.Error(w, "Invalid address: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		options := parser.WebhookOptions{URL: request.URL, Secret: request.Secret}
+		if endpoint, err := url.ParseRequestURI(options.URL); err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			http.Error(w, "Invalid url", http.StatusBadRequest)
+			return
+		}
+		for _, event := range request.Events {
+			switch e := store.EventType(event); e {
+			case store.EventTransaction, store.EventTokenTransfer, store.EventInternalTransaction, store.EventWithdrawal:
+				options.Events = append(options.Events, e)
+			default:
+				http.Error(w, "Invalid event: "+event, http.StatusBadRequest)
+				return
+			}
+		}
+		webhook, registered, err := p.RegisterWebhook(address, options)
+		if err != nil {
+			log.Println("Error registering webhook:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !registered {
+			http.Error(w, "Address not subscribed", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(webhook)
+	}
+}
+
+// webhookRequest is the body of a /register-webhook request.
+type webhookRequest struct {
+	Address string   `json:"address"`
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`
+	Secret  string   `json:"secret"`
+}
+
+// webhookRequestBody reads the webhook to register from a JSON or a form encoded request body.
+func webhookRequestBody(w http.ResponseWriter, r *http.Request) (webhookRequest, error) {
+	var request webhookRequest
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookRequestSize)).Decode(&request)
+		return request, err
+	}
+	if err := r.ParseForm(); err != nil {
+		return request, err
+	}
+	request.Address = r.PostForm.Get("address")
+	request.URL = r.PostForm.Get("url")
+	request.Events = r.PostForm["event"]
+	request.Secret = r.PostForm.Get("secret")
+	return request, nil
+}
+
+// UnregisterWebhookHandler handles the /unregister-webhook endpoint.
+func UnregisterWebhookHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, ok := webhookParam(w, r)
+		if !ok {
+			return
+		}
+		removed, err := p.UnregisterWebhook(id)
+		if err != nil {
+			log.Println("Error removing webhook:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if removed {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Webhook removed"))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("Webhook not found"))
+		}
+	}
+}
+
+// WebhooksHandler handles the /webhooks endpoint.
+func WebhooksHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var address store.Address
+		if r.URL.Query().Get("address") != "" {
+			var ok bool
+			if address, ok = addressParam(w, r); !ok {
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(p.GetWebhooks(address))
+	}
+}
+
+// WebhookDeliveriesHandler handles the /webhook-deliveries endpoint.
+func WebhookDeliveriesHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, ok := webhookParam(w, r)
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(p.GetWebhookDeliveries(id))
+	}
+}
+
+// DeadLettersHandler handles the /dead-letters endpoint.
+func DeadLettersHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, ok := webhookParam(w, r)
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(p.GetDeadLetters(id))
+	}
+}
+
+// ReplayDeadLettersHandler handles the /replay-dead-letters endpoint.
+func ReplayDeadLettersHandler(p parser.Parser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, ok := webhookParam(w, r)
+		if !ok {
+			return
+		}
+		replayed := p.ReplayDeadLetters(id, r.URL.Query().Get("delivery"))
+		json.NewEncoder(w).Encode(map[string]int{"replayed": replayed})
+	}
+}
+
+// webhookParam parses the webhook id query parameter, replying with an error when it is missing.
+func webhookParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Webhook id is required", http.StatusBadRequest)
+		return "", false
+	}
+	return id, true
+}
+
+// transactionQueryParams parses the pagination, filtering and sorting query parameters of the /transactions endpoint,
+// replying with an error when one of them is invalid.
+func transactionQueryParams(w http.ResponseWriter, r *http.Request) (store.TransactionQuery, bool) {
+	params := r.URL.Query()
+	query := store.TransactionQuery{Cursor: params.Get("cursor"), Limit: defaultPageLimit}
+	for name, bound := range map[string]*int{"fromBlock": &query.FromBlock, "toBlock": &query.ToBlock, "limit": &query.Limit} {
+		if param := params.Get(name); param != "" {
+			value, err := strconv.Atoi(param)
+			if err != nil || value < 1 {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return query, false
+			}
+			*bound = value
+		}
+	}
+	query.Limit = min(query.Limit, maxPageLimit)
+	for name, bound := range map[string]*time.Time{"fromTime": &query.FromTime, "toTime": &query.ToTime} {
+		if param := params.Get(name); param != "" {
+			value, err := time.Parse(time.RFC3339, param)
+			if err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return query, false
+			}
+			*bound = value
+		}
+	}
+
+	switch direction := store.Direction(params.Get("direction")); direction {
+	case "", store.DirectionIncoming, store.DirectionOutgoing, store.DirectionSelf:
+		query.Direction = direction
+	default:
+		http.Error(w, "Invalid direction", http.StatusBadRequest)
+		return query, false
+	}
+	if param := params.Get("minValue"); param != "" {
+		minValue, err := store.ParseQuantity(param)
+		if err != nil {
+			http.Error(w, "Invalid minValue", http.StatusBadRequest)
+			return query, false
+		}
+		query.MinValue = minValue
+	}
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		http.Error(w, "Invalid order", http.StatusBadRequest)
+		return query, false
+	}
+	return query, true
+}
+
+// statusParams parses the status query parameters, replying with an error when one of them is invalid.
+func statusParams(w http.ResponseWriter, r *http.Request) ([]store.TransactionStatus, bool) {
+	var statuses []store.TransactionStatus
+	for _, status := range r.URL.Query()["status"] {
+		switch s := store.TransactionStatus(status); s {
+		case store.StatusPending, store.StatusPendingConfirmation, store.StatusConfirmed, store.StatusFinalized:
+			statuses = append(statuses, s)
+		default:
+			http.Error(w, "Invalid status", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	return statuses, true
+}
+
+// addressParam parses the address query parameter, replying with an error when it is missing or invalid.
+func addressParam(w http.ResponseWriter, r *http.Request) (store.Address, bool) {
+	param := r.URL.Query().Get("address")
+	if param == "" {
+		http.Error(w, "Address is required", http.StatusBadRequest)
+		return "", false
+	}
+	address, err := store.ParseAddress(param)
+	if err != nil {
+		http.Error(w, "Invalid address: "+err.Error(), http.StatusBadRequest)
+		return "", false
 	}
+	return address, true
 }
 
 // Router sets up the HTTP routes and returns an http.Handler.
 func Router(p parser.Parser) http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/current-block", CurrentBlockHandler(p))
+	mux.HandleFunc("/sync-status", SyncStatusHandler(p))
+	mux.HandleFunc("/stuck-blocks", StuckBlocksHandler(p))
+	mux.HandleFunc("/endpoints", EndpointsHandler(p))
+	mux.HandleFunc("/metrics", MetricsHandler(p))
 	mux.HandleFunc("/subscribe", SubscribeHandler(p))
+	mux.HandleFunc("/unsubscribe", UnsubscribeHandler(p))
+	mux.HandleFunc("/subscriptions", SubscriptionsHandler(p))
+	mux.HandleFunc("/backfills", BackfillsHandler(p))
 	mux.HandleFunc("/transactions", TransactionsHandler(p))
+	mux.HandleFunc("/pending-transactions", PendingTransactionsHandler(p))
+	mux.HandleFunc("/withdrawals", WithdrawalsHandler(p))
+	mux.HandleFunc("/activity", ActivityHandler(p))
+	mux.HandleFunc("/token-transfers", TokenTransfersHandler(p))
+	mux.HandleFunc("/nft-transfers", NFTTransfersHandler(p))
+	mux.HandleFunc("/internal-transactions", InternalTransactionsHandler(p))
+	mux.HandleFunc("/stream", StreamHandler(p))
+	mux.HandleFunc("/register-webhook", RegisterWebhookHandler(p))
+	mux.HandleFunc("/unregister-webhook", UnregisterWebhookHandler(p))
+	mux.HandleFunc("/webhooks", WebhooksHandler(p))
+	mux.HandleFunc("/webhook-deliveries", WebhookDeliveriesHandler(p))
+	mux.HandleFunc("/dead-letters", DeadLettersHandler(p))
+	mux.HandleFunc("/replay-dead-letters", ReplayDeadLettersHandler(p))
 	return mux
 }
@@ -1,12 +1,18 @@
 package api_test
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mo-mohamed/txparser/api"
+	"github.com/mo-mohamed/txparser/blockchain"
 	"github.com/mo-mohamed/txparser/mock"
 	"github.com/mo-mohamed/txparser/parser"
 	store "github.com/mo-mohamed/txparser/storage"
@@ -16,7 +22,7 @@ func TestCurrentBlockHandler(t *testing.T) {
 	store := store.NewMemoryStore()
 	store.SetCurrentBlock(100)
 	blockchain := &mock.BlockchainMock{
-		LatestNetworkBlockFunc: func() int { return 100 },
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
 	}
 	parser := parser.NewTxParser(store, blockchain)
 
@@ -43,11 +49,11 @@ func TestCurrentBlockHandler(t *testing.T) {
 func TestSubscribeHandler(t *testing.T) {
 	store := store.NewMemoryStore()
 	blockchain := &mock.BlockchainMock{
-		LatestNetworkBlockFunc: func() int { return 10 },
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 10, nil },
 	}
 	parser := parser.NewTxParser(store, blockchain)
 
-	req := httptest.NewRequest("GET", "/subscribe?address=0x123456789abcdef", nil)
+	req := httptest.NewRequest("GET", "/subscribe?address=0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", nil)
 	w := httptest.NewRecorder()
 
 	handler := api.SubscribeHandler(parser)
@@ -64,18 +70,18 @@ func TestSubscribeHandler(t *testing.T) {
 
 func TestTransactionsHandler(t *testing.T) {
 	mockedTrans := []store.Transaction{
-		{Hash: "0xabc", From: "0x123456789abcdef", To: "0x987654321", Value: "1000", BlockNumber: "100"},
+		{Hash: "0xabc", From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Value: "1000", BlockNumber: "100"},
 	}
 	storage := store.NewMemoryStore()
 	blockchain := &mock.BlockchainMock{
-		LatestNetworkBlockFunc: func() int { return 10 },
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 10, nil },
 	}
 	parser := parser.NewTxParser(storage, blockchain)
 
-	parser.Subscribe("0x123456789abcdef")
+	parser.Subscribe("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
 	storage.SaveTransactions(mockedTrans)
 
-	req := httptest.NewRequest("GET", "/transactions?address=0x123456789abcdef", nil)
+	req := httptest.NewRequest("GET", "/transactions?address=0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", nil)
 	w := httptest.NewRecorder()
 
 	handler := api.TransactionsHandler(parser)
@@ -95,3 +101,769 @@ func TestTransactionsHandler(t *testing.T) {
 		t.Errorf("Handler returned wrong transactions: got %v", transactions)
 	}
 }
+
+func TestTransactionsHandlerStatusFilter(t *testing.T) {
+	mockedTrans := []store.Transaction{
+		{Hash: "0xabc", From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Value: "1000", BlockNumber: "80"},
+		{Hash: "0xdef", From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Value: "1000", BlockNumber: "100"},
+	}
+	storage := store.NewMemoryStore()
+	blockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	parser := parser.NewTxParser(storage, blockchain)
+
+	parser.Subscribe("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	storage.SaveTransactions(mockedTrans)
+
+	req := httptest.NewRequest("GET", "/transactions?address=0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed&status=confirmed", nil)
+	w := httptest.NewRecorder()
+
+	handler := api.TransactionsHandler(parser)
+	handler.ServeHTTP(w, req)
+
+	var transactions []store.Transaction
+	err := json.NewDecoder(w.Body).Decode(&transactions)
+	if err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+
+	if len(transactions) != 1 || transactions[0].Hash != "0xabc" || transactions[0].Status != store.StatusConfirmed {
+		t.Errorf("Handler returned wrong transactions: got %v", transactions)
+	}
+
+	req = httptest.NewRequest("GET", "/transactions?address=0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed&status=unknown", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestSyncStatusHandler(t *testing.T) {
+	store := store.NewMemoryStore()
+	store.SetCurrentBlock(90)
+	blockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(store, blockchain)
+
+	req := httptest.NewRequest("GET", "/sync-status", nil)
+	w := httptest.NewRecorder()
+
+	handler := api.SyncStatusHandler(p)
+	handler.ServeHTTP(w, req)
+
+	var response parser.SyncStatus
+	err := json.NewDecoder(w.Body).Decode(&response)
+	if err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+
+	if response.CurrentBlock != 90 || response.NetworkBlock != 100 || response.Lag != 10 {
+		t.Errorf("Handler returned wrong sync status: got %+v", response)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	store := store.NewMemoryStore()
+	store.SetCurrentBlock(90)
+	blockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(store, blockchain)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+
+	handler := api.MetricsHandler(p)
+	handler.ServeHTTP(w, req)
+
+	if body := w.Body.String(); !strings.Contains(body, "txparser_block_lag 10\n") || !strings.Contains(body, "txparser_stuck_blocks 0\n") {
+		t.Errorf("Handler returned wrong metrics: got %v", body)
+	}
+}
+
+func TestSubscribeHandlerFromBlock(t *testing.T) {
+	storage := store.NewMemoryStore()
+	blockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 10, nil },
+	}
+	p := parser.NewTxParser(storage, blockchain)
+
+	req := httptest.NewRequest("GET", "/subscribe?address=0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed&fromBlock=5", nil)
+	w := httptest.NewRecorder()
+	api.SubscribeHandler(p).ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	req = httptest.NewRequest("GET", "/backfills?address=0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", nil)
+	w = httptest.NewRecorder()
+	api.BackfillsHandler(p).ServeHTTP(w, req)
+
+	var jobs []store.BackfillJob
+	if err := json.NewDecoder(w.Body).Decode(&jobs); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].FromBlock != 5 || jobs[0].ToBlock != 10 || jobs[0].Status != store.BackfillQueued {
+		t.Errorf("Handler returned wrong backfill jobs: got %+v", jobs)
+	}
+
+	req = httptest.NewRequest("GET", "/subscribe?address=0xdbf03b407c01e7cd3cbea99509d93f8dddc8c6fb&fromBlock=abc", nil)
+	w = httptest.NewRecorder()
+	api.SubscribeHandler(p).ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestSubscribeHandlerFromGenesisUnsupported(t *testing.T) {
+	storage := store.NewMemoryStore()
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 10, nil },
+	}
+	p := parser.NewTxParser(storage, struct{ blockchain.IBlockchain }{mockBlockchain})
+
+	req := httptest.NewRequest("GET", "/subscribe?address=0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed&fromBlock=genesis", nil)
+	w := httptest.NewRecorder()
+	api.SubscribeHandler(p).ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestSubscribeHandlerBackfillTooLong(t *testing.T) {
+	storage := store.NewMemoryStore()
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(storage, mockBlockchain, parser.WithMaxBackfillBlocks(20))
+
+	req := httptest.NewRequest("GET", "/subscribe?address=0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed&fromBlock=50", nil)
+	w := httptest.NewRecorder()
+	api.SubscribeHandler(p).ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestUnsubscribeHandler(t *testing.T) {
+	store := store.NewMemoryStore()
+	blockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 10, nil },
+	}
+	p := parser.NewTxParser(store, blockchain)
+	p.SubscribeWithOptions("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", parser.SubscribeOptions{Label: "deposits", Owner: "acme"})
+	p.Subscribe("0xdbf03b407c01e7cd3cbea99509d93f8dddc8c6fb")
+
+	req := httptest.NewRequest("GET", "/subscriptions?owner=acme", nil)
+	w := httptest.NewRecorder()
+	api.SubscriptionsHandler(p).ServeHTTP(w, req)
+
+	var subscriptions []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&subscriptions); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(subscriptions) != 1 || subscriptions[0]["label"] != "deposits" || subscriptions[0]["createdAtBlock"] != float64(10) {
+		t.Errorf("Handler returned wrong subscriptions: got %v", subscriptions)
+	}
+
+	req = httptest.NewRequest("GET", "/unsubscribe?address=0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed&purge=true", nil)
+	w = httptest.NewRecorder()
+	api.UnsubscribeHandler(p).ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected GET to be rejected, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("DELETE", "/unsubscribe?address=0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed&purge=true", nil)
+	w = httptest.NewRecorder()
+	api.UnsubscribeHandler(p).ServeHTTP(w, req)
+
+	if body := w.Body.String(); w.Code != http.StatusOK || body != "Unsubscribed successfully" {
+		t.Errorf("Handler returned wrong response: got %v %v", w.Code, body)
+	}
+
+	w = httptest.NewRecorder()
+	api.UnsubscribeHandler(p).ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusNotFound {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestSubscribeHandlerNormalizesAddress(t *testing.T) {
+	storage := store.NewMemoryStore()
+	blockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(storage, blockchain)
+
+	req := httptest.NewRequest("GET", "/subscribe?address=0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", nil)
+	w := httptest.NewRecorder()
+	api.SubscribeHandler(p).ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	storage.SaveTransactions([]store.Transaction{
+		{Hash: "0xabc", From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Value: "1000", BlockNumber: "80"},
+	})
+
+	req = httptest.NewRequest("GET", "/transactions?address=0X5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED", nil)
+	w = httptest.NewRecorder()
+	api.TransactionsHandler(p).ServeHTTP(w, req)
+
+	var transactions []store.Transaction
+	if err := json.NewDecoder(w.Body).Decode(&transactions); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(transactions) != 1 {
+		t.Errorf("Handler returned wrong transactions: got %v", transactions)
+	}
+
+	for _, address := range []string{"0x123456789abcdef", "0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"} {
+		req = httptest.NewRequest("GET", "/subscribe?address="+address, nil)
+		w = httptest.NewRecorder()
+		api.SubscribeHandler(p).ServeHTTP(w, req)
+
+		if status := w.Code; status != http.StatusBadRequest {
+			t.Errorf("Handler returned wrong status code for %s: got %v want %v", address, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestEndpointsHandler(t *testing.T) {
+	store := store.NewMemoryStore()
+	blockchainMock := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		EndpointHealthFunc: func() []blockchain.EndpointHealth {
+			return []blockchain.EndpointHealth{
+				{URL: "https://primary.example", Healthy: true, HeadBlock: 100},
+				{URL: "https://lagging.example", Priority: 1, Quarantined: true, HeadBlock: 90},
+			}
+		},
+	}
+	p := parser.NewTxParser(store, blockchainMock)
+
+	req := httptest.NewRequest("GET", "/endpoints", nil)
+	w := httptest.NewRecorder()
+	api.EndpointsHandler(p).ServeHTTP(w, req)
+
+	var response []blockchain.EndpointHealth
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(response) != 2 || response[0].URL != "https://primary.example" || !response[1].Quarantined {
+		t.Errorf("Handler returned wrong endpoints: got %+v", response)
+	}
+
+	req = httptest.NewRequest("GET", "/metrics", nil)
+	w = httptest.NewRecorder()
+	api.MetricsHandler(p).ServeHTTP(w, req)
+
+	if body := w.Body.String(); !strings.Contains(body, "txparser_healthy_endpoints 1\n") {
+		t.Errorf("Handler returned wrong metrics: got %v", body)
+	}
+}
+
+func TestTokenTransfersHandler(t *testing.T) {
+	storage := store.NewMemoryStore()
+	blockchainMock := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(storage, blockchainMock)
+	p.Subscribe("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	storage.SaveBlock(store.Block{
+		BlockHeader: store.BlockHeader{Number: 80},
+		TokenTransfers: []store.TokenTransfer{
+			{TransactionHash: "0xabc", BlockNumber: "80", Token: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+				From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Amount: "0xf4240"},
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/token-transfers?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359&status=confirmed", nil)
+	w := httptest.NewRecorder()
+	api.TokenTransfersHandler(p).ServeHTTP(w, req)
+
+	var transfers []store.TokenTransfer
+	if err := json.NewDecoder(w.Body).Decode(&transfers); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(transfers) != 1 || transfers[0].Amount != "0xf4240" || transfers[0].Status != store.StatusConfirmed {
+		t.Errorf("Handler returned wrong token transfers: got %+v", transfers)
+	}
+}
+
+func TestNFTTransfersHandler(t *testing.T) {
+	storage := store.NewMemoryStore()
+	blockchainMock := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(storage, blockchainMock)
+	p.Subscribe("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	storage.SaveBlock(store.Block{
+		BlockHeader: store.BlockHeader{Number: 80},
+		TokenTransfers: []store.TokenTransfer{
+			{TransactionHash: "0xabc", LogIndex: 1, BlockNumber: "80", Standard: store.StandardERC20, Token: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+				From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Amount: "0xf4240"},
+			{TransactionHash: "0xabc", LogIndex: 2, BlockNumber: "80", Standard: store.StandardERC721, Token: "0xb0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+				From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", TokenID: "0x2a", Amount: "0x1"},
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/nft-transfers?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", nil)
+	w := httptest.NewRecorder()
+	api.NFTTransfersHandler(p).ServeHTTP(w, req)
+
+	var transfers []store.TokenTransfer
+	if err := json.NewDecoder(w.Body).Decode(&transfers); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(transfers) != 1 || transfers[0].TokenID != "0x2a" || transfers[0].Standard != store.StandardERC721 {
+		t.Errorf("Handler returned wrong NFT transfers: got %+v", transfers)
+	}
+}
+
+func TestInternalTransactionsHandler(t *testing.T) {
+	storage := store.NewMemoryStore()
+	blockchainMock := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(storage, blockchainMock)
+	p.Subscribe("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	storage.SaveBlock(store.Block{
+		BlockHeader: store.BlockHeader{Number: 80},
+		InternalTransactions: []store.InternalTransaction{
+			{TransactionHash: "0xabc", TraceAddress: []int{0}, BlockNumber: "80", Type: "call",
+				From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Value: "0x2"},
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/internal-transactions?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", nil)
+	w := httptest.NewRecorder()
+	api.InternalTransactionsHandler(p).ServeHTTP(w, req)
+
+	var calls []store.InternalTransaction
+	if err := json.NewDecoder(w.Body).Decode(&calls); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(calls) != 1 || calls[0].Value != "0x2" || calls[0].Status != store.StatusConfirmed {
+		t.Errorf("Handler returned wrong internal transactions: got %+v", calls)
+	}
+}
+
+func TestPendingTransactionsHandler(t *testing.T) {
+	storage := store.NewMemoryStore()
+	blockchainMock := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		PendingTransactionsFunc: func(ctx context.Context) ([]store.Transaction, error) {
+			return []store.Transaction{{Hash: "0xabc", From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+				To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Value: "0x2", Nonce: "0x1"}}, nil
+		},
+	}
+	p := parser.NewTxParser(storage, blockchainMock, parser.WithMempool(blockchainMock, 10*time.Millisecond, 0))
+	p.Subscribe("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go p.StartPolling(ctx)
+	for len(p.GetPendingTransactions("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")) == 0 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	req := httptest.NewRequest("GET", "/pending-transactions?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", nil)
+	w := httptest.NewRecorder()
+	api.PendingTransactionsHandler(p).ServeHTTP(w, req)
+
+	var transactions []parser.PendingTransaction
+	if err := json.NewDecoder(w.Body).Decode(&transactions); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(transactions) != 1 || transactions[0].Hash != "0xabc" || transactions[0].Status != store.StatusPending {
+		t.Errorf("Handler returned wrong pending transactions: got %+v", transactions)
+	}
+}
+
+func TestTransactionsHandlerFullView(t *testing.T) {
+	storage := store.NewMemoryStore()
+	blockchainMock := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(storage, blockchainMock)
+	p.Subscribe("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	details := store.TransactionDetails{Hash: "0xabc", Type: *store.NewQuantity(2), BlockNumber: store.NewQuantity(80),
+		From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359",
+		Value: *store.NewQuantity(2), MaxFeePerGas: store.NewQuantity(100), Input: "0x"}
+	storage.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: 80}, Transactions: []store.Transaction{details.Slim()}})
+
+	for view, full := range map[string]bool{"": false, "full": true} {
+		req := httptest.NewRequest("GET", "/transactions?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359&view="+view, nil)
+		w := httptest.NewRecorder()
+		api.TransactionsHandler(p).ServeHTTP(w, req)
+
+		var transactions []store.Transaction
+		if err := json.NewDecoder(w.Body).Decode(&transactions); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if len(transactions) != 1 || transactions[0].Value != "0x2" || (transactions[0].Details != nil) != full {
+			t.Errorf("Handler returned wrong transactions for view %q: got %+v", view, transactions)
+		}
+		if full && transactions[0].Details.MaxFeePerGas.Int64() != 100 {
+			t.Errorf("Expected the complete transaction, got %+v", transactions[0].Details)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/transactions?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359&view=wide", nil)
+	w := httptest.NewRecorder()
+	api.TransactionsHandler(p).ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid view to be rejected, got %d", w.Code)
+	}
+}
+
+func TestActivityHandler(t *testing.T) {
+	storage := store.NewMemoryStore()
+	blockchainMock := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(storage, blockchainMock)
+	p.Subscribe("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	storage.SaveBlock(store.Block{
+		BlockHeader: store.BlockHeader{Number: 81},
+		Transactions: []store.Transaction{
+			{Hash: "0xabc", From: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", To: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", Value: "0x1", BlockNumber: "81"},
+		},
+		Withdrawals: []store.Withdrawal{
+			{Index: "0x2", ValidatorIndex: "0x7", Address: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Amount: "0x2", BlockNumber: "81"},
+		},
+	})
+	storage.SaveBlock(store.Block{
+		BlockHeader: store.BlockHeader{Number: 80},
+		Withdrawals: []store.Withdrawal{
+			{Index: "0x1", ValidatorIndex: "0x7", Address: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Amount: "0x2", BlockNumber: "80"},
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/activity?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", nil)
+	w := httptest.NewRecorder()
+	api.ActivityHandler(p).ServeHTTP(w, req)
+
+	var activity []parser.Activity
+	if err := json.NewDecoder(w.Body).Decode(&activity); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(activity) != 3 || activity[0].Withdrawal == nil || activity[0].Withdrawal.Index != "0x1" ||
+		activity[1].Type != parser.ActivityTransaction || activity[1].Transaction.Hash != "0xabc" ||
+		activity[2].Type != parser.ActivityWithdrawal || activity[2].Status != store.StatusConfirmed {
+		t.Errorf("Handler returned wrong activity: got %+v", activity)
+	}
+}
+
+func TestTransactionsHandlerPagination(t *testing.T) {
+	storage := store.NewMemoryStore()
+	blockchainMock := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(storage, blockchainMock)
+	p.Subscribe("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	for block := 80; block < 85; block++ {
+		storage.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: block}, Transactions: []store.Transaction{
+			{Hash: "0x" + strconv.Itoa(block), From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359",
+				Value: "0x1", BlockNumber: strconv.Itoa(block)},
+		}})
+	}
+
+	req := httptest.NewRequest("GET", "/transactions?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359&order=desc&limit=2&direction=incoming", nil)
+	w := httptest.NewRecorder()
+	api.TransactionsHandler(p).ServeHTTP(w, req)
+
+	var transactions []store.Transaction
+	if err := json.NewDecoder(w.Body).Decode(&transactions); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	cursor := w.Header().Get("X-Next-Cursor")
+	if len(transactions) != 2 || transactions[0].Hash != "0x84" || transactions[1].Hash != "0x83" || cursor == "" {
+		t.Fatalf("Handler returned wrong first page: got %+v, cursor %q", transactions, cursor)
+	}
+
+	req = httptest.NewRequest("GET", "/transactions?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359&order=desc&limit=2&direction=incoming&cursor="+cursor, nil)
+	w = httptest.NewRecorder()
+	api.TransactionsHandler(p).ServeHTTP(w, req)
+	transactions = nil
+	json.NewDecoder(w.Body).Decode(&transactions)
+	if len(transactions) != 2 || transactions[0].Hash != "0x82" || transactions[1].Hash != "0x81" {
+		t.Errorf("Handler returned wrong second page: got %+v", transactions)
+	}
+
+	for _, params := range []string{"limit=0", "fromBlock=x", "direction=sideways", "minValue=0xzz", "order=up", "cursor=%21"} {
+		req := httptest.NewRequest("GET", "/transactions?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359&"+params, nil)
+		w := httptest.NewRecorder()
+		api.TransactionsHandler(p).ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %d", params, w.Code)
+		}
+	}
+}
+
+func TestTransactionsHandlerPaginatedByDefault(t *testing.T) {
+	storage := store.NewMemoryStore()
+	blockchainMock := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 200, nil },
+	}
+	p := parser.NewTxParser(storage, blockchainMock)
+	p.Subscribe("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	for block := 1; block <= 150; block++ {
+		storage.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: block}, Transactions: []store.Transaction{
+			{Hash: "0x" + strconv.Itoa(block), From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359",
+				Value: "0x1", BlockNumber: strconv.Itoa(block)},
+		}})
+	}
+
+	req := httptest.NewRequest("GET", "/transactions?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", nil)
+	w := httptest.NewRecorder()
+	api.TransactionsHandler(p).ServeHTTP(w, req)
+
+	var transactions []store.Transaction
+	if err := json.NewDecoder(w.Body).Decode(&transactions); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	cursor := w.Header().Get("X-Next-Cursor")
+	if len(transactions) != 100 || cursor == "" {
+		t.Fatalf("Expected a page of 100 transactions without a limit, got %d, cursor %q", len(transactions), cursor)
+	}
+
+	req = httptest.NewRequest("GET", "/transactions?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359&cursor="+cursor, nil)
+	w = httptest.NewRecorder()
+	api.TransactionsHandler(p).ServeHTTP(w, req)
+	transactions = nil
+	json.NewDecoder(w.Body).Decode(&transactions)
+	if len(transactions) != 50 || transactions[0].Hash != "0x101" || w.Header().Get("X-Next-Cursor") != "" {
+		t.Errorf("Expected the last 50 transactions on the second page, got %d, cursor %q", len(transactions), w.Header().Get("X-Next-Cursor"))
+	}
+}
+
+func TestTransactionsHandlerTimeRange(t *testing.T) {
+	storage := store.NewMemoryStore()
+	blockchainMock := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(storage, blockchainMock)
+	p.Subscribe("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	epoch := time.Date(2024, 3, 13, 13, 0, 0, 0, time.UTC)
+	for block := 80; block < 85; block++ {
+		blockTime := epoch.Add(time.Duration(block-80) * time.Minute)
+		storage.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: block, Timestamp: blockTime.Unix()}, Transactions: []store.Transaction{
+			{Hash: "0x" + strconv.Itoa(block), From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359",
+				Value: "0x1", BlockNumber: strconv.Itoa(block), Timestamp: &blockTime},
+		}})
+	}
+
+	req := httptest.NewRequest("GET", "/transactions?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359&fromTime=2024-03-13T13:01:00Z&toTime=2024-03-13T15:02:30%2B02:00", nil)
+	w := httptest.NewRecorder()
+	api.TransactionsHandler(p).ServeHTTP(w, req)
+
+	var transactions []store.Transaction
+	if err := json.NewDecoder(w.Body).Decode(&transactions); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(transactions) != 2 || transactions[0].Hash != "0x81" || transactions[1].Hash != "0x82" {
+		t.Fatalf("Handler returned wrong transactions: got %+v", transactions)
+	}
+	if transactions[0].Timestamp == nil || !transactions[0].Timestamp.Equal(epoch.Add(time.Minute)) {
+		t.Errorf("Expected the time of the block, got %v", transactions[0].Timestamp)
+	}
+
+	for _, params := range []string{"fromTime=yesterday", "toTime=2024-03-13"} {
+		req := httptest.NewRequest("GET", "/transactions?address=0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359&"+params, nil)
+		w := httptest.NewRecorder()
+		api.TransactionsHandler(p).ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %d", params, w.Code)
+		}
+	}
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	const address = "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"
+	blockchainMock := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(store.NewMemoryStore(), blockchainMock)
+	p.Subscribe(address)
+
+	body := `{"address": "` + address + `", "url": "https://example.com/hook", "events": ["transaction", "withdrawal"]}`
+	req := httptest.NewRequest("POST", "/register-webhook", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	api.Router(p).ServeHTTP(w, req)
+	var webhook store.Webhook
+	if err := json.NewDecoder(w.Body).Decode(&webhook); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if webhook.ID == "" || webhook.Secret == "" || len(webhook.Events) != 2 || webhook.URL != "https://example.com/hook" {
+		t.Fatalf("Expected the registered webhook along with a generated secret, got %+v", webhook)
+	}
+
+	req = httptest.NewRequest("GET", "/webhooks?address="+address, nil)
+	w = httptest.NewRecorder()
+	api.Router(p).ServeHTTP(w, req)
+	var webhooks []store.Webhook
+	json.NewDecoder(w.Body).Decode(&webhooks)
+	if len(webhooks) != 1 || webhooks[0].ID != webhook.ID || webhooks[0].Secret != "" {
+		t.Errorf("Expected the webhook to be listed without its secret, got %+v", webhooks)
+	}
+
+	req = httptest.NewRequest("POST", "/replay-dead-letters?id="+webhook.ID, nil)
+	w = httptest.NewRecorder()
+	api.Router(p).ServeHTTP(w, req)
+	if expected := "{\"replayed\":0}\n"; w.Body.String() != expected {
+		t.Errorf("Expected no dead letters to replay, got %q", w.Body.String())
+	}
+
+	for form, status := range map[string]int{
+		"address=" + address + "&url=https://example.com&event=transaction&secret=s3cret": http.StatusOK,
+		"address=" + address + "&url=ftp://example.com":                                   http.StatusBadRequest,
+		"address=" + address + "&url=https://example.com&event=block":                     http.StatusBadRequest,
+		"address=0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed&url=https://example.com":      http.StatusNotFound,
+		"url=https://example.com": http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest("POST", "/register-webhook", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		api.Router(p).ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("Expected %s to reply %d, got %d", form, status, w.Code)
+		}
+	}
+	for _, req := range []*http.Request{
+		httptest.NewRequest("POST", "/register-webhook?secret=s3cret", strings.NewReader(body)),
+		httptest.NewRequest("GET", "/dead-letters", nil),
+	} {
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		api.Router(p).ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %d", req.URL, w.Code)
+		}
+	}
+	for _, path := range []string{"/register-webhook?address=" + address + "&url=https://example.com",
+		"/replay-dead-letters?id=" + webhook.ID, "/unregister-webhook?id=" + webhook.ID} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		api.Router(p).ServeHTTP(w, req)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("Expected GET %s to be rejected, got %d", path, w.Code)
+		}
+	}
+	// Only the webhook registered from the JSON body is kept.
+	for _, webhook := range p.GetWebhooks(address) {
+		if webhook.URL == "https://example.com" {
+			p.UnregisterWebhook(webhook.ID)
+		}
+	}
+
+	req = httptest.NewRequest("DELETE", "/unregister-webhook?id="+webhook.ID, nil)
+	w = httptest.NewRecorder()
+	api.Router(p).ServeHTTP(w, req)
+	if w.Code != http.StatusOK || len(p.GetWebhooks("")) != 0 {
+		t.Errorf("Expected the webhook to be removed, got %d", w.Code)
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	const address = "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"
+	storage := store.NewMemoryStore()
+	blockchainMock := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(storage, blockchainMock)
+	p.Subscribe(address)
+	p.Subscribe("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	saveTransaction := func(hash string, block int) {
+		storage.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: block}, Transactions: []store.Transaction{
+			{Hash: hash, From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", To: address, Value: "0x1", BlockNumber: strconv.Itoa(block)},
+		}})
+	}
+	saveTransaction("0xa", 80)
+	server := httptest.NewServer(api.Router(p))
+	defer server.Close()
+
+	// connect opens a stream of the address, resuming after the given event id when set.
+	connect := func(lastEventID string) (*bufio.Reader, func()) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/stream?address="+address, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Could not open stream: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("Expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		return bufio.NewReader(resp.Body), func() { cancel(); resp.Body.Close() }
+	}
+	// readEvent reads the next event of a stream, and returns its id along with the hash of its transaction.
+	readEvent := func(events *bufio.Reader) (string, string) {
+		var id string
+		var tx store.Transaction
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Could not read event: %v", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				return id, tx.Hash
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &tx)
+			}
+		}
+	}
+
+	// A new stream only carries the transactions recorded after it was opened.
+	events, stop := connect("")
+	saveTransaction("0xb", 81)
+	if id, hash := readEvent(events); id != "2" || hash != "0xb" {
+		t.Errorf("Expected the transaction recorded after the stream was opened, got %s %s", id, hash)
+	}
+	stop()
+
+	// A reconnecting client resumes after the last event it received.
+	events, stop = connect("1")
+	defer stop()
+	if id, hash := readEvent(events); id != "2" || hash != "0xb" {
+		t.Errorf("Expected the stream to resume after event 1, got %s %s", id, hash)
+	}
+
+	for params, status := range map[string]int{
+		"/stream":                               http.StatusBadRequest,
+		"/stream?address=" + address + ",0x123": http.StatusBadRequest,
+		"/stream?address=0x0000000000000000000000000000000000000001": http.StatusNotFound,
+	} {
+		req := httptest.NewRequest("GET", params, nil)
+		w := httptest.NewRecorder()
+		api.Router(p).ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("Expected %s to reply %d, got %d", params, status, w.Code)
+		}
+	}
+	req := httptest.NewRequest("GET", "/stream?address="+address, nil)
+	req.Header.Set("Last-Event-ID", "last")
+	w := httptest.NewRecorder()
+	api.Router(p).ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid Last-Event-ID to be rejected, got %d", w.Code)
+	}
+}
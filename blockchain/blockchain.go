@@ -5,82 +5,618 @@ package blockchain
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math/rand"
 	"net/http"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	store "github.com/mo-mohamed/txparser/storage"
 )
 
+const (
+	// requestTimeout bounds the duration of a JSON-RPC request.
+	requestTimeout = 30 * time.Second
+	// defaultMaxBatchSize is the number of calls sent in a single JSON-RPC batch request.
+	defaultMaxBatchSize = 25
+	// maxErrorBodySize is the number of bytes of a failed response body kept in the error.
+	maxErrorBodySize = 512
+	// defaultHealthCheckInterval is the delay between two checks of the head of every endpoint.
+	defaultHealthCheckInterval = 30 * time.Second
+)
+
 type Blockchain struct {
-	//jsonRPCEndpoint is the endpoint for blockchain network
-	jsonRPCEndpoint string
+	// endpoints are the JSON-RPC endpoints of the blockchain network, a request fails over to the next endpoint
+	// when an endpoint fails
+	endpoints []*endpoint
+	// client is the HTTP client issuing the JSON-RPC requests
+	client *http.Client
+	// maxBatchSize is the number of calls sent in a single batch request, larger batches are split
+	maxBatchSize int
+	// maxHeadLag is the number of blocks an endpoint can lag behind the other endpoints before it is quarantined
+	maxHeadLag int
+	// healthCheckInterval is the delay between two checks of the head of every endpoint
+	healthCheckInterval time.Duration
+	// internalTransactions enables tracing the blocks to extract the internal transactions
+	internalTransactions bool
+	// debugTraceUnsupported is set once an endpoint rejected "debug_traceBlockByNumber", blocks are then traced with
+	// "trace_block"
+	debugTraceUnsupported atomic.Bool
+	// blockReceiptsUnsupported is set once an endpoint rejected "eth_getBlockReceipts", receipts are then fetched
+	// per transaction
+	blockReceiptsUnsupported atomic.Bool
+	// mu guards the health of the endpoints
+	mu sync.Mutex
+}
+
+// Option configures a Blockchain client.
+type Option func(*Blockchain)
+
+// WithMaxBatchSize sets the number of calls sent in a single JSON-RPC batch request, which providers usually cap.
+func WithMaxBatchSize(size int) Option {
+	return func(b *Blockchain) {
+		if size > 0 {
+			b.maxBatchSize = size
+		}
+	}
+}
+
+// WithEndpoint adds a JSON-RPC endpoint the requests fail over to, endpoints with a lower priority value are preferred.
+// The endpoint given to NewBlockchain has priority 0.
+func WithEndpoint(url string, priority int) Option {
+	return func(b *Blockchain) {
+		b.endpoints = append(b.endpoints, &endpoint{url: url, priority: priority})
+	}
+}
+
+// WithMaxHeadLag sets the number of blocks an endpoint can lag behind the most advanced endpoint before it is
+// quarantined, a quarantined endpoint is only used once every other endpoint failed.
+func WithMaxHeadLag(blocks int) Option {
+	return func(b *Blockchain) {
+		if blocks > 0 {
+			b.maxHeadLag = blocks
+		}
+	}
+}
+
+// WithHealthCheckInterval sets the delay between two checks of the head of every endpoint by RunHealthChecks.
+func WithHealthCheckInterval(interval time.Duration) Option {
+	return func(b *Blockchain) {
+		if interval > 0 {
+			b.healthCheckInterval = interval
+		}
+	}
+}
+
+// WithInternalTransactions traces the fetched blocks to extract the ether moved by contracts, which requires endpoints
+// supporting "debug_traceBlockByNumber" or "trace_block".
+func WithInternalTransactions() Option {
+	return func(b *Blockchain) {
+		b.internalTransactions = true
+	}
 }
 
 type blockData struct {
-	Result struct {
-		Transactions []store.Transaction `json:"transactions"`
-	} `json:"result"`
+	Number       string                     `json:"number"`
+	Hash         string                     `json:"hash"`
+	ParentHash   string                     `json:"parentHash"`
+	Timestamp    string                     `json:"timestamp"`
+	Transactions []store.TransactionDetails `json:"transactions"`
+	Withdrawals  []store.Withdrawal         `json:"withdrawals"`
 }
 
-// NewBlockchain returns new instance of the blockchain client
-func NewBlockchain(endpoint string) *Blockchain {
-	return &Blockchain{
-		jsonRPCEndpoint: endpoint,
+// rpcRequest is the envelope of a JSON-RPC request.
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+	ID      string        `json:"id"`
+}
+
+// rpcResponse is the envelope of a JSON-RPC response.
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// rpcCall is a call of a JSON-RPC batch, its result is decoded into result and its failure kept in err.
+type rpcCall struct {
+	method string
+	params []interface{}
+	result interface{}
+	err    error
+}
+
+// NewBlockchain returns new instance of the blockchain client for the JSON-RPC endpoint at url, failover endpoints
+// are added with WithEndpoint.
+func NewBlockchain(url string, options ...Option) *Blockchain {
+	b := &Blockchain{
+		endpoints:    []*endpoint{{url: url}},
+		client:       &http.Client{Timeout: requestTimeout},
+		maxBatchSize: defaultMaxBatchSize,
+		maxHeadLag:   defaultMaxHeadLag,
+
+		healthCheckInterval: defaultHealthCheckInterval,
+	}
+	for _, option := range options {
+		option(b)
 	}
+	return b
 }
 
-// ParseBlock returns the transactions within a block
-func (b *Blockchain) ParseBlock(block int) ([]store.Transaction, error) {
-	var blockData blockData
-	response, err := b.jsonRPCRequest("eth_getBlockByNumber", []interface{}{fmt.Sprintf("0x%x", block), true})
+// ParseBlock returns the header and the transactions of a block, enriched with their receipts
+func (b *Blockchain) ParseBlock(ctx context.Context, block int) (store.Block, error) {
+	var blockData *blockData
+	if err := b.jsonRPCRequest(ctx, "eth_getBlockByNumber", []interface{}{fmt.Sprintf("0x%x", block), true}, &blockData); err != nil {
+		return store.Block{}, fmt.Errorf("error fetching block %d: %w", block, err)
+	}
+	parsed, err := toBlock(block, blockData)
 	if err != nil {
-		log.Println("Error fetching block number:", err)
-		return nil, fmt.Errorf("error fetching block number: %s", err.Error())
+		return store.Block{}, err
+	}
+	if err := b.attachReceipts(ctx, []*store.Block{&parsed})[0]; err != nil {
+		return store.Block{}, fmt.Errorf("error fetching receipts of block %d: %w", block, err)
 	}
-	json.Unmarshal(response, &blockData)
-	return blockData.Result.Transactions, nil
+	if b.internalTransactions {
+		if err := b.attachTraces(ctx, []*store.Block{&parsed})[0]; err != nil {
+			return store.Block{}, fmt.Errorf("error tracing block %d: %w", block, err)
+		}
+	}
+	stampTimes(&parsed)
+	return parsed, nil
 }
 
-// LatestNetworkBlock returns the latest block on the network
-func (b *Blockchain) LatestNetworkBlock() int {
-	response, err := b.jsonRPCRequest("eth_blockNumber", []interface{}{})
+// ParseBlocks returns the headers and the transactions of several blocks, fetched with batch requests.
+// The failure to fetch a block is reported at the same index in the returned errors.
+func (b *Blockchain) ParseBlocks(ctx context.Context, blocks []int) ([]store.Block, []error) {
+	parsed := make([]store.Block, len(blocks))
+	errs := make([]error, len(blocks))
+	if len(blocks) == 1 {
+		parsed[0], errs[0] = b.ParseBlock(ctx, blocks[0])
+		return parsed, errs
+	}
+
+	blockData := make([]*blockData, len(blocks))
+	calls := make([]*rpcCall, len(blocks))
+	for i, block := range blocks {
+		calls[i] = &rpcCall{
+			method: "eth_getBlockByNumber",
+			params: []interface{}{fmt.Sprintf("0x%x", block), true},
+			result: &blockData[i],
+		}
+	}
+	b.batchRPCRequest(ctx, calls)
+
+	var fetched []*store.Block
+	var fetchedIndexes []int
+	for i, block := range blocks {
+		if calls[i].err != nil {
+			errs[i] = fmt.Errorf("error fetching block %d: %w", block, calls[i].err)
+			continue
+		}
+		if parsed[i], errs[i] = toBlock(block, blockData[i]); errs[i] == nil {
+			fetched = append(fetched, &parsed[i])
+			fetchedIndexes = append(fetchedIndexes, i)
+		}
+	}
+
+	// The receipts and the traces of the fetched blocks are fetched in further rounds of batch requests.
+	for i, err := range b.attachReceipts(ctx, fetched) {
+		if err != nil {
+			index := fetchedIndexes[i]
+			parsed[index] = store.Block{}
+			errs[index] = fmt.Errorf("error fetching receipts of block %d: %w", blocks[index], err)
+		}
+	}
+	if b.internalTransactions {
+		for i, err := range b.attachTraces(ctx, fetched) {
+			if err != nil && errs[fetchedIndexes[i]] == nil {
+				index := fetchedIndexes[i]
+				parsed[index] = store.Block{}
+				errs[index] = fmt.Errorf("error tracing block %d: %w", blocks[index], err)
+			}
+		}
+	}
+	for i := range parsed {
+		if errs[i] == nil {
+			stampTimes(&parsed[i])
+		}
+	}
+	return parsed, errs
+}
+
+// toBlock converts the block returned by the network.
+func toBlock(block int, blockData *blockData) (store.Block, error) {
+	if blockData == nil {
+		return store.Block{}, fmt.Errorf("error fetching block %d: %w", block, ErrBlockNotFound)
+	}
+
+	number, err := parseQuantity(blockData.Number)
+	if err != nil {
+		return store.Block{}, fmt.Errorf("error parsing block %d number: %w", block, err)
+	}
+	var timestamp int
+	if blockData.Timestamp != "" {
+		if timestamp, err = parseQuantity(blockData.Timestamp); err != nil {
+			return store.Block{}, fmt.Errorf("error parsing block %d timestamp: %w", block, err)
+		}
+	}
+	transactions := make([]store.Transaction, len(blockData.Transactions))
+	for i, tx := range blockData.Transactions {
+		transactions[i] = tx.Slim()
+	}
+	// Withdrawals carry no block number, blocks before Shanghai have none.
+	for i := range blockData.Withdrawals {
+		blockData.Withdrawals[i].BlockNumber = fmt.Sprintf("0x%x", number)
+	}
+	return store.Block{
+		BlockHeader: store.BlockHeader{
+			Number:     number,
+			Hash:       blockData.Hash,
+			ParentHash: blockData.ParentHash,
+			Timestamp:  int64(timestamp),
+		},
+		Transactions: transactions,
+		Withdrawals:  blockData.Withdrawals,
+	}, nil
+}
+
+// stampTimes sets the time of the block on its transactions, token transfers, internal transactions and withdrawals.
+func stampTimes(block *store.Block) {
+	if block.Timestamp == 0 {
+		return
+	}
+	blockTime := block.Time()
+	for i := range block.Transactions {
+		block.Transactions[i].Timestamp = &blockTime
+	}
+	for i := range block.TokenTransfers {
+		block.TokenTransfers[i].Timestamp = &blockTime
+	}
+	for i := range block.InternalTransactions {
+		block.InternalTransactions[i].Timestamp = &blockTime
+	}
+	for i := range block.Withdrawals {
+		block.Withdrawals[i].Timestamp = &blockTime
+	}
+}
+
+// LatestNetworkBlock returns the latest block on the network, as reported by the preferred endpoint. The endpoints are
+// tried in order until one of them replies, the head of every endpoint is only checked by CheckHealth.
+func (b *Blockchain) LatestNetworkBlock(ctx context.Context) (int, error) {
+	var result string
+	if err := b.jsonRPCRequest(ctx, "eth_blockNumber", []interface{}{}, &result); err != nil {
+		return 0, fmt.Errorf("error fetching block number: %w", err)
+	}
+	return parseQuantity(result)
+}
+
+// RunHealthChecks checks the head of every endpoint right away and then periodically, until the context is canceled.
+func (b *Blockchain) RunHealthChecks(ctx context.Context) {
+	ticker := time.NewTicker(b.healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		b.CheckHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckHealth checks the head of every endpoint, the endpoints lagging behind are quarantined. The check also lets
+// endpoints that failed earlier recover once they answer again.
+func (b *Blockchain) CheckHealth(ctx context.Context) {
+	endpoints := b.orderedEndpoints()
+	heads := make([]int, len(endpoints))
+	errs := make([]error, len(endpoints))
+	var wg sync.WaitGroup
+	for i, e := range endpoints {
+		wg.Add(1)
+		go func(i int, e *endpoint) {
+			defer wg.Done()
+			heads[i], errs[i] = b.endpointHead(ctx, e)
+		}(i, e)
+	}
+	wg.Wait()
+
+	b.mu.Lock()
+	networkHead := -1
+	for i, e := range endpoints {
+		if errs[i] == nil {
+			e.headBlock = heads[i]
+			networkHead = max(networkHead, heads[i])
+		}
+	}
+	for i, e := range endpoints {
+		if errs[i] == nil {
+			e.quarantined = networkHead-heads[i] > b.maxHeadLag
+		}
+	}
+	b.mu.Unlock()
+}
+
+// endpointHead returns the latest block known to an endpoint.
+func (b *Blockchain) endpointHead(ctx context.Context, e *endpoint) (int, error) {
+	requestBody, err := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: "eth_blockNumber", Params: []interface{}{}, ID: b.randomID()})
 	if err != nil {
-		log.Println("Error fetching block number:", err)
-		return 0
+		return 0, err
 	}
+	body, err := b.postTo(ctx, e, requestBody)
+	if err != nil {
+		return 0, err
+	}
+	var result string
+	if err := decodeResponse(body, &result); err != nil {
+		return 0, err
+	}
+	return parseQuantity(result)
+}
+
+// EndpointHealth reports the health of the endpoints, in the order they are tried.
+func (b *Blockchain) EndpointHealth() []EndpointHealth {
+	endpoints := b.orderedEndpoints()
 
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health := make([]EndpointHealth, len(endpoints))
+	for i, e := range endpoints {
+		health[i] = e.health()
+	}
+	return health
+}
+
+// orderedEndpoints returns the endpoints in the order they are tried.
+func (b *Blockchain) orderedEndpoints() []*endpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	endpoints := append([]*endpoint(nil), b.endpoints...)
+	orderEndpoints(endpoints)
+	return endpoints
+}
+
+// PendingTransactions returns the transactions of the mempool that are ready to be mined, listed with "txpool_content".
+func (b *Blockchain) PendingTransactions(ctx context.Context) ([]store.Transaction, error) {
 	var result struct {
-		Result string `json:"result"`
+		Pending map[string]map[string]store.TransactionDetails `json:"pending"`
+	}
+	if err := b.jsonRPCRequest(ctx, "txpool_content", []interface{}{}, &result); err != nil {
+		return nil, fmt.Errorf("error fetching mempool: %w", err)
+	}
+	var transactions []store.Transaction
+	for _, byNonce := range result.Pending {
+		for _, tx := range byNonce {
+			transactions = append(transactions, tx.Slim())
+		}
+	}
+	return transactions, nil
+}
+
+// TransactionCount returns the number of transactions sent by the address up to the given block, its nonce.
+func (b *Blockchain) TransactionCount(ctx context.Context, address store.Address, block int) (int, error) {
+	var result string
+	if err := b.jsonRPCRequest(ctx, "eth_getTransactionCount", []interface{}{address, fmt.Sprintf("0x%x", block)}, &result); err != nil {
+		return 0, fmt.Errorf("error fetching transaction count of %s at block %d: %w", address, block, err)
+	}
+	return parseQuantity(result)
+}
+
+// Balance returns the ether balance of the address at the given block, in wei.
+func (b *Blockchain) Balance(ctx context.Context, address store.Address, block int) (*store.Quantity, error) {
+	var result string
+	if err := b.jsonRPCRequest(ctx, "eth_getBalance", []interface{}{address, fmt.Sprintf("0x%x", block)}, &result); err != nil {
+		return nil, fmt.Errorf("error fetching balance of %s at block %d: %w", address, block, err)
+	}
+	return store.ParseQuantity(result)
+}
+
+// BlockNumberByTag returns the number of the block the network labels with the given tag, such as "finalized" or "safe"
+func (b *Blockchain) BlockNumberByTag(ctx context.Context, tag string) (int, error) {
+	var result *struct {
+		Number string `json:"number"`
+	}
+	if err := b.jsonRPCRequest(ctx, "eth_getBlockByNumber", []interface{}{tag, false}, &result); err != nil {
+		return 0, fmt.Errorf("error fetching %s block: %w", tag, err)
+	}
+	if result == nil {
+		return 0, fmt.Errorf("error fetching %s block: %w", tag, ErrBlockNotFound)
+	}
+	return parseQuantity(result.Number)
+}
+
+// jsonRPCRequest issues a RPC request to the Etherium blockchain network and decodes its result.
+func (b *Blockchain) jsonRPCRequest(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	body, err := b.post(ctx, rpcRequest{JSONRPC: "2.0", Method: method, Params: params, ID: b.randomID()})
+	if err != nil {
+		return err
+	}
+	return decodeResponse(body, result)
+}
+
+// decodeResponse decodes the body of a single JSON-RPC response into result.
+func decodeResponse(body []byte, result interface{}) error {
+	var response rpcResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return response.decode(result)
+}
+
+// batchRPCRequest issues the calls as JSON-RPC batch requests of at most maxBatchSize calls. The responses are
+// correlated to the calls by id, and the outcome of every call is kept in the call.
+func (b *Blockchain) batchRPCRequest(ctx context.Context, calls []*rpcCall) {
+	if len(calls) == 1 {
+		calls[0].err = b.jsonRPCRequest(ctx, calls[0].method, calls[0].params, calls[0].result)
+		return
+	}
+	for start := 0; start < len(calls); start += b.maxBatchSize {
+		b.sendBatch(ctx, calls[start:min(start+b.maxBatchSize, len(calls))])
+	}
+}
+
+// sendBatch issues a single JSON-RPC batch request.
+func (b *Blockchain) sendBatch(ctx context.Context, calls []*rpcCall) {
+	requests := make([]rpcRequest, len(calls))
+	for i, call := range calls {
+		requests[i] = rpcRequest{JSONRPC: "2.0", Method: call.method, Params: call.params, ID: strconv.Itoa(i)}
+	}
+
+	body, err := b.post(ctx, requests)
+	if err != nil {
+		for _, call := range calls {
+			call.err = err
+		}
+		return
+	}
+
+	var responses []rpcResponse
+	if err := json.Unmarshal(body, &responses); err != nil {
+		// Providers rejecting the whole batch reply with a single error object.
+		var response rpcResponse
+		if json.Unmarshal(body, &response) == nil && response.Error != nil {
+			err = response.Error
+		} else {
+			err = fmt.Errorf("error decoding batch response: %w", err)
+		}
+		for _, call := range calls {
+			call.err = err
+		}
+		return
+	}
+
+	byID := make(map[string]rpcResponse, len(responses))
+	for _, response := range responses {
+		var id string
+		if json.Unmarshal(response.ID, &id) != nil {
+			id = string(response.ID)
+		}
+		byID[id] = response
+	}
+	for i, call := range calls {
+		response, exists := byID[strconv.Itoa(i)]
+		if !exists {
+			call.err = errors.New("missing response in batch")
+			continue
+		}
+		call.err = response.decode(call.result)
+	}
+}
+
+// post sends a JSON-RPC payload and returns the response body. The endpoints are tried in order until one of them
+// replies without a retryable JSON-RPC error. When they all fail, the error of the last endpoint is returned, or its
+// reply when it holds JSON-RPC errors, so they are reported for each call.
+func (b *Blockchain) post(ctx context.Context, payload interface{}) ([]byte, error) {
+	requestBody, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	for _, e := range b.orderedEndpoints() {
+		if body, err = b.postTo(ctx, e, requestBody); err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) && ctx.Err() == nil {
+		return body, nil
+	}
+	return body, err
+}
+
+// postTo sends a JSON-RPC request body to an endpoint, recording the outcome in the endpoint health. A reply holding a
+// retryable JSON-RPC error is returned along with the error.
+func (b *Blockchain) postTo(ctx context.Context, e *endpoint, requestBody []byte) ([]byte, error) {
+	start := time.Now()
+	body, err := b.send(ctx, e.url, requestBody)
+	if err == nil {
+		if rpcErr := retryableError(body); rpcErr != nil {
+			err = rpcErr
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case err == nil:
+		e.recordSuccess(time.Since(start))
+	case ctx.Err() == nil:
+		// Requests canceled by the caller don't tell anything about the endpoint.
+		e.recordFailure(err)
 	}
-	json.Unmarshal(response, &result)
-	var latestBlock int
-	fmt.Sscanf(result.Result, "0x%x", &latestBlock)
-	return latestBlock
+	return body, err
 }
 
-// jsonRPCRequest issues a RPC request to the Etherium blockchain network.
-func (b *Blockchain) jsonRPCRequest(method string, params []interface{}) ([]byte, error) {
-	requestBody, _ := json.Marshal(map[string]interface{}{
-		"jsonrpc": "2.0",
-		"method":  method,
-		"params":  params,
-		"id":      b.randomID(),
-	})
+// send posts a JSON-RPC request body to the url and returns the response body.
+func (b *Blockchain) send(ctx context.Context, url string, requestBody []byte) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.Post(b.jsonRPCEndpoint, "application/json", bytes.NewReader(requestBody))
+	resp, err := b.client.Do(request)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
 	return io.ReadAll(resp.Body)
 }
 
+// retryableError returns the first retryable JSON-RPC error of a single or a batch reply, nil when there is none.
+func retryableError(body []byte) *RPCError {
+	var responses []rpcResponse
+	if json.Unmarshal(body, &responses) != nil {
+		var response rpcResponse
+		if json.Unmarshal(body, &response) != nil {
+			return nil
+		}
+		responses = []rpcResponse{response}
+	}
+	for _, response := range responses {
+		if response.Error != nil && response.Error.retryable() {
+			return response.Error
+		}
+	}
+	return nil
+}
+
+// decode returns the error object of the response, or decodes its result.
+func (r rpcResponse) decode(result interface{}) error {
+	if r.Error != nil {
+		return r.Error
+	}
+	if err := json.Unmarshal(r.Result, result); err != nil {
+		return fmt.Errorf("error decoding result: %w", err)
+	}
+	return nil
+}
+
+// parseQuantity decodes a hex encoded quantity.
+func parseQuantity(quantity string) (int, error) {
+	value, err := strconv.ParseInt(quantity, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q: %w", quantity, err)
+	}
+	return int(value), nil
+}
+
 // randomID generates random Identifier
 func (b *Blockchain) randomID() string {
 	return strconv.Itoa(int(rand.Uint32()))
@@ -0,0 +1,542 @@
+package blockchain_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mo-mohamed/txparser/blockchain"
+	store "github.com/mo-mohamed/txparser/storage"
+	"github.com/mo-mohamed/txparser/websocket"
+)
+
+// rpcServer starts a JSON-RPC endpoint replying to every request with the given status and body.
+func rpcServer(t *testing.T, status int, body string) *blockchain.Blockchain {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return blockchain.NewBlockchain(server.URL)
+}
+
+// methodServer starts a JSON-RPC endpoint answering single and batch requests with the members given for the method,
+// such as `"result":"0x1"`. Members given for a method along with its first parameter, keyed as "method param",
+// take precedence. Methods without members are answered with a "method not found" error.
+func methodServer(t *testing.T, members map[string]string, options ...blockchain.Option) *blockchain.Blockchain {
+	t.Helper()
+	answer := func(request map[string]interface{}) string {
+		id, _ := json.Marshal(request["id"])
+		method := request["method"].(string)
+		member, exists := members[method]
+		if params, _ := request["params"].([]interface{}); len(params) > 0 {
+			if m, found := members[fmt.Sprintf("%s %v", method, params[0])]; found {
+				member, exists = m, true
+			}
+		}
+		if !exists {
+			member = `"error":{"code":-32601,"message":"the method does not exist"}`
+		}
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,%s}`, id, member)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload json.RawMessage
+		json.NewDecoder(r.Body).Decode(&payload)
+		var requests []map[string]interface{}
+		if json.Unmarshal(payload, &requests) != nil {
+			var request map[string]interface{}
+			json.Unmarshal(payload, &request)
+			w.Write([]byte(answer(request)))
+			return
+		}
+		responses := make([]string, len(requests))
+		for i, request := range requests {
+			responses[i] = answer(request)
+		}
+		w.Write([]byte("[" + strings.Join(responses, ",") + "]"))
+	}))
+	t.Cleanup(server.Close)
+	return blockchain.NewBlockchain(server.URL, options...)
+}
+
+func TestParseBlock(t *testing.T) {
+	client := methodServer(t, map[string]string{
+		"eth_getBlockByNumber": `"result":{"number":"0x64","hash":"0xb100","parentHash":"0xb99","timestamp":"0x65f1b057",
+			"transactions":[{"hash":"0xabc","type":"0x2","chainId":"0x1","nonce":"0x3","from":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+			"to":null,"value":"0x1","blockNumber":"0x64","transactionIndex":"0x0","gas":"0x5208","maxFeePerGas":"0x77359400",
+			"maxPriorityFeePerGas":"0x1","input":"0x6080","accessList":[]}]}`,
+		"eth_getBlockReceipts": `"result":[{"transactionHash":"0xabc","status":"0x0","gasUsed":"0x5208","effectiveGasPrice":"0x3b9aca00",
+			"contractAddress":"0xFB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"}]`,
+	})
+
+	block, err := client.ParseBlock(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("Expected block to be parsed, got %v", err)
+	}
+	if block.Number != 100 || block.Hash != "0xb100" || block.ParentHash != "0xb99" {
+		t.Errorf("Expected header of block 100, got %+v", block.BlockHeader)
+	}
+	if len(block.Transactions) != 1 || block.Transactions[0].From != "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed" {
+		t.Fatalf("Expected a normalized transaction, got %+v", block.Transactions)
+	}
+	tx := block.Transactions[0]
+	if tx.ExecutionStatus != store.ExecutionReverted || tx.GasUsed != "0x5208" || tx.EffectiveGasPrice != "0x3b9aca00" {
+		t.Errorf("Expected the transaction to be enriched with its receipt, got %+v", tx)
+	}
+	// 21000 gas at 1 gwei
+	if tx.Fee != "0x1319718a5000" || tx.ContractAddress != "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359" {
+		t.Errorf("Expected the fee and the created contract, got %+v", tx)
+	}
+	if tx.Nonce != "0x3" || tx.Details == nil || tx.Details.TransactionType() != store.TransactionDynamicFee ||
+		tx.Details.MaxFeePerGas.Hex() != "0x77359400" || tx.Details.Input != "0x6080" {
+		t.Errorf("Expected the complete transaction to be kept, got %+v", tx.Details)
+	}
+	blockTime := time.Date(2024, 3, 13, 13, 55, 35, 0, time.UTC)
+	if !block.Time().Equal(blockTime) || tx.Timestamp == nil || !tx.Timestamp.Equal(blockTime) {
+		t.Errorf("Expected the transaction to carry the time of its block, got %v %v", block.Time(), tx.Timestamp)
+	}
+}
+
+func TestParseBlocksTransactionReceipts(t *testing.T) {
+	// The endpoint doesn't support eth_getBlockReceipts.
+	client := methodServer(t, map[string]string{
+		"eth_getBlockByNumber 0x1": `"result":{"number":"0x1","hash":"0xb1","parentHash":"0xb0","transactions":[{"hash":"0xa"},{"hash":"0xb"}]}`,
+		"eth_getBlockByNumber 0x2": `"result":{"number":"0x2","hash":"0xb2","parentHash":"0xb1","transactions":[{"hash":"0xc"}]}`,
+		// Older clients don't report the effective gas price.
+		"eth_getTransactionReceipt 0xa": `"result":{"transactionHash":"0xa","status":"0x1","gasUsed":"0x1"}`,
+		"eth_getTransactionReceipt 0xb": `"result":{"transactionHash":"0xb","status":"0x0","gasUsed":"0x1","effectiveGasPrice":"0x2"}`,
+		"eth_getTransactionReceipt 0xc": `"result":null`,
+	})
+
+	blocks, errs := client.ParseBlocks(context.Background(), []int{1, 2})
+
+	if errs[0] != nil || blocks[0].Transactions[0].ExecutionStatus != store.ExecutionSucceeded || blocks[0].Transactions[0].Fee != "" ||
+		blocks[0].Transactions[1].ExecutionStatus != store.ExecutionReverted || blocks[0].Transactions[1].Fee != "0x2" {
+		t.Errorf("Expected the transactions of block 1 to be enriched with their receipts, got %+v, %v", blocks[0], errs[0])
+	}
+	if errs[1] == nil {
+		t.Error("Expected block 2 to fail for its missing receipt")
+	}
+}
+
+func TestParseBlocksReceiptsRateLimited(t *testing.T) {
+	client := methodServer(t, map[string]string{
+		"eth_getBlockByNumber 0x1":      `"result":{"number":"0x1","hash":"0xb1","parentHash":"0xb0","transactions":[{"hash":"0xa"}]}`,
+		"eth_getBlockReceipts":          `"error":{"code":-32005,"message":"method eth_getBlockReceipts is rate limited"}`,
+		"eth_getTransactionReceipt 0xa": `"result":{"transactionHash":"0xa","status":"0x1","gasUsed":"0x1","effectiveGasPrice":"0x2"}`,
+	})
+
+	// The rate limit doesn't tell the endpoint lacks eth_getBlockReceipts, the block fails rather than falling back
+	// to the receipts of every transaction.
+	if _, errs := client.ParseBlocks(context.Background(), []int{1}); errs[0] == nil {
+		t.Error("Expected the block to fail while its receipts are rate limited")
+	}
+}
+
+func TestParseBlockNotFound(t *testing.T) {
+	client := rpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":"1","result":null}`)
+
+	if _, err := client.ParseBlock(context.Background(), 100); !errors.Is(err, blockchain.ErrBlockNotFound) {
+		t.Errorf("Expected ErrBlockNotFound, got %v", err)
+	}
+}
+
+func TestJSONRPCError(t *testing.T) {
+	client := rpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":"1","error":{"code":-32005,"message":"limit exceeded","data":{"retryAfter":1}}}`)
+
+	_, err := client.LatestNetworkBlock(context.Background())
+	var rpcErr *blockchain.RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("Expected a JSON-RPC error, got %v", err)
+	}
+	if rpcErr.Code != -32005 || rpcErr.Message != "limit exceeded" || string(rpcErr.Data) != `{"retryAfter":1}` {
+		t.Errorf("Expected the rate limit error details, got %+v", rpcErr)
+	}
+}
+
+func TestHTTPStatusError(t *testing.T) {
+	client := rpcServer(t, http.StatusTooManyRequests, "Too Many Requests")
+
+	_, err := client.BlockNumberByTag(context.Background(), "finalized")
+	var statusErr *blockchain.HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected an HTTP status error, got %v", err)
+	}
+}
+
+func TestMalformedResponse(t *testing.T) {
+	client := rpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":"1","result":"0xzz"}`)
+
+	if _, err := client.LatestNetworkBlock(context.Background()); err == nil {
+		t.Error("Expected an error for a malformed block number")
+	}
+}
+
+func TestParseBlocksBatch(t *testing.T) {
+	var batches [][]map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			t.Errorf("Expected a batch request, got %v", err)
+		}
+		batches = append(batches, requests)
+
+		// Responses are sent in reverse order, the block 0x3 fails and the block 0x4 gets no response.
+		var responses []string
+		for i := len(requests) - 1; i >= 0; i-- {
+			id, _ := json.Marshal(requests[i]["id"])
+			switch number := requests[i]["params"].([]interface{})[0]; number {
+			case "0x3":
+				responses = append(responses, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"error":{"code":-32000,"message":"header not found"}}`, id))
+			case "0x4":
+			default:
+				responses = append(responses, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"number":"%s","hash":"0xb","parentHash":"0xa","transactions":[]}}`, id, number))
+			}
+		}
+		w.Write([]byte("[" + strings.Join(responses, ",") + "]"))
+	}))
+	defer server.Close()
+	client := blockchain.NewBlockchain(server.URL, blockchain.WithMaxBatchSize(2))
+
+	blocks, errs := client.ParseBlocks(context.Background(), []int{1, 2, 3, 4, 5})
+
+	if len(batches) != 3 || len(batches[0]) != 2 || len(batches[2]) != 1 {
+		t.Errorf("Expected the calls to be split in batches of 2, got %d batches", len(batches))
+	}
+	for i, expected := range []int{1, 2, 0, 0, 5} {
+		if blocks[i].Number != expected {
+			t.Errorf("Expected block at index %d to be %d, got %d", i, expected, blocks[i].Number)
+		}
+	}
+	var rpcErr *blockchain.RPCError
+	if !errors.As(errs[2], &rpcErr) || rpcErr.Message != "header not found" {
+		t.Errorf("Expected block 3 to fail with its JSON-RPC error, got %v", errs[2])
+	}
+	if errs[3] == nil {
+		t.Error("Expected block 4 to fail for its missing response")
+	}
+	if errs[0] != nil || errs[1] != nil || errs[4] != nil {
+		t.Errorf("Expected the other blocks to succeed, got %v", errs)
+	}
+}
+
+func TestParseBlocksBatchRejected(t *testing.T) {
+	client := rpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batch requests not supported"}}`)
+
+	_, errs := client.ParseBlocks(context.Background(), []int{1, 2})
+
+	for i, err := range errs {
+		var rpcErr *blockchain.RPCError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != -32600 {
+			t.Errorf("Expected block at index %d to fail with the batch error, got %v", i, err)
+		}
+	}
+}
+
+// endpointServer starts a JSON-RPC endpoint replying with the given status and body, and returns its url.
+func endpointServer(t *testing.T, status int, body string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestFailover(t *testing.T) {
+	primary := endpointServer(t, http.StatusServiceUnavailable, "overloaded")
+	fallback := endpointServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":"1","result":{"number":"0x64","hash":"0xb100","parentHash":"0xb99","transactions":[]}}`)
+	client := blockchain.NewBlockchain(primary, blockchain.WithEndpoint(fallback, 1))
+
+	block, err := client.ParseBlock(context.Background(), 100)
+	if err != nil || block.Hash != "0xb100" {
+		t.Fatalf("Expected block to be fetched from the fallback endpoint, got %+v, %v", block, err)
+	}
+	if health := client.EndpointHealth(); health[0].URL != primary {
+		t.Errorf("Expected a single failure to keep the primary endpoint preferred, got %+v", health)
+	}
+
+	for i := 0; i < 3; i++ {
+		client.ParseBlock(context.Background(), 100)
+	}
+	health := client.EndpointHealth()
+	if health[0].URL != fallback || !health[0].Healthy {
+		t.Errorf("Expected the fallback endpoint to be preferred once the primary kept failing, got %+v", health)
+	}
+	if health[1].URL != primary || health[1].Failures != 4 || health[1].Healthy {
+		t.Errorf("Expected the primary endpoint to be unhealthy, got %+v", health[1])
+	}
+}
+
+func TestFailoverOnRetryableRPCError(t *testing.T) {
+	rateLimited := endpointServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":"1","error":{"code":-32005,"message":"limit exceeded"}}`)
+	missingHeader := endpointServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":"1","error":{"code":-32000,"message":"header not found"}}`)
+	fallback := endpointServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":"1","result":{"number":"0x64","hash":"0xb100","parentHash":"0xb99","transactions":[]}}`)
+	client := blockchain.NewBlockchain(rateLimited, blockchain.WithEndpoint(missingHeader, 1), blockchain.WithEndpoint(fallback, 2))
+
+	block, err := client.ParseBlock(context.Background(), 100)
+	if err != nil || block.Hash != "0xb100" {
+		t.Fatalf("Expected block to be fetched from the fallback endpoint, got %+v, %v", block, err)
+	}
+	for _, health := range client.EndpointHealth() {
+		if health.URL != fallback && health.Failures != 1 {
+			t.Errorf("Expected the endpoint to record its JSON-RPC error as a failure, got %+v", health)
+		}
+	}
+
+	// Once every endpoint failed, the JSON-RPC error of the last one is reported.
+	client = blockchain.NewBlockchain(rateLimited, blockchain.WithEndpoint(missingHeader, 1))
+	_, err = client.ParseBlock(context.Background(), 100)
+	var rpcErr *blockchain.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Message != "header not found" {
+		t.Errorf("Expected the JSON-RPC error of the last endpoint, got %v", err)
+	}
+}
+
+func TestLatestNetworkBlockQueriesPreferredEndpoint(t *testing.T) {
+	var requests atomic.Int32
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":"1","result":"0x70"}`))
+	}))
+	t.Cleanup(fallback.Close)
+	primary := endpointServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":"1","result":"0x64"}`)
+	client := blockchain.NewBlockchain(primary, blockchain.WithEndpoint(fallback.URL, 1), blockchain.WithMaxHeadLag(20))
+
+	for i := 0; i < 3; i++ {
+		if head, err := client.LatestNetworkBlock(context.Background()); err != nil || head != 100 {
+			t.Fatalf("Expected the head of the primary endpoint, got %d, %v", head, err)
+		}
+	}
+	if requests.Load() != 0 {
+		t.Errorf("Expected the fallback endpoint not to be polled, got %d requests", requests.Load())
+	}
+
+	client.CheckHealth(context.Background())
+	if requests.Load() != 1 {
+		t.Errorf("Expected the health check to query the fallback endpoint, got %d requests", requests.Load())
+	}
+}
+
+func TestQuarantineLaggingEndpoint(t *testing.T) {
+	lagging := endpointServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":"1","result":"0x64"}`)
+	synced := endpointServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":"1","result":"0x70"}`)
+	client := blockchain.NewBlockchain(lagging, blockchain.WithEndpoint(synced, 1), blockchain.WithMaxHeadLag(5))
+
+	client.CheckHealth(context.Background())
+	head, err := client.LatestNetworkBlock(context.Background())
+	if err != nil || head != 112 {
+		t.Fatalf("Expected the head of the synced endpoint, got %d, %v", head, err)
+	}
+
+	health := client.EndpointHealth()
+	if health[0].URL != synced || health[0].HeadBlock != 112 {
+		t.Errorf("Expected the synced endpoint to be preferred, got %+v", health[0])
+	}
+	if health[1].URL != lagging || !health[1].Quarantined || health[1].HeadBlock != 100 {
+		t.Errorf("Expected the lagging endpoint to be quarantined, got %+v", health[1])
+	}
+}
+
+func TestSubscribeHeads(t *testing.T) {
+	// Every connection announces a single head and drops, the next connection announces the following head.
+	var connections int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		connections++
+		if _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		conn.WriteMessage([]byte(`{"jsonrpc":"2.0","id":"1","result":"0xsub"}`))
+		conn.WriteMessage([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0xsub","result":{"number":"0x%x"}}}`, 100+connections)))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	heads := blockchain.NewHeadSource("ws" + strings.TrimPrefix(server.URL, "http")).SubscribeHeads(ctx)
+
+	for _, expected := range []int{101, 102} {
+		if head := <-heads; head != expected {
+			t.Fatalf("Expected head %d, got %d", expected, head)
+		}
+	}
+	cancel()
+	if _, open := <-heads; open {
+		t.Error("Expected heads channel to be closed once the context is canceled")
+	}
+}
+
+func TestParseBlockTokenTransfers(t *testing.T) {
+	const (
+		transfer       = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+		transferSingle = "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62"
+		transferBatch  = "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb"
+		operator       = "0x000000000000000000000000dbf03b407c01e7cd3cbea99509d93f8dddc8c6fb"
+		from           = "0x0000000000000000000000005aaeb6053f3e94c9b9a09f33669435e7ef1beaed"
+		to             = "0x000000000000000000000000fb6916095ca1df60bb79ce92ce3ea74c37c5d359"
+	)
+	word := func(value int) string { return fmt.Sprintf("%064x", value) }
+	client := methodServer(t, map[string]string{
+		"eth_getBlockByNumber": `"result":{"number":"0x64","hash":"0xb100","parentHash":"0xb99","transactions":[{"hash":"0xabc"}]}`,
+		"eth_getBlockReceipts": fmt.Sprintf(`"result":[{"transactionHash":"0xabc","status":"0x1","gasUsed":"0x1","effectiveGasPrice":"0x1","logs":[
+			{"address":"0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48","topics":["%[1]s","%[4]s","%[5]s"],"data":"0x%[6]s","logIndex":"0x7"},
+			{"address":"0xB0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48","topics":["%[1]s","%[4]s","%[5]s","0x2a"],"data":"0x","logIndex":"0x8"},
+			{"address":"0xC0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48","topics":["%[2]s","%[3]s","%[4]s","%[5]s"],"data":"0x%[7]s","logIndex":"0x9"},
+			{"address":"0xC0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48","topics":["%[8]s","%[3]s","%[4]s","%[5]s"],"data":"0x%[9]s","logIndex":"0xa"},
+			{"address":"0xD0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48","topics":["%[1]s","%[4]s","%[5]s"],"data":"0x01","logIndex":"0xb"}]}]`,
+			transfer, transferSingle, operator, from, to,
+			word(1000000),
+			word(5)+word(3),
+			transferBatch, word(64)+word(160)+word(2)+word(6)+word(7)+word(2)+word(1)+word(2)),
+	})
+
+	block, err := client.ParseBlock(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("Expected block to be parsed, got %v", err)
+	}
+	expected := []store.TokenTransfer{
+		{LogIndex: 7, Standard: store.StandardERC20, Token: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", Amount: "0xf4240"},
+		{LogIndex: 8, Standard: store.StandardERC721, Token: "0xb0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", TokenID: "0x2a", Amount: "0x1"},
+		{LogIndex: 9, Standard: store.StandardERC1155, Token: "0xc0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", TokenID: "0x5", Amount: "0x3"},
+		{LogIndex: 10, Standard: store.StandardERC1155, Token: "0xc0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", TokenID: "0x6", Amount: "0x1"},
+		{LogIndex: 10, BatchIndex: 1, Standard: store.StandardERC1155, Token: "0xc0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", TokenID: "0x7", Amount: "0x2"},
+	}
+	for i := range expected {
+		expected[i].TransactionHash = "0xabc"
+		expected[i].BlockNumber = "0x64"
+		expected[i].From = "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"
+		expected[i].To = "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"
+		if expected[i].Standard == store.StandardERC1155 {
+			expected[i].Operator = "0xdbf03b407c01e7cd3cbea99509d93f8dddc8c6fb"
+		}
+	}
+	// The malformed transfer event is ignored.
+	if len(block.TokenTransfers) != len(expected) {
+		t.Fatalf("Expected %d token transfers, got %+v", len(expected), block.TokenTransfers)
+	}
+	for i, transfer := range block.TokenTransfers {
+		if transfer != expected[i] {
+			t.Errorf("Expected token transfer %+v, got %+v", expected[i], transfer)
+		}
+	}
+}
+
+func TestParseBlockInternalTransactions(t *testing.T) {
+	block := `"result":{"number":"0x64","hash":"0xb100","parentHash":"0xb99","transactions":[{"hash":"0xa"},{"hash":"0xb"}]}`
+	receipts := `"result":[{"transactionHash":"0xa","status":"0x1","gasUsed":"0x1","effectiveGasPrice":"0x1"},
+		{"transactionHash":"0xb","status":"0x1","gasUsed":"0x1","effectiveGasPrice":"0x1"}]`
+
+	// The wallet 0xc1 forwards ether to 0xd1 and 0xd2, the second forward reverts along with the call it made.
+	// The second transaction reverts as a whole.
+	client := methodServer(t, map[string]string{
+		"eth_getBlockByNumber": block,
+		"eth_getBlockReceipts": receipts,
+		"debug_traceBlockByNumber": `"result":[
+			{"txHash":"0xa","result":{"type":"CALL","from":"0xe0","to":"0xc1","value":"0x5","calls":[
+				{"type":"CALL","from":"0xc1","to":"0xd1","value":"0x2","calls":[{"type":"DELEGATECALL","from":"0xd1","to":"0xf1","value":"0x2"}]},
+				{"type":"CALL","from":"0xc1","to":"0xd2","value":"0x3","error":"execution reverted","calls":[{"type":"CALL","from":"0xd2","to":"0xd3","value":"0x1"}]},
+				{"type":"STATICCALL","from":"0xc1","to":"0xf2"}]}},
+			{"txHash":"0xb","result":{"type":"CALL","from":"0xe0","to":"0xc1","value":"0x5","error":"execution reverted","calls":[
+				{"type":"CALL","from":"0xc1","to":"0xd1","value":"0x5"}]}}]`,
+	}, blockchain.WithInternalTransactions())
+
+	parsed, err := client.ParseBlock(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("Expected block to be parsed, got %v", err)
+	}
+	if len(parsed.InternalTransactions) != 1 {
+		t.Fatalf("Expected a single internal transaction, got %+v", parsed.InternalTransactions)
+	}
+	call := parsed.InternalTransactions[0]
+	if call.TransactionHash != "0xa" || fmt.Sprint(call.TraceAddress) != "[0]" || call.Type != "call" ||
+		call.From != "0xc1" || call.To != "0xd1" || call.Value != "0x2" || call.BlockNumber != "0x64" {
+		t.Errorf("Expected the forward to 0xd1, got %+v", call)
+	}
+
+	// The same block traced by an endpoint only supporting trace_block.
+	client = methodServer(t, map[string]string{
+		"eth_getBlockByNumber": block,
+		"eth_getBlockReceipts": receipts,
+		"trace_block": `"result":[
+			{"type":"call","action":{"callType":"call","from":"0xe0","to":"0xc1","value":"0x5"},"traceAddress":[],"transactionHash":"0xa"},
+			{"type":"call","action":{"callType":"call","from":"0xc1","to":"0xd1","value":"0x2"},"traceAddress":[0],"transactionHash":"0xa"},
+			{"type":"call","action":{"callType":"delegatecall","from":"0xd1","to":"0xf1","value":"0x2"},"traceAddress":[0,0],"transactionHash":"0xa"},
+			{"type":"call","action":{"callType":"call","from":"0xc1","to":"0xd2","value":"0x3"},"error":"Reverted","traceAddress":[1],"transactionHash":"0xa"},
+			{"type":"call","action":{"callType":"call","from":"0xd2","to":"0xd3","value":"0x1"},"traceAddress":[1,0],"transactionHash":"0xa"},
+			{"type":"call","action":{"callType":"call","from":"0xe0","to":"0xc1","value":"0x5"},"error":"Reverted","traceAddress":[],"transactionHash":"0xb"},
+			{"type":"call","action":{"callType":"call","from":"0xc1","to":"0xd1","value":"0x5"},"traceAddress":[0],"transactionHash":"0xb"},
+			{"type":"reward","action":{"author":"0xe1","value":"0x1"},"traceAddress":[]}]`,
+	}, blockchain.WithInternalTransactions())
+
+	parsed, err = client.ParseBlock(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("Expected block to be parsed, got %v", err)
+	}
+	if len(parsed.InternalTransactions) != 1 || parsed.InternalTransactions[0].To != "0xd1" || parsed.InternalTransactions[0].Value != "0x2" {
+		t.Errorf("Expected the forward to 0xd1 from trace_block, got %+v", parsed.InternalTransactions)
+	}
+}
+
+func TestPendingTransactions(t *testing.T) {
+	b := methodServer(t, map[string]string{
+		"txpool_content": `"result":{
+			"pending":{"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed":{"7":{"hash":"0x1","from":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed","to":"0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359","value":"0x10","nonce":"0x7","blockNumber":null}}},
+			"queued":{"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed":{"9":{"hash":"0x2","from":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed","nonce":"0x9"}}}
+		}`,
+	})
+
+	transactions, err := b.PendingTransactions(context.Background())
+	if err != nil {
+		t.Fatalf("PendingTransactions failed: %v", err)
+	}
+	if len(transactions) != 1 || transactions[0].Hash != "0x1" || transactions[0].Nonce != "0x7" || transactions[0].BlockNumber != "" {
+		t.Errorf("Expected the single pending transaction, queued ones are not ready to be mined, got %+v", transactions)
+	}
+}
+
+func TestAccountHistory(t *testing.T) {
+	b := methodServer(t, map[string]string{
+		"eth_getTransactionCount": `"result":"0x7"`,
+		"eth_getBalance":          `"result":"0xde0b6b3a7640000"`,
+	})
+
+	nonce, err := b.TransactionCount(context.Background(), "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", 100)
+	if err != nil || nonce != 7 {
+		t.Errorf("Expected the nonce 7, got %d %v", nonce, err)
+	}
+	balance, err := b.Balance(context.Background(), "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", 100)
+	if err != nil || balance.String() != "1000000000000000000" {
+		t.Errorf("Expected a balance of 1 ether, got %v %v", balance, err)
+	}
+}
+
+func TestParseBlockWithdrawals(t *testing.T) {
+	client := methodServer(t, map[string]string{
+		"eth_getBlockByNumber": `"result":{"number":"0x64","hash":"0xb100","parentHash":"0xb99","transactions":[],
+			"withdrawals":[{"index":"0x2a","validatorIndex":"0x3e8","address":"0xFB6916095ca1df60bB79Ce92cE3Ea74c37c5d359","amount":"0xe4e1c0"}]}`,
+	})
+
+	block, err := client.ParseBlock(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("Expected block to be parsed, got %v", err)
+	}
+	expected := store.Withdrawal{Index: "0x2a", ValidatorIndex: "0x3e8", Address: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359",
+		Amount: "0xe4e1c0", BlockNumber: "0x64"}
+	if len(block.Withdrawals) != 1 || block.Withdrawals[0] != expected {
+		t.Errorf("Expected the withdrawal of the block, got %+v", block.Withdrawals)
+	}
+}
@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// defaultMaxHeadLag is the number of blocks an endpoint can lag behind the most advanced endpoint before it is quarantined.
+	defaultMaxHeadLag = 5
+	// healthDecay is the weight of the history in the moving averages of the latency and the error rate.
+	healthDecay = 0.8
+	// unhealthyErrorRate is the error rate above which an endpoint is only used once the healthy endpoints failed.
+	unhealthyErrorRate = 0.5
+)
+
+// EndpointHealth describes the health of a JSON-RPC endpoint as observed by the client.
+type EndpointHealth struct {
+	// URL is the address of the endpoint.
+	URL string `json:"url"`
+	// Priority is the priority of the endpoint, lower values are preferred.
+	Priority int `json:"priority"`
+	// Healthy tells whether the endpoint is used ahead of the unhealthy and quarantined endpoints.
+	Healthy bool `json:"healthy"`
+	// Quarantined tells whether the endpoint lags too far behind the head of the other endpoints.
+	Quarantined bool `json:"quarantined"`
+	// HeadBlock is the latest block the endpoint reported.
+	HeadBlock int `json:"headBlock"`
+	// LatencyMs is the moving average of the duration of the successful requests, in milliseconds.
+	LatencyMs float64 `json:"latencyMs"`
+	// ErrorRate is the moving average of the failed requests, between 0 and 1.
+	ErrorRate float64 `json:"errorRate"`
+	// Requests is the number of requests sent to the endpoint.
+	Requests int `json:"requests"`
+	// Failures is the number of requests that failed.
+	Failures int `json:"failures"`
+	// LastError is the error of the latest failed request.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// endpoint tracks the health of a JSON-RPC endpoint, it is guarded by the lock of the client.
+type endpoint struct {
+	url         string
+	priority    int
+	latency     time.Duration
+	errorRate   float64
+	headBlock   int
+	quarantined bool
+	requests    int
+	failures    int
+	lastError   string
+}
+
+// healthy reports whether the endpoint should be tried ahead of the others.
+func (e *endpoint) healthy() bool {
+	return !e.quarantined && e.errorRate <= unhealthyErrorRate
+}
+
+// recordSuccess updates the moving averages with a successful request.
+func (e *endpoint) recordSuccess(latency time.Duration) {
+	e.requests++
+	e.errorRate *= healthDecay
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency = time.Duration(healthDecay*float64(e.latency) + (1-healthDecay)*float64(latency))
+	}
+}
+
+// recordFailure updates the moving averages with a failed request.
+func (e *endpoint) recordFailure(err error) {
+	e.requests++
+	e.failures++
+	e.errorRate = healthDecay*e.errorRate + (1 - healthDecay)
+	e.lastError = err.Error()
+}
+
+// health returns the exported view of the endpoint health.
+func (e *endpoint) health() EndpointHealth {
+	return EndpointHealth{
+		URL:         e.url,
+		Priority:    e.priority,
+		Healthy:     e.healthy(),
+		Quarantined: e.quarantined,
+		HeadBlock:   e.headBlock,
+		LatencyMs:   float64(e.latency) / float64(time.Millisecond),
+		ErrorRate:   e.errorRate,
+		Requests:    e.requests,
+		Failures:    e.failures,
+		LastError:   e.lastError,
+	}
+}
+
+// orderEndpoints sorts the endpoints in the order they are tried: healthy endpoints first, then by priority, then
+// by error rate and latency between endpoints of the same priority.
+func orderEndpoints(endpoints []*endpoint) {
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		a, b := endpoints[i], endpoints[j]
+		if a.healthy() != b.healthy() {
+			return a.healthy()
+		}
+		if a.quarantined != b.quarantined {
+			return !a.quarantined
+		}
+		if a.priority != b.priority {
+			return a.priority < b.priority
+		}
+		if a.errorRate != b.errorRate {
+			return a.errorRate < b.errorRate
+		}
+		return a.latency < b.latency
+	})
+}
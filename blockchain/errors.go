@@ -0,0 +1,62 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// rateLimitCode is the JSON-RPC error code the providers reply with once a rate limit is exceeded.
+const rateLimitCode = -32005
+
+// retryableMessages are fragments of the messages of the JSON-RPC errors specific to the endpoint that replied, such as
+// a rate limit or a block or a state the endpoint doesn't hold yet, which another endpoint may not fail with.
+var retryableMessages = []string{"rate limit", "limit exceeded", "too many requests", "header not found", "missing trie node"}
+
+// ErrBlockNotFound is returned when the network doesn't know the requested block yet.
+var ErrBlockNotFound = errors.New("block not found")
+
+// RPCError is an error object returned by the JSON-RPC endpoint, such as a rate limit or an invalid request.
+type RPCError struct {
+	// Code is the JSON-RPC error code.
+	Code int `json:"code"`
+	// Message is the short description of the error.
+	Message string `json:"message"`
+	// Data holds additional details on the error, if any.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	if len(e.Data) > 0 {
+		return fmt.Sprintf("json-rpc error %d: %s (%s)", e.Code, e.Message, e.Data)
+	}
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// retryable reports whether the error is specific to the endpoint that replied, the request is then sent to the next
+// endpoint.
+func (e *RPCError) retryable() bool {
+	if e.Code == rateLimitCode {
+		return true
+	}
+	message := strings.ToLower(e.Message)
+	for _, fragment := range retryableMessages {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPStatusError is returned when the JSON-RPC endpoint replies with a non successful HTTP status.
+type HTTPStatusError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Body is the beginning of the response body.
+	Body string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("http status %d: %s", e.StatusCode, e.Body)
+}
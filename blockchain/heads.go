@@ -0,0 +1,138 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mo-mohamed/txparser/websocket"
+)
+
+const (
+	// defaultReconnectBackoff is the delay before reconnecting a dropped subscription.
+	defaultReconnectBackoff = time.Second
+	// defaultMaxReconnectBackoff caps the delay between reconnections, which doubles after every failed attempt.
+	defaultMaxReconnectBackoff = time.Minute
+	// headTimeout is the time without any new head after which the subscription is considered stale and reconnected.
+	headTimeout = time.Minute
+	// subscribeTimeout bounds the time spent connecting and subscribing.
+	subscribeTimeout = 10 * time.Second
+)
+
+// HeadSource subscribes to the new blocks of the network with "eth_subscribe" over a WebSocket connection.
+type HeadSource struct {
+	// wsEndpoint is the WebSocket JSON-RPC endpoint of the network
+	wsEndpoint string
+	// backoff is the delay before the first reconnection attempt
+	backoff time.Duration
+	// maxBackoff caps the delay between reconnection attempts
+	maxBackoff time.Duration
+}
+
+// headNotification is the "eth_subscription" notification of a new head.
+type headNotification struct {
+	Method string `json:"method"`
+	Params struct {
+		Subscription string `json:"subscription"`
+		Result       struct {
+			Number string `json:"number"`
+		} `json:"result"`
+	} `json:"params"`
+}
+
+// NewHeadSource returns a source of the new blocks announced by the WebSocket endpoint.
+func NewHeadSource(wsEndpoint string) *HeadSource {
+	return &HeadSource{
+		wsEndpoint: wsEndpoint,
+		backoff:    defaultReconnectBackoff,
+		maxBackoff: defaultMaxReconnectBackoff,
+	}
+}
+
+// SubscribeHeads delivers the number of every new block on the returned channel until the context is canceled.
+// A dropped subscription is reconnected with an exponential backoff. The channel only holds the latest head, a slow
+// reader skips the heads it didn't read in time.
+func (h *HeadSource) SubscribeHeads(ctx context.Context) <-chan int {
+	heads := make(chan int, 1)
+	go func() {
+		defer close(heads)
+		backoff := h.backoff
+		for ctx.Err() == nil {
+			subscribed, err := h.subscribe(ctx, heads)
+			if ctx.Err() != nil {
+				return
+			}
+			if subscribed {
+				backoff = h.backoff
+			}
+			log.Printf("Head subscription dropped, reconnecting in %s: %s\n", backoff, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, h.maxBackoff)
+		}
+	}()
+	return heads
+}
+
+// subscribe connects to the endpoint and delivers the new heads until the connection drops. It reports whether the
+// subscription was established, along with the error that ended it.
+func (h *HeadSource) subscribe(ctx context.Context, heads chan int) (bool, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, subscribeTimeout)
+	defer cancel()
+	conn, err := websocket.Dial(dialCtx, h.wsEndpoint)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Closing the connection unblocks the pending read once the context is canceled.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	request, err := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: "eth_subscribe", Params: []interface{}{"newHeads"}, ID: "1"})
+	if err != nil {
+		return false, err
+	}
+	if err := conn.WriteMessage(request); err != nil {
+		return false, err
+	}
+	conn.SetReadDeadline(time.Now().Add(subscribeTimeout))
+	message, err := conn.ReadMessage()
+	if err != nil {
+		return false, err
+	}
+	var subscription string
+	if err := decodeResponse(message, &subscription); err != nil {
+		return false, fmt.Errorf("error subscribing to new heads: %w", err)
+	}
+	log.Println("Subscribed to new heads on", h.wsEndpoint)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(headTimeout))
+		message, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		var notification headNotification
+		if err := json.Unmarshal(message, &notification); err != nil || notification.Method != "eth_subscription" ||
+			notification.Params.Subscription != subscription {
+			continue
+		}
+		number, err := parseQuantity(notification.Params.Result.Number)
+		if err != nil {
+			log.Println(err.Error())
+			continue
+		}
+
+		// The unread head is replaced by the newer one.
+		select {
+		case <-heads:
+		default:
+		}
+		heads <- number
+	}
+}
@@ -1,12 +1,56 @@
 package blockchain
 
-import store "github.com/mo-mohamed/txparser/storage"
+import (
+	"context"
+
+	store "github.com/mo-mohamed/txparser/storage"
+)
 
 // IBlockchain defines the interface for interacting with a blockchain network.
+// Failures are reported as errors, JSON-RPC error objects as *RPCError and unsuccessful HTTP responses as *HTTPStatusError.
 type IBlockchain interface {
-	// ParseBlock fetches and extracts transactions from the specified block number.
-	ParseBlock(block int) ([]store.Transaction, error)
+	// ParseBlock fetches the header and extracts transactions from the specified block number.
+	// It returns ErrBlockNotFound when the network doesn't know the block yet.
+	ParseBlock(ctx context.Context, block int) (store.Block, error)
+
+	// ParseBlocks fetches several blocks in as few round trips as possible, reporting the failure of each block
+	// at the same index in the returned errors.
+	ParseBlocks(ctx context.Context, blocks []int) ([]store.Block, []error)
 
 	// LatestNetworkBlock retrieves the number of the latest block available on the blockchain network.
-	LatestNetworkBlock() int
+	LatestNetworkBlock(ctx context.Context) (int, error)
+
+	// BlockNumberByTag retrieves the number of the block labeled with the given tag, such as "finalized" or "safe".
+	BlockNumberByTag(ctx context.Context, tag string) (int, error)
+}
+
+// IHeadSource notifies the new blocks added to the network as they arrive.
+type IHeadSource interface {
+	// SubscribeHeads delivers the number of every new block on the returned channel, which is closed once the
+	// context is canceled.
+	SubscribeHeads(ctx context.Context) <-chan int
+}
+
+// IMempool lists the transactions waiting to be mined.
+type IMempool interface {
+	// PendingTransactions retrieves the transactions of the mempool that are ready to be mined.
+	PendingTransactions(ctx context.Context) ([]store.Transaction, error)
+}
+
+// IAccountHistory reads the state of accounts at past blocks, which requires an archive node for the old blocks.
+type IAccountHistory interface {
+	// TransactionCount retrieves the number of transactions sent by the address up to the given block.
+	TransactionCount(ctx context.Context, address store.Address, block int) (int, error)
+
+	// Balance retrieves the ether balance of the address at the given block, in wei.
+	Balance(ctx context.Context, address store.Address, block int) (*store.Quantity, error)
+}
+
+// IHealthReporter is implemented by the blockchain clients tracking the health of their JSON-RPC endpoints.
+type IHealthReporter interface {
+	// EndpointHealth reports the health of the endpoints, in the order they are tried.
+	EndpointHealth() []EndpointHealth
+
+	// RunHealthChecks checks the health of every endpoint periodically, until the context is canceled.
+	RunHealthChecks(ctx context.Context)
 }
@@ -0,0 +1,321 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+const (
+	// errMethodNotFound is the JSON-RPC error code of a method the endpoint doesn't implement.
+	errMethodNotFound = -32601
+	// transferTopic is the topic of the Transfer(address,address,uint256) event, the Keccak-256 hash of its signature.
+	transferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	// transferSingleTopic is the topic of the TransferSingle(address,address,address,uint256,uint256) event.
+	transferSingleTopic = "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62"
+	// transferBatchTopic is the topic of the TransferBatch(address,address,address,uint256[],uint256[]) event.
+	transferBatchTopic = "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb"
+	// maxDataInt bounds the offsets and lengths decoded from the log data.
+	maxDataInt = 1 << 20
+)
+
+// receiptData is the receipt of a transaction returned by the network.
+type receiptData struct {
+	TransactionHash   string        `json:"transactionHash"`
+	Status            string        `json:"status"`
+	GasUsed           string        `json:"gasUsed"`
+	EffectiveGasPrice string        `json:"effectiveGasPrice"`
+	ContractAddress   store.Address `json:"contractAddress"`
+	Logs              []logData     `json:"logs"`
+}
+
+// logData is an event logged by a transaction.
+type logData struct {
+	Address  store.Address `json:"address"`
+	Topics   []string      `json:"topics"`
+	Data     string        `json:"data"`
+	LogIndex string        `json:"logIndex"`
+}
+
+// attachReceipts fetches the receipts of the transactions of the blocks and enriches the transactions with them,
+// the failure to fetch the receipts of a block is reported at the same index in the returned errors.
+// The receipts of a whole block are fetched with "eth_getBlockReceipts", falling back to a "eth_getTransactionReceipt"
+// call per transaction on endpoints that don't support it.
+func (b *Blockchain) attachReceipts(ctx context.Context, blocks []*store.Block) []error {
+	errs := make([]error, len(blocks))
+	var pending []int
+	for i, block := range blocks {
+		if len(block.Transactions) > 0 {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return errs
+	}
+
+	if !b.blockReceiptsUnsupported.Load() {
+		receipts := make([][]receiptData, len(pending))
+		calls := make([]*rpcCall, len(pending))
+		for i, index := range pending {
+			calls[i] = &rpcCall{
+				method: "eth_getBlockReceipts",
+				params: []interface{}{fmt.Sprintf("0x%x", blocks[index].Number)},
+				result: &receipts[i],
+			}
+		}
+		b.batchRPCRequest(ctx, calls)
+
+		if !methodNotFound(calls[0].err) {
+			for i, index := range pending {
+				if calls[i].err == nil {
+					calls[i].err = applyReceipts(blocks[index], receipts[i])
+				}
+				errs[index] = calls[i].err
+			}
+			return errs
+		}
+		b.blockReceiptsUnsupported.Store(true)
+	}
+
+	// Every transaction receipt is fetched in the same batch requests.
+	var calls []*rpcCall
+	receipts := make([][]receiptData, len(pending))
+	for i, index := range pending {
+		receipts[i] = make([]receiptData, len(blocks[index].Transactions))
+		for j, tx := range blocks[index].Transactions {
+			calls = append(calls, &rpcCall{
+				method: "eth_getTransactionReceipt",
+				params: []interface{}{tx.Hash},
+				result: &receipts[i][j],
+			})
+		}
+	}
+	b.batchRPCRequest(ctx, calls)
+
+	for i, index := range pending {
+		for range blocks[index].Transactions {
+			if errs[index] == nil && calls[0].err != nil {
+				errs[index] = calls[0].err
+			}
+			calls = calls[1:]
+		}
+		if errs[index] == nil {
+			errs[index] = applyReceipts(blocks[index], receipts[i])
+		}
+	}
+	return errs
+}
+
+// applyReceipts enriches the transactions of the block with their receipts, and decodes the token transfers they logged.
+func applyReceipts(block *store.Block, receipts []receiptData) error {
+	byHash := make(map[string]receiptData, len(receipts))
+	for _, receipt := range receipts {
+		byHash[strings.ToLower(receipt.TransactionHash)] = receipt
+	}
+
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		receipt, exists := byHash[strings.ToLower(tx.Hash)]
+		if !exists {
+			return fmt.Errorf("missing receipt of transaction %s", tx.Hash)
+		}
+		switch receipt.Status {
+		case "0x1":
+			tx.ExecutionStatus = store.ExecutionSucceeded
+		case "0x0":
+			tx.ExecutionStatus = store.ExecutionReverted
+		}
+		tx.GasUsed = receipt.GasUsed
+		tx.EffectiveGasPrice = receipt.EffectiveGasPrice
+		tx.ContractAddress = receipt.ContractAddress
+
+		// Older clients and some providers omit the gas used or the effective gas price, the fee is left empty then.
+		if fee, err := multiplyQuantities(receipt.GasUsed, receipt.EffectiveGasPrice); err == nil {
+			tx.Fee = fee
+		}
+
+		for _, log := range receipt.Logs {
+			for _, transfer := range decodeTokenTransfers(log) {
+				transfer.TransactionHash = tx.Hash
+				transfer.BlockNumber = fmt.Sprintf("0x%x", block.Number)
+				block.TokenTransfers = append(block.TokenTransfers, transfer)
+			}
+		}
+	}
+	return nil
+}
+
+// decodeTokenTransfers decodes the token transfers of an event, none when the log is not a transfer event.
+// ERC-20 and ERC-721 contracts both log Transfer events, ERC-20 transfers carry the amount in the data while ERC-721
+// transfers index the token id. ERC-1155 contracts log TransferSingle and TransferBatch events.
+// Any contract can log events, malformed transfer events are ignored.
+func decodeTokenTransfers(log logData) []store.TokenTransfer {
+	if len(log.Topics) == 0 {
+		return nil
+	}
+	topic := strings.ToLower(log.Topics[0])
+	if topic != transferTopic && topic != transferSingleTopic && topic != transferBatchTopic {
+		return nil
+	}
+	words, err := dataWords(log.Data)
+	if err != nil {
+		return nil
+	}
+	logIndex, err := parseQuantity(log.LogIndex)
+	if err != nil {
+		return nil
+	}
+
+	switch {
+	case topic == transferTopic && len(log.Topics) == 3 && len(words) == 1:
+		return []store.TokenTransfer{{
+			LogIndex: logIndex,
+			Standard: store.StandardERC20,
+			Token:    log.Address,
+			From:     topicAddress(log.Topics[1]),
+			To:       topicAddress(log.Topics[2]),
+			Amount:   quantity(words[0]),
+		}}
+	case topic == transferTopic && len(log.Topics) == 4 && len(words) == 0:
+		return []store.TokenTransfer{{
+			LogIndex: logIndex,
+			Standard: store.StandardERC721,
+			Token:    log.Address,
+			From:     topicAddress(log.Topics[1]),
+			To:       topicAddress(log.Topics[2]),
+			TokenID:  quantity(log.Topics[3]),
+			Amount:   "0x1",
+		}}
+	case topic == transferSingleTopic && len(log.Topics) == 4 && len(words) == 2:
+		return []store.TokenTransfer{{
+			LogIndex: logIndex,
+			Standard: store.StandardERC1155,
+			Token:    log.Address,
+			Operator: topicAddress(log.Topics[1]),
+			From:     topicAddress(log.Topics[2]),
+			To:       topicAddress(log.Topics[3]),
+			TokenID:  quantity(words[0]),
+			Amount:   quantity(words[1]),
+		}}
+	case topic == transferBatchTopic && len(log.Topics) == 4:
+		ids, idsErr := dataArray(words, 0)
+		amounts, amountsErr := dataArray(words, 1)
+		if idsErr != nil || amountsErr != nil || len(ids) != len(amounts) {
+			return nil
+		}
+		transfers := make([]store.TokenTransfer, len(ids))
+		for i := range ids {
+			transfers[i] = store.TokenTransfer{
+				LogIndex:   logIndex,
+				BatchIndex: i,
+				Standard:   store.StandardERC1155,
+				Token:      log.Address,
+				Operator:   topicAddress(log.Topics[1]),
+				From:       topicAddress(log.Topics[2]),
+				To:         topicAddress(log.Topics[3]),
+				TokenID:    quantity(ids[i]),
+				Amount:     quantity(amounts[i]),
+			}
+		}
+		return transfers
+	}
+	// Transfer events of other shapes are not token transfers of a known standard.
+	return nil
+}
+
+// dataWords splits the data of a log into its 32 bytes words, hex encoded.
+func dataWords(data string) ([]string, error) {
+	digits := strings.TrimPrefix(data, "0x")
+	if len(digits)%64 != 0 {
+		return nil, fmt.Errorf("invalid log data length %d", len(digits))
+	}
+	words := make([]string, len(digits)/64)
+	for i := range words {
+		words[i] = digits[i*64 : (i+1)*64]
+	}
+	return words, nil
+}
+
+// dataArray decodes the dynamic array of words the head word at the given position points to.
+func dataArray(words []string, position int) ([]string, error) {
+	if position >= len(words) {
+		return nil, errors.New("truncated log data")
+	}
+	offset, err := wordInt(words[position])
+	if err != nil || offset%32 != 0 || offset/32 >= len(words) {
+		return nil, fmt.Errorf("invalid array offset in log data")
+	}
+	start := offset / 32
+	length, err := wordInt(words[start])
+	if err != nil || length > len(words)-start-1 {
+		return nil, fmt.Errorf("invalid array length in log data")
+	}
+	return words[start+1 : start+1+length], nil
+}
+
+// wordInt decodes a word holding a small integer, such as an offset or a length.
+func wordInt(word string) (int, error) {
+	value, ok := new(big.Int).SetString(word, 16)
+	if !ok || !value.IsInt64() || value.Int64() > maxDataInt {
+		return 0, fmt.Errorf("invalid integer %q", word)
+	}
+	return int(value.Int64()), nil
+}
+
+// quantity converts a hex encoded word into a quantity, stripping its leading zeros.
+func quantity(word string) string {
+	value, _ := new(big.Int).SetString(strings.TrimPrefix(word, "0x"), 16)
+	if value == nil {
+		return "0x0"
+	}
+	return "0x" + value.Text(16)
+}
+
+// topicAddress decodes an address indexed in a topic, where it is left padded to 32 bytes.
+func topicAddress(topic string) store.Address {
+	digits := strings.ToLower(strings.TrimPrefix(topic, "0x"))
+	if len(digits) > 40 {
+		digits = digits[len(digits)-40:]
+	}
+	return store.Address("0x" + digits)
+}
+
+// multiplyQuantities multiplies two hex encoded quantities, which may exceed 64 bits.
+func multiplyQuantities(a string, b string) (string, error) {
+	x, ok := new(big.Int).SetString(strings.TrimPrefix(a, "0x"), 16)
+	if !ok {
+		return "", fmt.Errorf("invalid quantity %q", a)
+	}
+	y, ok := new(big.Int).SetString(strings.TrimPrefix(b, "0x"), 16)
+	if !ok {
+		return "", fmt.Errorf("invalid quantity %q", b)
+	}
+	return "0x" + x.Mul(x, y).Text(16), nil
+}
+
+// methodNotFoundMessages are the messages of the providers reporting a method they don't implement with another
+// error code than errMethodNotFound.
+var methodNotFoundMessages = []string{"method not found", "method not supported", "unsupported method", "does not exist/is not available"}
+
+// methodNotFound reports whether the error tells the endpoint doesn't implement the method. Other errors mentioning
+// the method, such as rate limits or a temporarily disabled method, don't tell it.
+func methodNotFound(err error) bool {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	if rpcErr.Code == errMethodNotFound {
+		return true
+	}
+	message := strings.ToLower(rpcErr.Message)
+	for _, known := range methodNotFoundMessages {
+		if strings.Contains(message, known) {
+			return true
+		}
+	}
+	return false
+}
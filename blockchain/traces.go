@@ -0,0 +1,225 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+// callFrame is a call traced by the callTracer of "debug_traceBlockByNumber", along with the calls it made.
+type callFrame struct {
+	Type  string        `json:"type"`
+	From  store.Address `json:"from"`
+	To    store.Address `json:"to"`
+	Value string        `json:"value"`
+	Error string        `json:"error"`
+	Calls []callFrame   `json:"calls"`
+}
+
+// transactionTrace is the trace of a transaction returned by "debug_traceBlockByNumber".
+type transactionTrace struct {
+	TxHash string    `json:"txHash"`
+	Result callFrame `json:"result"`
+}
+
+// parityTrace is a call traced by "trace_block", which lists the calls of every transaction of the block flattened.
+type parityTrace struct {
+	Type   string `json:"type"`
+	Action struct {
+		CallType      string        `json:"callType"`
+		From          store.Address `json:"from"`
+		To            store.Address `json:"to"`
+		Value         string        `json:"value"`
+		Address       store.Address `json:"address"`
+		RefundAddress store.Address `json:"refundAddress"`
+		Balance       string        `json:"balance"`
+	} `json:"action"`
+	Result *struct {
+		Address store.Address `json:"address"`
+	} `json:"result"`
+	Error           string `json:"error"`
+	TraceAddress    []int  `json:"traceAddress"`
+	TransactionHash string `json:"transactionHash"`
+}
+
+// attachTraces traces the transactions of the blocks and extracts the internal transactions moving ether, the failure
+// to trace a block is reported at the same index in the returned errors. Blocks are traced with the callTracer of
+// "debug_traceBlockByNumber", falling back to "trace_block" on endpoints that don't support it.
+func (b *Blockchain) attachTraces(ctx context.Context, blocks []*store.Block) []error {
+	errs := make([]error, len(blocks))
+	var pending []int
+	for i, block := range blocks {
+		if len(block.Transactions) > 0 {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return errs
+	}
+
+	if !b.debugTraceUnsupported.Load() {
+		traces := make([][]transactionTrace, len(pending))
+		calls := make([]*rpcCall, len(pending))
+		for i, index := range pending {
+			calls[i] = &rpcCall{
+				method: "debug_traceBlockByNumber",
+				params: []interface{}{fmt.Sprintf("0x%x", blocks[index].Number), map[string]string{"tracer": "callTracer"}},
+				result: &traces[i],
+			}
+		}
+		b.batchRPCRequest(ctx, calls)
+
+		if !methodNotFound(calls[0].err) {
+			for i, index := range pending {
+				if calls[i].err == nil {
+					calls[i].err = applyCallTraces(blocks[index], traces[i])
+				}
+				errs[index] = calls[i].err
+			}
+			return errs
+		}
+		b.debugTraceUnsupported.Store(true)
+	}
+
+	traces := make([][]parityTrace, len(pending))
+	calls := make([]*rpcCall, len(pending))
+	for i, index := range pending {
+		calls[i] = &rpcCall{
+			method: "trace_block",
+			params: []interface{}{fmt.Sprintf("0x%x", blocks[index].Number)},
+			result: &traces[i],
+		}
+	}
+	b.batchRPCRequest(ctx, calls)
+
+	for i, index := range pending {
+		if calls[i].err == nil {
+			applyParityTraces(blocks[index], traces[i])
+		}
+		errs[index] = calls[i].err
+	}
+	return errs
+}
+
+// applyCallTraces flattens the calls of the transaction traces into the internal transactions of the block.
+// The traces are listed in the order of the transactions, older nodes omit the transaction hash.
+func applyCallTraces(block *store.Block, traces []transactionTrace) error {
+	if len(traces) != len(block.Transactions) {
+		return fmt.Errorf("got %d traces for %d transactions", len(traces), len(block.Transactions))
+	}
+	for i, trace := range traces {
+		txHash := trace.TxHash
+		if txHash == "" {
+			txHash = block.Transactions[i].Hash
+		}
+		// The top level call is the transaction itself.
+		if trace.Result.Error == "" {
+			flattenCalls(block, txHash, nil, trace.Result.Calls)
+		}
+	}
+	return nil
+}
+
+// flattenCalls appends the calls moving ether to the internal transactions of the block, along with the calls they
+// made. The calls of a reverted call are reverted along with it.
+func flattenCalls(block *store.Block, txHash string, path []int, calls []callFrame) {
+	for i, call := range calls {
+		if call.Error != "" {
+			continue
+		}
+		callPath := append(append([]int(nil), path...), i)
+		callType := strings.ToLower(call.Type)
+		if movesEther(callType, call.Value) {
+			block.InternalTransactions = append(block.InternalTransactions, store.InternalTransaction{
+				TransactionHash: txHash,
+				TraceAddress:    callPath,
+				BlockNumber:     fmt.Sprintf("0x%x", block.Number),
+				Type:            callType,
+				From:            call.From,
+				To:              call.To,
+				Value:           quantity(call.Value),
+			})
+		}
+		flattenCalls(block, txHash, callPath, call.Calls)
+	}
+}
+
+// applyParityTraces extracts the internal transactions of the block from its flattened traces.
+func applyParityTraces(block *store.Block, traces []parityTrace) {
+	// The calls of a reverted call are reverted along with it, a reverted top level call reverts the whole transaction.
+	reverted := make(map[string][][]int)
+	for _, trace := range traces {
+		if trace.Error != "" {
+			reverted[trace.TransactionHash] = append(reverted[trace.TransactionHash], trace.TraceAddress)
+		}
+	}
+
+	for _, trace := range traces {
+		// Block rewards carry no transaction hash, and the top level calls are the transactions themselves.
+		if trace.TransactionHash == "" || len(trace.TraceAddress) == 0 ||
+			revertedCall(reverted[trace.TransactionHash], trace.TraceAddress) {
+			continue
+		}
+		call := store.InternalTransaction{
+			TransactionHash: trace.TransactionHash,
+			TraceAddress:    trace.TraceAddress,
+			BlockNumber:     fmt.Sprintf("0x%x", block.Number),
+			From:            trace.Action.From,
+			To:              trace.Action.To,
+			Value:           trace.Action.Value,
+		}
+		switch trace.Type {
+		case "call":
+			call.Type = strings.ToLower(trace.Action.CallType)
+		case "create":
+			call.Type = "create"
+			if trace.Result != nil {
+				call.To = trace.Result.Address
+			}
+		case "suicide":
+			call.Type = "selfdestruct"
+			call.From = trace.Action.Address
+			call.To = trace.Action.RefundAddress
+			call.Value = trace.Action.Balance
+		}
+		if movesEther(call.Type, call.Value) {
+			call.Value = quantity(call.Value)
+			block.InternalTransactions = append(block.InternalTransactions, call)
+		}
+	}
+}
+
+// revertedCall reports whether the call is one of the reverted calls of its transaction, or was made under one of them.
+func revertedCall(reverted [][]int, traceAddress []int) bool {
+	for _, path := range reverted {
+		if len(path) <= len(traceAddress) && isPrefix(path, traceAddress) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrefix reports whether the call path starts with the prefix.
+func isPrefix(prefix []int, path []int) bool {
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// movesEther reports whether a call of the given type transfers a non-zero value. Delegate and static calls run in
+// the context of the caller and move no ether.
+func movesEther(callType string, value string) bool {
+	switch callType {
+	case "call", "create", "create2", "selfdestruct":
+	default:
+		return false
+	}
+	amount, ok := new(big.Int).SetString(strings.TrimPrefix(value, "0x"), 16)
+	return ok && amount.Sign() > 0
+}
@@ -0,0 +1,94 @@
+/*
+Package keccak implements the Keccak-256 hash function used by Ethereum, which predates and differs from the
+standardized SHA3-256 by its padding.
+*/
+package keccak
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// rate is the number of bytes absorbed per permutation for a 256 bits output.
+const rate = 136
+
+// roundConstants are the constants xored into the state by the iota step of each round.
+var roundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// rotations are the rotation offsets of the rho step, indexed by lane.
+var rotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// Sum256 returns the Keccak-256 digest of the data.
+func Sum256(data []byte) [32]byte {
+	var state [25]uint64
+
+	// Pad with the Keccak domain byte and the final bit, then absorb the data a block at a time.
+	padded := make([]byte, len(data), len(data)+rate)
+	copy(padded, data)
+	padded = append(padded, 0x01)
+	for len(padded)%rate != 0 {
+		padded = append(padded, 0x00)
+	}
+	padded[len(padded)-1] |= 0x80
+
+	for block := padded; len(block) > 0; block = block[rate:] {
+		for i := 0; i < rate/8; i++ {
+			state[i] ^= binary.LittleEndian.Uint64(block[i*8:])
+		}
+		permute(&state)
+	}
+
+	var digest [32]byte
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(digest[i*8:], state[i])
+	}
+	return digest
+}
+
+// permute applies the Keccak-f[1600] permutation to the state.
+func permute(state *[25]uint64) {
+	for round := 0; round < 24; round++ {
+		// theta
+		var columns [5]uint64
+		for x := 0; x < 5; x++ {
+			columns[x] = state[x] ^ state[x+5] ^ state[x+10] ^ state[x+15] ^ state[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := columns[(x+4)%5] ^ bits.RotateLeft64(columns[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				state[x+y] ^= d
+			}
+		}
+
+		// rho and pi
+		var moved [25]uint64
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				moved[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(state[x+5*y], rotations[x+5*y])
+			}
+		}
+
+		// chi
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				state[x+y] = moved[x+y] ^ (^moved[(x+1)%5+y] & moved[(x+2)%5+y])
+			}
+		}
+
+		// iota
+		state[0] ^= roundConstants[round]
+	}
+}
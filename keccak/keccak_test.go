@@ -0,0 +1,22 @@
+package keccak_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/mo-mohamed/txparser/keccak"
+)
+
+func TestSum256(t *testing.T) {
+	cases := map[string]string{
+		"": "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+		"The quick brown fox jumps over the lazy dog": "4d741b6f1eb29cb2a9b9911c82f56fa8d73b04959d3d9d222895df6c0b28aa15",
+		"Transfer(address,address,uint256)":           "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+	}
+	for input, expected := range cases {
+		digest := keccak.Sum256([]byte(input))
+		if got := hex.EncodeToString(digest[:]); got != expected {
+			t.Errorf("Expected digest of %q to be %s, got %s", input, expected, got)
+		}
+	}
+}
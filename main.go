@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,9 +19,63 @@ import (
 )
 
 func main() {
-	store := store.NewMemoryStore()
-	blockchain := blockchain.NewBlockchain("https://ethereum-rpc.publicnode.com")
-	p := parser.NewTxParser(store, blockchain)
+	rpcEndpoints := flag.String("rpc-endpoints", "https://ethereum-rpc.publicnode.com", "comma separated JSON-RPC endpoints, in order of preference")
+	wsEndpoint := flag.String("ws-endpoint", "wss://ethereum-rpc.publicnode.com", "WebSocket JSON-RPC endpoint announcing new blocks, empty to only poll")
+	watchMempool := flag.Bool("mempool", false, "watch the mempool for pending transactions, the endpoints must support txpool_content")
+	internalTransactions := flag.Bool("internal-transactions", false, "trace the blocks to track the ether moved by contracts, the endpoints must support tracing")
+	maxHeadLag := flag.Int("max-head-lag", 5, "number of blocks an endpoint can lag behind the others before it is quarantined")
+	healthCheckInterval := flag.Duration("health-check-interval", 30*time.Second, "delay between two checks of the head of every endpoint")
+	dataDir := flag.String("data-dir", "data", "directory the parser state is persisted to")
+	maxCatchUp := flag.Int("max-catch-up", 0, "maximum number of missed blocks caught up on startup, 0 for no limit")
+	concurrency := flag.Int("concurrency", 4, "number of blocks fetched at the same time")
+	batchSize := flag.Int("batch-size", parser.DefaultBatchSize, "number of blocks fetched in a single batch request")
+	backfillBatchSize := flag.Int("backfill-batch-size", 0, "number of blocks fetched in a single batch request when backfilling, 0 to use the batch size")
+	maxBackfillBlocks := flag.Int("max-backfill-blocks", 0, "maximum number of blocks a backfill scans, 0 for no limit")
+	maxRPCBatch := flag.Int("max-rpc-batch", 25, "maximum number of calls the RPC provider accepts in a batch request")
+	fetchWindow := flag.Int("fetch-window", 16, "number of blocks fetched ahead of the next block to process")
+	confirmations := flag.Int("confirmations", 12, "number of blocks, including its own, a transaction has to be buried under to be confirmed")
+	finalityTags := flag.Bool("finality-tags", false, "report the transactions of the finalized and safe blocks reported by the network as finalized and confirmed")
+	startFromHead := flag.Bool("start-from-head", false, "ignore the persisted block and start from the latest block on the network")
+	flag.Parse()
+
+	store, err := store.NewFileStore(*dataDir)
+	if err != nil {
+		log.Fatalf("Error opening store: %v\n", err)
+	}
+	endpoints := strings.Split(*rpcEndpoints, ",")
+	blockchainOptions := []blockchain.Option{
+		blockchain.WithMaxBatchSize(*maxRPCBatch),
+		blockchain.WithMaxHeadLag(*maxHeadLag),
+		blockchain.WithHealthCheckInterval(*healthCheckInterval),
+	}
+	if *internalTransactions {
+		blockchainOptions = append(blockchainOptions, blockchain.WithInternalTransactions())
+	}
+	for priority, endpoint := range endpoints[1:] {
+		blockchainOptions = append(blockchainOptions, blockchain.WithEndpoint(strings.TrimSpace(endpoint), priority+1))
+	}
+	options := []parser.Option{
+		parser.WithConfirmations(*confirmations),
+		parser.WithMaxCatchUp(*maxCatchUp),
+		parser.WithConcurrency(*concurrency, *fetchWindow),
+		parser.WithBatchSize(*batchSize),
+		parser.WithBackfillBatchSize(*backfillBatchSize),
+		parser.WithMaxBackfillBlocks(*maxBackfillBlocks),
+	}
+	if *wsEndpoint != "" {
+		options = append(options, parser.WithHeadSource(blockchain.NewHeadSource(*wsEndpoint)))
+	}
+	blockchain := blockchain.NewBlockchain(strings.TrimSpace(endpoints[0]), blockchainOptions...)
+	if *finalityTags {
+		options = append(options, parser.WithFinalityTags())
+	}
+	if *startFromHead {
+		options = append(options, parser.WithStartFromHead())
+	}
+	if *watchMempool {
+		options = append(options, parser.WithMempool(blockchain, 0, 0))
+	}
+	p := parser.NewTxParser(store, blockchain, options...)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -31,11 +88,17 @@ func main() {
 	}()
 
 	// Start the background polling worker
-	go p.StartPolling(ctx)
+	pollingDone := make(chan struct{})
+	go func() {
+		p.StartPolling(ctx)
+		close(pollingDone)
+	}()
 
 	server := &http.Server{
 		Addr:    ":8080",
 		Handler: api.Router(p),
+		// The requests are canceled on shutdown, which ends the open streams.
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
 
 	go func() {
@@ -58,5 +121,11 @@ func main() {
 		log.Println("HTTP server stopped.")
 	}
 
+	// Wait for the polling worker and its backfill, webhook and mempool workers to stop writing before closing the store
+	<-pollingDone
+	if err := store.Close(); err != nil {
+		log.Printf("Store close error: %v\n", err)
+	}
+
 	log.Println("Server stopped.")
 }
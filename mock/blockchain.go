@@ -1,18 +1,72 @@
 package mock
 
 import (
+	"context"
+
+	"github.com/mo-mohamed/txparser/blockchain"
 	store "github.com/mo-mohamed/txparser/storage"
 )
 
 type BlockchainMock struct {
-	ParseBlockFunc         func(block int) ([]store.Transaction, error)
-	LatestNetworkBlockFunc func() int
+	ParseBlockFunc          func(ctx context.Context, block int) (store.Block, error)
+	ParseBlocksFunc         func(ctx context.Context, blocks []int) ([]store.Block, []error)
+	LatestNetworkBlockFunc  func(ctx context.Context) (int, error)
+	BlockNumberByTagFunc    func(ctx context.Context, tag string) (int, error)
+	EndpointHealthFunc      func() []blockchain.EndpointHealth
+	RunHealthChecksFunc     func(ctx context.Context)
+	PendingTransactionsFunc func(ctx context.Context) ([]store.Transaction, error)
+	TransactionCountFunc    func(ctx context.Context, address store.Address, block int) (int, error)
+	BalanceFunc             func(ctx context.Context, address store.Address, block int) (*store.Quantity, error)
+}
+
+func (b *BlockchainMock) ParseBlock(ctx context.Context, block int) (store.Block, error) {
+	return b.ParseBlockFunc(ctx, block)
+}
+
+// ParseBlocks falls back to fetching the blocks one at a time with ParseBlockFunc when ParseBlocksFunc isn't set.
+func (b *BlockchainMock) ParseBlocks(ctx context.Context, blocks []int) ([]store.Block, []error) {
+	if b.ParseBlocksFunc != nil {
+		return b.ParseBlocksFunc(ctx, blocks)
+	}
+	parsed := make([]store.Block, len(blocks))
+	errs := make([]error, len(blocks))
+	for i, block := range blocks {
+		parsed[i], errs[i] = b.ParseBlockFunc(ctx, block)
+	}
+	return parsed, errs
+}
+
+func (b *BlockchainMock) LatestNetworkBlock(ctx context.Context) (int, error) {
+	return b.LatestNetworkBlockFunc(ctx)
+}
+
+func (b *BlockchainMock) BlockNumberByTag(ctx context.Context, tag string) (int, error) {
+	return b.BlockNumberByTagFunc(ctx, tag)
+}
+
+func (b *BlockchainMock) PendingTransactions(ctx context.Context) ([]store.Transaction, error) {
+	return b.PendingTransactionsFunc(ctx)
+}
+
+func (b *BlockchainMock) TransactionCount(ctx context.Context, address store.Address, block int) (int, error) {
+	return b.TransactionCountFunc(ctx, address, block)
+}
+
+func (b *BlockchainMock) Balance(ctx context.Context, address store.Address, block int) (*store.Quantity, error) {
+	return b.BalanceFunc(ctx, address, block)
 }
 
-func (b *BlockchainMock) ParseBlock(block int) ([]store.Transaction, error) {
-	return b.ParseBlockFunc(block)
+// EndpointHealth reports no endpoint when EndpointHealthFunc isn't set.
+func (b *BlockchainMock) EndpointHealth() []blockchain.EndpointHealth {
+	if b.EndpointHealthFunc == nil {
+		return nil
+	}
+	return b.EndpointHealthFunc()
 }
 
-func (b *BlockchainMock) LatestNetworkBlock() int {
-	return b.LatestNetworkBlockFunc()
+// RunHealthChecks returns right away when RunHealthChecksFunc isn't set.
+func (b *BlockchainMock) RunHealthChecks(ctx context.Context) {
+	if b.RunHealthChecksFunc != nil {
+		b.RunHealthChecksFunc(ctx)
+	}
 }
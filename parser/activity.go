@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"sort"
+
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+// ActivityType tells what an activity entry holds.
+type ActivityType string
+
+const (
+	// ActivityTransaction marks a transaction sent or received by the address.
+	ActivityTransaction ActivityType = "transaction"
+	// ActivityWithdrawal marks a beacon chain withdrawal credited to the address.
+	ActivityWithdrawal ActivityType = "withdrawal"
+)
+
+// Activity is an entry of the activity feed of an address, holding either a transaction or a withdrawal.
+type Activity struct {
+	// Type tells whether the entry is a transaction or a withdrawal.
+	Type ActivityType `json:"type"`
+	// BlockNumber is the number of the block of the entry, zero for transactions pending in the mempool.
+	BlockNumber int `json:"blockNumber"`
+	// Status is the finality of the entry at the time it was retrieved.
+	Status store.TransactionStatus `json:"status"`
+	// Transaction is the transaction of a transaction entry.
+	Transaction *store.Transaction `json:"transaction,omitempty"`
+	// Withdrawal is the withdrawal of a withdrawal entry.
+	Withdrawal *store.Withdrawal `json:"withdrawal,omitempty"`
+}
+
+// GetActivity returns the transactions and withdrawals of a subscribed address ordered by block, optionally restricted
+// to the given statuses. The withdrawals of a block are processed after its transactions, and the transactions pending
+// in the mempool come last.
+func (p *TxParser) GetActivity(address store.Address, statuses ...store.TransactionStatus) []Activity {
+	activity := []Activity{}
+	for _, tx := range p.GetTransactions(address, statuses...) {
+		tx := tx
+		activity = append(activity, Activity{
+			Type:        ActivityTransaction,
+			BlockNumber: tx.BlockHeight(),
+			Status:      tx.Status,
+			Transaction: &tx,
+		})
+	}
+	for _, withdrawal := range p.GetWithdrawals(address, statuses...) {
+		withdrawal := withdrawal
+		activity = append(activity, Activity{
+			Type:        ActivityWithdrawal,
+			BlockNumber: withdrawal.BlockHeight(),
+			Status:      withdrawal.Status,
+			Withdrawal:  &withdrawal,
+		})
+	}
+	sort.SliceStable(activity, func(i, j int) bool {
+		a, b := activity[i], activity[j]
+		if (a.Status == store.StatusPending) != (b.Status == store.StatusPending) {
+			return b.Status == store.StatusPending
+		}
+		if a.BlockNumber != b.BlockNumber {
+			return a.BlockNumber < b.BlockNumber
+		}
+		return a.Type == ActivityTransaction && b.Type == ActivityWithdrawal
+	})
+	return activity
+}
@@ -0,0 +1,292 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mo-mohamed/txparser/blockchain"
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+// backfillRetryDelay is the delay before fetching again a historical block that could not be fetched.
+const backfillRetryDelay = 5 * time.Second
+
+// ErrBackfillUnsupported is returned when subscribing an address with a backfill from its first activity while the
+// blockchain client cannot read the state of accounts at past blocks.
+var ErrBackfillUnsupported = errors.New("backfilling from the first activity of an address is not supported by the blockchain client")
+
+// ErrBackfillTooLong is returned when subscribing an address with a backfill starting further back than the maximum
+// number of backfilled blocks.
+var ErrBackfillTooLong = errors.New("the backfill starts further back than the maximum number of backfilled blocks")
+
+// backfiller schedules and runs the backfill jobs one at a time. The jobs are persisted in the store as they progress,
+// so the unfinished ones resume where they stopped after a restart.
+type backfiller struct {
+	// jobs holds every scheduled job, in scheduling order.
+	jobs []*store.BackfillJob
+	// queue holds the jobs waiting for the worker.
+	queue []*store.BackfillJob
+	// wakeup notifies the worker a job was queued.
+	wakeup chan struct{}
+	// mu guards the jobs and their progress.
+	mu sync.Mutex
+}
+
+func newBackfiller() *backfiller {
+	return &backfiller{wakeup: make(chan struct{}, 1)}
+}
+
+// load restores the jobs persisted in the store, queueing again the ones that did not complete.
+func (b *backfiller) load(s store.IStore) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, job := range s.BackfillJobs() {
+		job := job
+		b.jobs = append(b.jobs, &job)
+		if job.Status == store.BackfillQueued || job.Status == store.BackfillRunning {
+			job.Status = store.BackfillQueued
+			b.queue = append(b.queue, &job)
+		}
+	}
+	if len(b.queue) > 0 {
+		b.wakeup <- struct{}{}
+	}
+}
+
+// scheduleBackfill queues a backfill of the transactions of a subscribed address starting from the given block, or
+// from the first block the address was active in when fromGenesis is set.
+func (p *TxParser) scheduleBackfill(address store.Address, fromBlock int, fromGenesis bool) {
+	currentBlock := p.store.CurrentBlock()
+	if fromGenesis {
+		fromBlock = 1
+	}
+	if fromBlock > currentBlock {
+		return
+	}
+
+	job := &store.BackfillJob{
+		Address:      address,
+		FromGenesis:  fromGenesis,
+		ToBlock:      currentBlock,
+		ScannedBlock: fromBlock - 1,
+		Status:       store.BackfillQueued,
+		ScheduledAt:  time.Now().UTC(),
+	}
+	if !fromGenesis {
+		job.FromBlock = fromBlock
+	}
+	p.backfills.mu.Lock()
+	p.backfills.jobs = append(p.backfills.jobs, job)
+	p.backfills.queue = append(p.backfills.queue, job)
+	p.saveBackfill(job)
+	p.backfills.mu.Unlock()
+
+	select {
+	case p.backfills.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// saveBackfill persists the progress of a job. The caller must hold the lock.
+func (p *TxParser) saveBackfill(job *store.BackfillJob) {
+	if err := p.store.SaveBackfillJob(*job); err != nil {
+		log.Println("Error storing backfill job:", err)
+	}
+}
+
+// GetBackfillJobs returns the progress of the backfill jobs, optionally restricted to an address.
+func (p *TxParser) GetBackfillJobs(address store.Address) []store.BackfillJob {
+	p.backfills.mu.Lock()
+	defer p.backfills.mu.Unlock()
+
+	jobs := []store.BackfillJob{}
+	for _, job := range p.backfills.jobs {
+		if address == "" || job.Address == address {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs
+}
+
+// runBackfills runs the queued backfill jobs until the context is canceled.
+func (p *TxParser) runBackfills(ctx context.Context) {
+	for {
+		if job := p.nextBackfill(); job != nil {
+			p.backfill(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.backfills.wakeup:
+		}
+	}
+}
+
+// nextBackfill pops the next queued job, or returns nil when the queue is empty.
+func (p *TxParser) nextBackfill() *store.BackfillJob {
+	p.backfills.mu.Lock()
+	defer p.backfills.mu.Unlock()
+
+	if len(p.backfills.queue) == 0 {
+		return nil
+	}
+	job := p.backfills.queue[0]
+	p.backfills.queue = p.backfills.queue[1:]
+	job.Status = store.BackfillRunning
+	p.saveBackfill(job)
+	return job
+}
+
+// backfill scans the blocks from the start of the job until it catches up with the polled blocks. The range overlaps
+// the blocks polled after the subscription, the store skips the transactions it already holds for the address.
+func (p *TxParser) backfill(ctx context.Context, job *store.BackfillJob) {
+	if job.FromGenesis && job.FromBlock == 0 && !p.locateFirstActivity(ctx, job) {
+		return
+	}
+	log.Printf("Backfilling address %s from block %d\n", job.Address, job.FromBlock)
+	for ctx.Err() == nil {
+		p.backfills.mu.Lock()
+		if _, subscribed := p.store.Subscription(job.Address); !subscribed {
+			job.Status = store.BackfillCanceled
+			p.saveBackfill(job)
+			p.backfills.mu.Unlock()
+			log.Printf("Backfilling address %s canceled\n", job.Address)
+			return
+		}
+		fromBlock := job.ScannedBlock + 1
+		job.ToBlock = p.store.CurrentBlock()
+		if fromBlock > job.ToBlock {
+			job.Status = store.BackfillCompleted
+			p.saveBackfill(job)
+			p.backfills.mu.Unlock()
+			log.Printf("Backfilling address %s completed\n", job.Address)
+			return
+		}
+		batchSize := p.backfillBatchSize
+		if batchSize == 0 {
+			batchSize = p.batchSize
+		}
+		batch := make([]int, min(batchSize, job.ToBlock-fromBlock+1))
+		p.backfills.mu.Unlock()
+
+		for i := range batch {
+			batch[i] = fromBlock + i
+		}
+		blocks, errs := p.blockChain.ParseBlocks(ctx, batch)
+		scanned := 0
+		for i, block := range blocks {
+			// The blocks following a failed block are fetched again after the retry delay.
+			if errs[i] != nil {
+				log.Println(errs[i].Error())
+				waitBackfillRetry(ctx)
+				break
+			}
+			if err := p.store.BackfillTransactions(job.Address, block); err != nil {
+				log.Println("Error storing backfilled block:", err)
+				waitBackfillRetry(ctx)
+				break
+			}
+			p.records.notify()
+			scanned++
+		}
+		if scanned > 0 {
+			p.backfills.mu.Lock()
+			job.ScannedBlock = batch[scanned-1]
+			p.saveBackfill(job)
+			p.backfills.mu.Unlock()
+		}
+	}
+}
+
+// locateFirstActivity sets the start of a job scanning from the first activity of its address, the first block the
+// address has a nonce or a balance in, by bisecting the blocks up to the end of the job. The nonce only grows, so is
+// the balance of an address until it sends a transaction, unless it is a contract: the start is approximate when
+// located from the balance. An address only ever holding tokens is never active this way, its job is then marked
+// unsupported. It returns false when the job is canceled or unsupported, or the context is done before the block is
+// located.
+func (p *TxParser) locateFirstActivity(ctx context.Context, job *store.BackfillJob) bool {
+	history, ok := p.blockChain.(blockchain.IAccountHistory)
+	if !ok {
+		p.backfills.mu.Lock()
+		job.Status = store.BackfillCanceled
+		p.saveBackfill(job)
+		p.backfills.mu.Unlock()
+		log.Printf("Backfilling address %s canceled: %v\n", job.Address, ErrBackfillUnsupported)
+		return false
+	}
+
+	log.Printf("Locating the first activity of address %s\n", job.Address)
+	// The first active block lies in (low, high], high past the end of the job standing for an address never active.
+	low, high := 0, job.ToBlock+1
+	sentAtHigh := false
+	for low+1 < high {
+		if ctx.Err() != nil {
+			return false
+		}
+		if _, subscribed := p.store.Subscription(job.Address); !subscribed {
+			p.backfills.mu.Lock()
+			job.Status = store.BackfillCanceled
+			p.saveBackfill(job)
+			p.backfills.mu.Unlock()
+			log.Printf("Backfilling address %s canceled\n", job.Address)
+			return false
+		}
+		middle := low + (high-low)/2
+		sent, funded, err := accountActivity(ctx, history, job.Address, middle)
+		if err != nil {
+			log.Println("Error locating the first activity:", err)
+			waitBackfillRetry(ctx)
+			continue
+		}
+		if sent || funded {
+			high, sentAtHigh = middle, sent
+		} else {
+			low = middle
+		}
+	}
+
+	p.backfills.mu.Lock()
+	defer p.backfills.mu.Unlock()
+	if high > job.ToBlock {
+		job.Status = store.BackfillUnsupported
+		p.saveBackfill(job)
+		log.Printf("Backfilling address %s stopped: no transaction sent nor ether held\n", job.Address)
+		return false
+	}
+	job.FromBlock = high
+	job.Approximate = !sentAtHigh
+	if p.maxBackfillBlocks > 0 && job.ToBlock-job.FromBlock+1 > p.maxBackfillBlocks {
+		job.FromBlock = job.ToBlock - p.maxBackfillBlocks + 1
+		job.Approximate = true
+	}
+	job.ScannedBlock = job.FromBlock - 1
+	p.saveBackfill(job)
+	return true
+}
+
+// accountActivity tells whether the address sent a transaction, and whether it held ether, at the given block. The
+// balance is only read for an address that sent no transaction yet.
+func accountActivity(ctx context.Context, history blockchain.IAccountHistory, address store.Address, block int) (bool, bool, error) {
+	nonce, err := history.TransactionCount(ctx, address, block)
+	if err != nil || nonce > 0 {
+		return nonce > 0, false, err
+	}
+	balance, err := history.Balance(ctx, address, block)
+	if err != nil {
+		return false, false, err
+	}
+	return false, balance.Sign() > 0, nil
+}
+
+// waitBackfillRetry waits for the retry delay, or until the context is done.
+func waitBackfillRetry(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(backfillRetryDelay):
+	}
+}
@@ -1,15 +1,139 @@
 package parser
 
-import store "github.com/mo-mohamed/txparser/storage"
+import (
+	"context"
+
+	"github.com/mo-mohamed/txparser/blockchain"
+	store "github.com/mo-mohamed/txparser/storage"
+)
 
 // Parser defines an interface for interacting with the blockchain parser.
 type Parser interface {
 	// GetCurrentBlock retrieves the most recently parsed block number from the blockchain.
 	GetCurrentBlock() int
 
-	// Subscribe adds an Ethereum address to the list of monitored addresses for transactions.
-	Subscribe(address string) bool
+	// GetSyncStatus reports how far the parser is behind the latest block on the network.
+	GetSyncStatus() SyncStatus
+
+	// GetStuckBlocks retrieves the blocks that could not be fetched and wait to be retried.
+	GetStuckBlocks() []FailedBlock
+
+	// GetEndpointHealth retrieves the health of the JSON-RPC endpoints of the blockchain client.
+	GetEndpointHealth() []blockchain.EndpointHealth
+
+	// Subscribe adds an Ethereum address to the list of monitored addresses for transactions, it fails when the
+	// subscription cannot be stored.
+	Subscribe(address store.Address) (bool, error)
+
+	// SubscribeWithOptions adds an Ethereum address to the monitored addresses along with the subscription details,
+	// and backfills its transactions when a starting block is given. It fails with ErrBackfillUnsupported when the
+	// backfill starts from the first activity of the address and the blockchain client cannot locate it.
+	SubscribeWithOptions(address store.Address, options SubscribeOptions) (bool, error)
+
+	// Unsubscribe removes an Ethereum address from the monitored addresses, purging its stored transactions when asked to.
+	Unsubscribe(address store.Address, purge bool) (bool, error)
+
+	// GetSubscriptions retrieves the monitored addresses along with their details, optionally restricted to an owner.
+	GetSubscriptions(owner string) []store.Subscription
+
+	// GetBackfillJobs retrieves the progress of the backfill jobs, optionally restricted to an address.
+	GetBackfillJobs(address store.Address) []store.BackfillJob
+
+	// GetTransactions retrieves the list of transactions involving a specified address, optionally restricted to the given statuses.
+	GetTransactions(address store.Address, statuses ...store.TransactionStatus) []store.Transaction
+
+	// GetFullTransactions retrieves the list of transactions involving a specified address along with the complete
+	// transactions, optionally restricted to the given statuses.
+	GetFullTransactions(address store.Address, statuses ...store.TransactionStatus) []store.Transaction
+
+	// QueryTransactions retrieves a page of the transactions of a specified address selected by the query, optionally
+	// restricted to the given statuses.
+	QueryTransactions(query store.TransactionQuery, statuses ...store.TransactionStatus) (store.TransactionPage, error)
+
+	// GetPendingTransactions retrieves the transactions involving a specified address seen in the mempool, either
+	// still pending or recently replaced or dropped.
+	GetPendingTransactions(address store.Address) []PendingTransaction
+
+	// GetTokenTransfers retrieves the list of ERC-20 transfers sent or received by a specified address, optionally
+	// restricted to the given statuses.
+	GetTokenTransfers(address store.Address, statuses ...store.TransactionStatus) []store.TokenTransfer
+
+	// GetNFTTransfers retrieves the list of ERC-721 and ERC-1155 transfers sent or received by a specified address,
+	// optionally restricted to the given statuses.
+	GetNFTTransfers(address store.Address, statuses ...store.TransactionStatus) []store.TokenTransfer
+
+	// GetWithdrawals retrieves the list of beacon chain withdrawals credited to a specified address, optionally
+	// restricted to the given statuses.
+	GetWithdrawals(address store.Address, statuses ...store.TransactionStatus) []store.Withdrawal
+
+	// GetActivity retrieves the transactions and withdrawals of a specified address ordered by block, optionally
+	// restricted to the given statuses.
+	GetActivity(address store.Address, statuses ...store.TransactionStatus) []Activity
+
+	// GetInternalTransactions retrieves the list of internal transactions sending ether to or from a specified address,
+	// optionally restricted to the given statuses.
+	GetInternalTransactions(address store.Address, statuses ...store.TransactionStatus) []store.InternalTransaction
+
+	// GetLatestSequence retrieves the sequence number of the latest recorded transaction.
+	GetLatestSequence() uint64
+
+	// StreamTransactions streams the transactions of the specified addresses recorded after the sequence number, in
+	// the order they are recorded, until the context is canceled.
+	StreamTransactions(ctx context.Context, addresses []store.Address, sequence uint64) <-chan store.Transaction
+
+	// RegisterWebhook registers a webhook notified of the activity of a subscribed address, it fails when the address
+	// is not subscribed. The returned webhook holds its secret.
+	RegisterWebhook(address store.Address, options WebhookOptions) (store.Webhook, bool, error)
+
+	// UnregisterWebhook removes a webhook by its id.
+	UnregisterWebhook(id string) (bool, error)
+
+	// GetWebhooks retrieves the webhooks registered for a specified address, or every webhook when the address is
+	// empty, without their secrets.
+	GetWebhooks(address store.Address) []store.Webhook
+
+	// GetWebhookDeliveries retrieves the latest delivery attempts of a webhook, the most recent first.
+	GetWebhookDeliveries(id string) []DeliveryAttempt
+
+	// GetDeadLetters retrieves the deliveries to a webhook that failed every attempt.
+	GetDeadLetters(id string) []store.Delivery
+
+	// ReplayDeadLetters delivers the dead letters of a webhook again, either every one or the one with the given
+	// delivery id, and returns the number of replayed deliveries.
+	ReplayDeadLetters(id string, deliveryID string) int
+}
+
+// SyncStatus describes the progress of the parser against the network.
+type SyncStatus struct {
+	// CurrentBlock is the most recently parsed block.
+	CurrentBlock int `json:"currentBlock"`
+	// NetworkBlock is the latest block seen on the network.
+	NetworkBlock int `json:"networkBlock"`
+	// Lag is the number of blocks the parser is behind the network.
+	Lag int `json:"lag"`
+	// StuckBlocks is the number of blocks that could not be fetched and wait to be retried.
+	StuckBlocks int `json:"stuckBlocks"`
+}
+
+// SubscribeOptions holds the optional details of a subscription.
+type SubscribeOptions struct {
+	// Label is a free form name given to the subscription.
+	Label string
+	// Owner identifies who the subscription belongs to.
+	Owner string
+	// FromBlock, when set, is the block the transactions of the address are backfilled from.
+	FromBlock int
+	// FromGenesis, when set, backfills the transactions of the address from the first block it was active in, located
+	// from its nonce and balance at past blocks. It takes precedence over FromBlock.
+	FromGenesis bool
+}
 
-	// GetTransactions retrieves the list of transactions involving a specified address.
-	GetTransactions(address string) []store.Transaction
+// WebhookOptions holds the details of a webhook.
+type WebhookOptions struct {
+	// URL is the endpoint the events are posted to.
+	URL string
+	// Events lists the types of the delivered events, every type when empty.
+	Events []store.EventType
+	// Secret is the key the payloads are signed with, a random one is generated when empty.
+	Secret string
 }
@@ -0,0 +1,229 @@
+package parser
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mo-mohamed/txparser/blockchain"
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+const (
+	// defaultMempoolPollInterval is the delay between two polls of the mempool.
+	defaultMempoolPollInterval = 2 * time.Second
+	// defaultDropTimeout is how long a pending transaction can be missing from the mempool without being mined
+	// before it is considered dropped.
+	defaultDropTimeout = time.Minute
+	// mempoolRetention is how long replaced and dropped transactions are still reported.
+	mempoolRetention = 10 * time.Minute
+)
+
+// PendingTransaction is a transaction involving a subscribed address seen in the mempool, along with how it settled
+// when it was replaced or dropped. Mined transactions are reported with the transactions of their block.
+type PendingTransaction struct {
+	store.Transaction
+	// FirstSeen is the time the transaction was first seen in the mempool.
+	FirstSeen time.Time `json:"firstSeen"`
+	// ReplacedBy is the hash of the transaction with the same sender and nonce that replaced this one.
+	ReplacedBy string `json:"replacedBy,omitempty"`
+}
+
+// pendingEntry tracks a pending transaction between the polls of the mempool.
+type pendingEntry struct {
+	tx PendingTransaction
+	// missingSince is the time the transaction left the mempool, zero while it is in the mempool.
+	missingSince time.Time
+	// settledAt is the time the transaction was replaced or dropped.
+	settledAt time.Time
+}
+
+// mempool tracks the pending transactions involving subscribed addresses until they are mined, replaced or dropped.
+type mempool struct {
+	// source lists the transactions of the mempool.
+	source blockchain.IMempool
+	// pollInterval is the delay between two polls of the mempool.
+	pollInterval time.Duration
+	// dropTimeout is how long a transaction can be missing from the mempool before it is considered dropped.
+	dropTimeout time.Duration
+	// transactions holds the tracked transactions, indexed by hash.
+	transactions map[string]*pendingEntry
+	// mined holds the time the recently mined transactions were mined, indexed by hash, the mempool may still list
+	// them for a while.
+	mined map[string]time.Time
+	// mu guards the tracked transactions.
+	mu sync.Mutex
+}
+
+func newMempool(source blockchain.IMempool, pollInterval time.Duration, dropTimeout time.Duration) *mempool {
+	if pollInterval <= 0 {
+		pollInterval = defaultMempoolPollInterval
+	}
+	if dropTimeout <= 0 {
+		dropTimeout = defaultDropTimeout
+	}
+	return &mempool{
+		source:       source,
+		pollInterval: pollInterval,
+		dropTimeout:  dropTimeout,
+		transactions: make(map[string]*pendingEntry),
+		mined:        make(map[string]time.Time),
+	}
+}
+
+// runMempool polls the mempool until the context is canceled.
+func (p *TxParser) runMempool(ctx context.Context) {
+	for {
+		p.pollMempool(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.mempool.pollInterval):
+		}
+	}
+}
+
+// pollMempool tracks the new pending transactions involving subscribed addresses, and settles the ones replaced by
+// a transaction with the same sender and nonce or missing from the mempool for longer than the drop timeout.
+func (p *TxParser) pollMempool(ctx context.Context) {
+	transactions, err := p.mempool.source.PendingTransactions(ctx)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	var involved []store.Transaction
+	for _, tx := range transactions {
+		if p.subscribed(tx.From) || p.subscribed(tx.To) {
+			involved = append(involved, tx)
+		}
+	}
+
+	m := p.mempool
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	seen := make(map[string]bool, len(involved))
+	for _, tx := range involved {
+		hash := strings.ToLower(tx.Hash)
+		if _, exists := m.mined[hash]; exists {
+			continue
+		}
+		seen[hash] = true
+		if entry, exists := m.transactions[hash]; exists {
+			entry.missingSince = time.Time{}
+			continue
+		}
+		m.replace(tx, now)
+		tx.BlockNumber = ""
+		tx.Status = store.StatusPending
+		tx.Details = nil
+		m.transactions[hash] = &pendingEntry{tx: PendingTransaction{Transaction: tx, FirstSeen: now}}
+	}
+
+	for hash, entry := range m.transactions {
+		switch {
+		case entry.tx.Status != store.StatusPending:
+			if now.Sub(entry.settledAt) > mempoolRetention {
+				delete(m.transactions, hash)
+			}
+		case seen[hash]:
+		case entry.missingSince.IsZero():
+			entry.missingSince = now
+		case now.Sub(entry.missingSince) >= m.dropTimeout:
+			entry.tx.Status = store.StatusDropped
+			entry.settledAt = now
+		}
+	}
+	for hash, minedAt := range m.mined {
+		if now.Sub(minedAt) > mempoolRetention {
+			delete(m.mined, hash)
+		}
+	}
+}
+
+// reconcileMempool stops tracking the pending transactions mined in the block, and settles the ones replaced by a
+// mined transaction with the same sender and nonce.
+func (p *TxParser) reconcileMempool(block store.Block) {
+	if p.mempool == nil {
+		return
+	}
+	m := p.mempool
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	senders := make(map[store.Address]bool)
+	for _, entry := range m.transactions {
+		if entry.tx.Status == store.StatusPending {
+			senders[entry.tx.From] = true
+		}
+	}
+	now := time.Now().UTC()
+	for _, tx := range block.Transactions {
+		hash := strings.ToLower(tx.Hash)
+		if _, tracked := m.transactions[hash]; tracked || p.subscribed(tx.From) || p.subscribed(tx.To) {
+			delete(m.transactions, hash)
+			m.mined[hash] = now
+		}
+		// A replacement may not involve a subscribed address, as a cancellation sent back to the sender when only the
+		// recipient is subscribed, so every mined transaction of a tracked sender is matched.
+		if senders[tx.From] {
+			m.replace(tx, now)
+		}
+	}
+}
+
+// replace settles the pending transactions sharing the sender and nonce of the given transaction as replaced by it.
+// The caller must hold the lock.
+func (m *mempool) replace(tx store.Transaction, now time.Time) {
+	if tx.Nonce == "" {
+		return
+	}
+	for hash, entry := range m.transactions {
+		if entry.tx.Status == store.StatusPending && entry.tx.From == tx.From &&
+			strings.EqualFold(entry.tx.Nonce, tx.Nonce) && hash != strings.ToLower(tx.Hash) {
+			entry.tx.Status = store.StatusReplaced
+			entry.tx.ReplacedBy = tx.Hash
+			entry.settledAt = now
+		}
+	}
+}
+
+// pendingTransactions returns the tracked transactions involving the address ordered by the time they were first seen,
+// optionally restricted to the ones still pending.
+func (m *mempool) pendingTransactions(address store.Address, pendingOnly bool) []PendingTransaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	transactions := []PendingTransaction{}
+	for _, entry := range m.transactions {
+		if entry.tx.Involves(address) && (!pendingOnly || entry.tx.Status == store.StatusPending) {
+			transactions = append(transactions, entry.tx)
+		}
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].FirstSeen.Before(transactions[j].FirstSeen)
+	})
+	return transactions
+}
+
+// GetPendingTransactions returns the transactions involving a subscribed address seen in the mempool, either still
+// pending or recently replaced or dropped. It is empty unless the parser watches the mempool.
+func (p *TxParser) GetPendingTransactions(address store.Address) []PendingTransaction {
+	if p.mempool == nil {
+		return []PendingTransaction{}
+	}
+	return p.mempool.pendingTransactions(address, false)
+}
+
+// subscribed reports whether the address is subscribed.
+func (p *TxParser) subscribed(address store.Address) bool {
+	if address == "" {
+		return false
+	}
+	_, exists := p.store.Subscription(address)
+	return exists
+}
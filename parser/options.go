@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"time"
+
+	"github.com/mo-mohamed/txparser/blockchain"
+)
+
+// defaultConfirmations is the number of blocks, including its own, a transaction has to be buried under
+// before it is considered confirmed.
+const defaultConfirmations = 12
+
+const (
+	// defaultConcurrency is the number of blocks fetched at the same time.
+	defaultConcurrency = 4
+	// defaultFetchWindow is the number of blocks fetched ahead of the next block to commit.
+	defaultFetchWindow = 16
+)
+
+// DefaultBatchSize is the number of blocks fetched in a single batch request when no batch size is given.
+const DefaultBatchSize = 10
+
+// Option configures a TxParser.
+type Option func(*TxParser)
+
+// WithConfirmations sets the number of blocks, including its own, a transaction has to be buried under
+// before it is considered confirmed. The webhook events of a block are held back until it is confirmed.
+func WithConfirmations(confirmations int) Option {
+	return func(p *TxParser) {
+		p.confirmations = confirmations
+	}
+}
+
+// WithFinalityTags makes the parser rely on the "finalized" and "safe" block tags reported by the network,
+// transactions in finalized blocks are reported as finalized and the ones in safe blocks as confirmed.
+func WithFinalityTags() Option {
+	return func(p *TxParser) {
+		p.useFinalityTags = true
+	}
+}
+
+// WithMaxCatchUp limits the number of blocks caught up on startup, when the stored block is further behind
+// the network the blocks in between are skipped. A zero limit catches up on every missed block.
+func WithMaxCatchUp(blocks int) Option {
+	return func(p *TxParser) {
+		p.maxCatchUp = blocks
+	}
+}
+
+// WithStartFromHead makes the parser ignore the stored block and start polling from the latest block on the network.
+func WithStartFromHead() Option {
+	return func(p *TxParser) {
+		p.startFromHead = true
+	}
+}
+
+// WithHeadSource makes the parser fetch new blocks as soon as the head source announces them, instead of waiting
+// for the next poll. Polling goes on in the background and takes over whenever the head source drops.
+func WithHeadSource(source blockchain.IHeadSource) Option {
+	return func(p *TxParser) {
+		p.headSource = source
+	}
+}
+
+// WithMempool makes the parser watch the mempool for pending transactions involving subscribed addresses, polling
+// it at the given interval. A pending transaction missing from the mempool for longer than the drop timeout without
+// being mined is reported as dropped. Zero durations use the defaults.
+func WithMempool(source blockchain.IMempool, pollInterval time.Duration, dropTimeout time.Duration) Option {
+	return func(p *TxParser) {
+		p.mempool = newMempool(source, pollInterval, dropTimeout)
+	}
+}
+
+// WithConcurrency sets the number of blocks fetched at the same time, and the number of blocks fetched ahead of
+// the next block to commit. The window is raised to the concurrency when lower.
+func WithConcurrency(concurrency int, fetchWindow int) Option {
+	return func(p *TxParser) {
+		if concurrency < 1 {
+			concurrency = 1
+		}
+		if fetchWindow < concurrency {
+			fetchWindow = concurrency
+		}
+		p.concurrency = concurrency
+		p.fetchWindow = fetchWindow
+	}
+}
+
+// WithRetryBackoff sets the delay before retrying a block that could not be fetched, the delay doubles after every
+// failed attempt up to the given maximum.
+func WithRetryBackoff(backoff time.Duration, maxBackoff time.Duration) Option {
+	return func(p *TxParser) {
+		p.retries = newRetryQueue(backoff, maxBackoff)
+	}
+}
+
+// WithWebhookRetry sets the delay before retrying a failed webhook delivery, the delay doubles after every failed
+// attempt up to the given maximum. A delivery failing the given number of attempts is moved to the dead letters.
+func WithWebhookRetry(backoff time.Duration, maxBackoff time.Duration, attempts int) Option {
+	return func(p *TxParser) {
+		p.webhooks = newWebhookDispatcher(backoff, maxBackoff, attempts)
+	}
+}
+
+// WithBatchSize sets the number of blocks fetched in a single batch request, when catching up or backfilling.
+// The fetch window is raised to the batch size when lower.
+func WithBatchSize(size int) Option {
+	return func(p *TxParser) {
+		if size > 0 {
+			p.batchSize = size
+		}
+	}
+}
+
+// WithMaxBackfillBlocks limits the number of blocks a backfill scans. Every block is fetched along with its receipts,
+// and traced when tracking internal transactions, for the one address backfilled, so the backfills starting far back
+// are costly. The subscriptions backfilling further back are rejected, and the backfills from the first activity of an
+// address start this many blocks back at most. There is no limit by default.
+func WithMaxBackfillBlocks(blocks int) Option {
+	return func(p *TxParser) {
+		if blocks > 0 {
+			p.maxBackfillBlocks = blocks
+		}
+	}
+}
+
+// WithBackfillBatchSize sets the number of blocks fetched in a single batch request when backfilling, so the history
+// of newly subscribed addresses can be scanned in larger batches than the polled blocks. The backfills use the batch
+// size of the polled blocks when it is not set.
+func WithBackfillBatchSize(size int) Option {
+	return func(p *TxParser) {
+		if size > 0 {
+			p.backfillBatchSize = size
+		}
+	}
+}
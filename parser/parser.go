@@ -8,13 +8,23 @@ package parser
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"math"
+	"sync"
 	"time"
 
 	"github.com/mo-mohamed/txparser/blockchain"
 	store "github.com/mo-mohamed/txparser/storage"
 )
 
+// pollInterval is the delay between two polls of the latest block on the network.
+const pollInterval = 5 * time.Second
+
+// maxReorgDepth is the maximum number of blocks discarded in a single rollback, the store keeping the headers of as many
+// blocks to detect the reorganizations.
+const maxReorgDepth = store.ReorgWindow
+
 // TxParser implements the Parser interface.
 type TxParser struct {
 	// store is the storage holding transactions, subscribers and blocks data
@@ -22,65 +32,553 @@ type TxParser struct {
 
 	// blockChain is a blockchain client for communicating with the blockchain network
 	blockChain blockchain.IBlockchain
+
+	// confirmations is the number of blocks a transaction has to be buried under to be confirmed
+	confirmations int
+
+	// useFinalityTags makes the transaction status rely on the "finalized" and "safe" block tags
+	useFinalityTags bool
+
+	// maxCatchUp is the maximum number of missed blocks caught up on startup, zero means no limit
+	maxCatchUp int
+
+	// startFromHead makes the parser ignore the stored block and start from the latest block on the network
+	startFromHead bool
+
+	// resumed tells whether the starting block was picked, which is delayed to the first poll when the network could
+	// not be reached on creation
+	resumed bool
+
+	// headSource announces the new blocks ahead of the next poll, when set
+	headSource blockchain.IHeadSource
+
+	// concurrency is the number of blocks fetched at the same time
+	concurrency int
+
+	// fetchWindow is the number of blocks fetched ahead of the next block to commit
+	fetchWindow int
+
+	// batchSize is the number of blocks fetched in a single request
+	batchSize int
+
+	// backfillBatchSize is the number of blocks fetched in a single request when backfilling, the batch size when zero
+	backfillBatchSize int
+
+	// maxBackfillBlocks is the maximum number of blocks a backfill scans, zero means no limit
+	maxBackfillBlocks int
+
+	// headBlock is the latest block seen on the network
+	headBlock int
+
+	// safeBlock is the latest block the network labels as safe
+	safeBlock int
+
+	// finalizedBlock is the latest block the network labels as finalized
+	finalizedBlock int
+
+	// retries holds the blocks that could not be fetched until they are recovered
+	retries *retryQueue
+
+	// backfills schedules the scans of the history of newly subscribed addresses
+	backfills *backfiller
+
+	// mempool tracks the pending transactions involving subscribed addresses, when set
+	mempool *mempool
+
+	// webhooks delivers the recorded activity to the webhooks of the subscribed addresses
+	webhooks *webhookDispatcher
+
+	// records announces the transactions recorded to the streams
+	records *recordNotifier
+
+	// mu guards the network blocks tracked by the parser
+	mu sync.Mutex
 }
 
 // NewTxParser initializes a new TxParser.
-func NewTxParser(store store.IStore, blockchain blockchain.IBlockchain) *TxParser {
+func NewTxParser(store store.IStore, blockchain blockchain.IBlockchain, options ...Option) *TxParser {
 	parser := &TxParser{
-		store:      store,
-		blockChain: blockchain,
+		store:         store,
+		blockChain:    blockchain,
+		confirmations: defaultConfirmations,
+		concurrency:   defaultConcurrency,
+		fetchWindow:   defaultFetchWindow,
+		batchSize:     DefaultBatchSize,
+		retries:       newRetryQueue(defaultRetryBackoff, defaultMaxRetryBackoff),
+		backfills:     newBackfiller(),
+		webhooks:      newWebhookDispatcher(defaultWebhookBackoff, defaultWebhookMaxBackoff, defaultWebhookAttempts),
+		records:       newRecordNotifier(),
+	}
+	for _, option := range options {
+		option(parser)
 	}
-	// Set the current block to the latest block on the network to start polling from this point
-	parser.store.SetCurrentBlock(parser.blockChain.LatestNetworkBlock())
+	parser.webhooks.load(store)
+	parser.backfills.load(store)
+	parser.fetchWindow = max(parser.fetchWindow, parser.batchSize)
+	latestBlockOnNetwork, err := parser.blockChain.LatestNetworkBlock(context.Background())
+	if err != nil {
+		log.Println("Error fetching the latest block, resuming on the first poll:", err)
+		return parser
+	}
+	parser.resume(latestBlockOnNetwork)
+	parser.updateNetworkBlocks(context.Background(), latestBlockOnNetwork)
 	return parser
 }
 
+// resume picks the block polling starts after. The parser resumes from the stored block and catches up on the blocks
+// missed since, unless the store is fresh or it is told to start from the latest block on the network.
+func (p *TxParser) resume(latestBlockOnNetwork int) {
+	p.resumed = true
+	storedBlock := p.store.CurrentBlock()
+	switch {
+	case storedBlock == 0 || p.startFromHead:
+		if err := p.store.SetCurrentBlock(latestBlockOnNetwork); err != nil {
+			log.Println("Error storing the starting block:", err)
+		}
+	case p.maxCatchUp > 0 && latestBlockOnNetwork-storedBlock > p.maxCatchUp:
+		catchUpFrom := latestBlockOnNetwork - p.maxCatchUp
+		log.Printf("Skipping blocks %d to %d, exceeding the catch up window\n", storedBlock+1, catchUpFrom)
+		if err := p.store.SetCurrentBlock(catchUpFrom); err != nil {
+			log.Println("Error storing the starting block:", err)
+		}
+	case latestBlockOnNetwork > storedBlock:
+		log.Printf("Catching up on blocks %d to %d\n", storedBlock+1, latestBlockOnNetwork)
+	}
+}
+
 // GetCurrentBlock fetches the latest block number from the Ethereum network.
 func (p *TxParser) GetCurrentBlock() int {
 	return p.store.CurrentBlock()
 }
 
+// GetSyncStatus reports how far the parser is behind the network.
+func (p *TxParser) GetSyncStatus() SyncStatus {
+	currentBlock := p.store.CurrentBlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := SyncStatus{
+		CurrentBlock: currentBlock,
+		NetworkBlock: p.headBlock,
+		StuckBlocks:  len(p.retries.list()),
+	}
+	if p.headBlock > currentBlock {
+		status.Lag = p.headBlock - currentBlock
+	}
+	return status
+}
+
+// GetStuckBlocks returns the blocks that could not be fetched and wait to be retried.
+func (p *TxParser) GetStuckBlocks() []FailedBlock {
+	return p.retries.list()
+}
+
+// GetEndpointHealth returns the health of the endpoints of the blockchain client, if it tracks it.
+func (p *TxParser) GetEndpointHealth() []blockchain.EndpointHealth {
+	health := []blockchain.EndpointHealth{}
+	if reporter, ok := p.blockChain.(blockchain.IHealthReporter); ok {
+		health = append(health, reporter.EndpointHealth()...)
+	}
+	return health
+}
+
 // Subscribe adds an address to the list of subscribers.
-func (p *TxParser) Subscribe(address string) bool {
-	return p.store.Subscribe(address)
+func (p *TxParser) Subscribe(address store.Address) (bool, error) {
+	return p.SubscribeWithOptions(address, SubscribeOptions{})
+}
+
+// SubscribeWithOptions adds an address to the list of subscribers along with the subscription details, and schedules
+// a backfill of its transactions when a starting block is given. It fails when the subscription cannot be stored, with
+// ErrBackfillUnsupported when the backfill starts from the first activity of the address and the blockchain client
+// cannot locate it, or with ErrBackfillTooLong when the backfill starts further back than the maximum number of
+// backfilled blocks.
+func (p *TxParser) SubscribeWithOptions(address store.Address, options SubscribeOptions) (bool, error) {
+	if _, ok := p.blockChain.(blockchain.IAccountHistory); options.FromGenesis && !ok {
+		return false, ErrBackfillUnsupported
+	}
+	if options.FromBlock > 0 && p.maxBackfillBlocks > 0 && p.store.CurrentBlock()-options.FromBlock+1 > p.maxBackfillBlocks {
+		return false, ErrBackfillTooLong
+	}
+	subscription := store.Subscription{
+		Address:        address,
+		Label:          options.Label,
+		Owner:          options.Owner,
+		CreatedAtBlock: p.store.CurrentBlock(),
+		CreatedAt:      time.Now().UTC(),
+	}
+	if subscribed, err := p.store.Subscribe(subscription); !subscribed || err != nil {
+		return false, err
+	}
+	if options.FromBlock > 0 || options.FromGenesis {
+		p.scheduleBackfill(address, options.FromBlock, options.FromGenesis)
+	}
+	return true, nil
 }
 
-// GetTransactions returns a list of transactions for a subscribed address.
-func (p *TxParser) GetTransactions(address string) []store.Transaction {
-	return p.store.Transactions(address)
+// Unsubscribe removes an address from the list of subscribers, purging its stored transactions when asked to.
+func (p *TxParser) Unsubscribe(address store.Address, purge bool) (bool, error) {
+	return p.store.Unsubscribe(address, purge)
 }
 
-// StartPolling starts fetching new blocks, alternatively "eth_subscribe" can be used for new blocks.
+// GetSubscriptions returns every subscription, optionally restricted to an owner.
+func (p *TxParser) GetSubscriptions(owner string) []store.Subscription {
+	subscriptions := []store.Subscription{}
+	for _, subscription := range p.store.Subscriptions() {
+		if owner == "" || subscription.Owner == owner {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions
+}
+
+// GetTransactions returns a list of transactions for a subscribed address along with their status,
+// optionally restricted to the given statuses. The transactions still pending in the mempool come last.
+func (p *TxParser) GetTransactions(address store.Address, statuses ...store.TransactionStatus) []store.Transaction {
+	transactions := p.GetFullTransactions(address, statuses...)
+	for i := range transactions {
+		transactions[i].Details = nil
+	}
+	return transactions
+}
+
+// GetFullTransactions returns the transactions of a subscribed address as GetTransactions does, along with the
+// complete transactions retrieved from their blocks.
+func (p *TxParser) GetFullTransactions(address store.Address, statuses ...store.TransactionStatus) []store.Transaction {
+	transactions := []store.Transaction{}
+	for _, tx := range p.store.Transactions(address) {
+		tx.Status = p.blockStatus(tx.BlockHeight())
+		if len(statuses) == 0 || containsStatus(statuses, tx.Status) {
+			transactions = append(transactions, tx)
+		}
+	}
+	if p.mempool != nil && (len(statuses) == 0 || containsStatus(statuses, store.StatusPending)) {
+		for _, tx := range p.mempool.pendingTransactions(address, true) {
+			transactions = append(transactions, tx.Transaction)
+		}
+	}
+	return transactions
+}
+
+// QueryTransactions returns a page of the transactions of a subscribed address selected by the query along with their
+// status, optionally restricted to the given statuses. The transactions pending in the mempool come after the mined
+// ones, on the last page in ascending order and on the first page in descending order, on top of the limit. They are
+// left out of queries restricted to a block range.
+func (p *TxParser) QueryTransactions(query store.TransactionQuery, statuses ...store.TransactionStatus) (store.TransactionPage, error) {
+	ranged := query.FromBlock > 0 || query.ToBlock > 0 || !query.FromTime.IsZero() || !query.ToTime.IsZero()
+	if len(statuses) > 0 {
+		query.Match = func(tx store.Transaction) bool {
+			return containsStatus(statuses, p.blockStatus(tx.BlockHeight()))
+		}
+		// The query is narrowed down to the blocks holding the statuses, the store doesn't scan the others.
+		fromBlock, toBlock := p.statusBlocks(statuses)
+		query.FromBlock = max(query.FromBlock, fromBlock)
+		if toBlock < math.MaxInt && (query.ToBlock == 0 || toBlock < query.ToBlock) {
+			query.ToBlock = toBlock
+		}
+	}
+	page, err := p.store.QueryTransactions(query)
+	if err != nil {
+		return store.TransactionPage{}, err
+	}
+	for i := range page.Transactions {
+		page.Transactions[i].Status = p.blockStatus(page.Transactions[i].BlockHeight())
+	}
+
+	lastPage := (!query.Descending && page.NextCursor == "") || (query.Descending && query.Cursor == "")
+	if p.mempool == nil || !lastPage || ranged || (len(statuses) > 0 && !containsStatus(statuses, store.StatusPending)) {
+		return page, nil
+	}
+	query.Match = nil
+	var pending []store.Transaction
+	for _, tx := range p.mempool.pendingTransactions(query.Address, true) {
+		if query.Matches(tx.Transaction) {
+			pending = append(pending, tx.Transaction)
+		}
+	}
+	if query.Descending {
+		for i, j := 0, len(pending)-1; i < j; i, j = i+1, j-1 {
+			pending[i], pending[j] = pending[j], pending[i]
+		}
+		page.Transactions = append(pending, page.Transactions...)
+	} else {
+		page.Transactions = append(page.Transactions, pending...)
+	}
+	return page, nil
+}
+
+// GetTokenTransfers returns the ERC-20 transfers sent or received by a subscribed address along with their status,
+// optionally restricted to the given statuses.
+func (p *TxParser) GetTokenTransfers(address store.Address, statuses ...store.TransactionStatus) []store.TokenTransfer {
+	return p.tokenTransfers(address, false, statuses)
+}
+
+// GetNFTTransfers returns the ERC-721 and ERC-1155 transfers sent or received by a subscribed address along with
+// their status, optionally restricted to the given statuses.
+func (p *TxParser) GetNFTTransfers(address store.Address, statuses ...store.TransactionStatus) []store.TokenTransfer {
+	return p.tokenTransfers(address, true, statuses)
+}
+
+// GetInternalTransactions returns the ether sent to or from a subscribed address by contract calls along with their
+// status, optionally restricted to the given statuses.
+func (p *TxParser) GetInternalTransactions(address store.Address, statuses ...store.TransactionStatus) []store.InternalTransaction {
+	calls := []store.InternalTransaction{}
+	for _, call := range p.store.InternalTransactions(address) {
+		call.Status = p.blockStatus(call.BlockHeight())
+		if len(statuses) == 0 || containsStatus(statuses, call.Status) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// GetWithdrawals returns the beacon chain withdrawals credited to a subscribed address along with their status,
+// optionally restricted to the given statuses.
+func (p *TxParser) GetWithdrawals(address store.Address, statuses ...store.TransactionStatus) []store.Withdrawal {
+	withdrawals := []store.Withdrawal{}
+	for _, withdrawal := range p.store.Withdrawals(address) {
+		withdrawal.Status = p.blockStatus(withdrawal.BlockHeight())
+		if len(statuses) == 0 || containsStatus(statuses, withdrawal.Status) {
+			withdrawals = append(withdrawals, withdrawal)
+		}
+	}
+	return withdrawals
+}
+
+// tokenTransfers returns either the fungible or the non-fungible token transfers of an address along with their status.
+func (p *TxParser) tokenTransfers(address store.Address, nonFungible bool, statuses []store.TransactionStatus) []store.TokenTransfer {
+	transfers := []store.TokenTransfer{}
+	for _, transfer := range p.store.TokenTransfers(address) {
+		if transfer.NonFungible() != nonFungible {
+			continue
+		}
+		transfer.Status = p.blockStatus(transfer.BlockHeight())
+		if len(statuses) == 0 || containsStatus(statuses, transfer.Status) {
+			transfers = append(transfers, transfer)
+		}
+	}
+	return transfers
+}
+
+// blockStatus computes the finality of the content of a block against the network blocks.
+func (p *TxParser) blockStatus(blockNumber int) store.TransactionStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case p.useFinalityTags && blockNumber <= p.finalizedBlock:
+		return store.StatusFinalized
+	case p.useFinalityTags && blockNumber <= p.safeBlock:
+		return store.StatusConfirmed
+	case p.headBlock-blockNumber+1 >= p.confirmations:
+		return store.StatusConfirmed
+	default:
+		return store.StatusPendingConfirmation
+	}
+}
+
+// statusBlocks returns the range of the blocks holding the transactions of the given statuses, which only depend on the
+// block number, the pending ones aside. The range ends at math.MaxInt when unbounded, and is empty when it starts past
+// its end.
+func (p *TxParser) statusBlocks(statuses []store.TransactionStatus) (int, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// The last finalized and confirmed blocks, the blocks after them are confirmed and pending confirmation.
+	finalized, confirmed := 0, p.headBlock-p.confirmations+1
+	if p.useFinalityTags {
+		finalized, confirmed = p.finalizedBlock, max(confirmed, p.safeBlock)
+	}
+	confirmed = max(confirmed, finalized)
+	ranges := map[store.TransactionStatus][2]int{
+		store.StatusFinalized:           {1, finalized},
+		store.StatusConfirmed:           {finalized + 1, confirmed},
+		store.StatusPendingConfirmation: {confirmed + 1, math.MaxInt},
+	}
+	fromBlock, toBlock := math.MaxInt, 0
+	for _, status := range statuses {
+		if blocks, exists := ranges[status]; exists && blocks[0] <= blocks[1] {
+			fromBlock, toBlock = min(fromBlock, blocks[0]), max(toBlock, blocks[1])
+		}
+	}
+	return fromBlock, toBlock
+}
+
+// updateNetworkBlocks records the latest block on the network, along with the finalized and safe blocks when enabled.
+// A tagged block that could not be fetched keeps its previously known value.
+func (p *TxParser) updateNetworkBlocks(ctx context.Context, latestBlockOnNetwork int) {
+	safeBlock, finalizedBlock := -1, -1
+	if p.useFinalityTags {
+		var err error
+		if safeBlock, err = p.blockChain.BlockNumberByTag(ctx, "safe"); err != nil {
+			log.Println(err.Error())
+			safeBlock = -1
+		}
+		if finalizedBlock, err = p.blockChain.BlockNumberByTag(ctx, "finalized"); err != nil {
+			log.Println(err.Error())
+			finalizedBlock = -1
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.headBlock = latestBlockOnNetwork
+	if safeBlock >= 0 {
+		p.safeBlock = safeBlock
+	}
+	if finalizedBlock >= 0 {
+		p.finalizedBlock = finalizedBlock
+	}
+}
+
+// containsStatus reports whether the status is within the list of statuses.
+func containsStatus(statuses []store.TransactionStatus, status store.TransactionStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// StartPolling starts fetching new blocks on every poll, or as soon as the head source announces them when set.
+// It also runs the backfill jobs of the addresses subscribed with a starting block, delivers the webhook events, checks
+// the health of the JSON-RPC endpoints when the blockchain client tracks it, and watches the mempool when set.
+// It returns once the context is canceled and every one of these workers stopped, so the store can be closed.
 func (p *TxParser) StartPolling(ctx context.Context) {
 	log.Println("Starting Polling Blocks")
+	var workers sync.WaitGroup
+	defer workers.Wait()
+	run := func(worker func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker(ctx)
+		}()
+	}
+	run(p.runBackfills)
+	run(p.runWebhooks)
+	if reporter, ok := p.blockChain.(blockchain.IHealthReporter); ok {
+		run(reporter.RunHealthChecks)
+	}
+	if p.mempool != nil {
+		run(p.runMempool)
+	}
+	var heads <-chan int
+	if p.headSource != nil {
+		heads = p.headSource.SubscribeHeads(ctx)
+	}
+	announcedBlock := 0
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("Polling blocks stopped.")
 			return
 		default:
-			latestBlockOnNetwork := p.blockChain.LatestNetworkBlock()
-
-			for block := p.store.CurrentBlock() + 1; block <= latestBlockOnNetwork; block++ {
-				p.processBlock(block)
+			if latestBlockOnNetwork, err := p.blockChain.LatestNetworkBlock(ctx); err != nil {
+				log.Println(err.Error())
+			} else {
+				// The announced block may not be reported by the JSON-RPC endpoints yet.
+				latestBlockOnNetwork = max(latestBlockOnNetwork, announcedBlock)
+				if !p.resumed {
+					p.resume(latestBlockOnNetwork)
+				}
+				p.updateNetworkBlocks(ctx, latestBlockOnNetwork)
+				p.syncBlocks(ctx, latestBlockOnNetwork)
 			}
-			p.store.SetCurrentBlock(latestBlockOnNetwork)
 
-			// On Etherium network, there is a new block added every 12 seconds
-			time.Sleep(5 * time.Second)
+			// On Etherium network, there is a new block added every 12 seconds, a failed block may be due for a retry sooner
+			wait := pollInterval
+			if retryIn := p.retries.retryIn(p.store.CurrentBlock() + 1); retryIn > 0 && retryIn < wait {
+				wait = retryIn
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			case head, ok := <-heads:
+				if !ok {
+					heads = nil
+				}
+				announcedBlock = max(announcedBlock, head)
+			}
 		}
 	}
 }
 
-// processBlock helper fetches and extractstransactions from a block.
-func (p *TxParser) processBlock(blockNumber int) {
+// commitBlock helper stores the transactions of a fetched block, and reports whether polling can move on to the next block.
+func (p *TxParser) commitBlock(ctx context.Context, blockNumber int, block store.Block, err error) bool {
 	log.Println("Processing Block Number:", blockNumber)
 
-	transactions, err := p.blockChain.ParseBlock(blockNumber)
+	// The current block must not move past a block that could not be fetched, it gets retried on the following polls.
 	if err != nil {
-		log.Println(err.Error())
+		log.Printf("Processing Block %d failed, waiting for a retry: %s\n", blockNumber, err.Error())
+		return false
 	}
-	p.store.SaveTransactions(transactions)
+
+	// The new block must extend the stored chain, otherwise the stored blocks got orphaned by a reorganization.
+	if parent, exists := p.store.Block(blockNumber - 1); exists && parent.Hash != block.ParentHash {
+		log.Println("Chain reorganization detected at block:", blockNumber)
+		if p.rollback(ctx, blockNumber-1) > 0 {
+			return true
+		}
+		// The stored parent is still canonical, so the fetched block is stale: it is fetched again after the retry delay
+		// rather than on the next poll.
+		err := fmt.Errorf("parent hash %s does not match the hash %s of the stored block %d", block.ParentHash, parent.Hash, blockNumber-1)
+		log.Printf("Processing Block %d failed, waiting for a retry: %s\n", blockNumber, err.Error())
+		p.retries.fail(blockNumber, err)
+		return false
+	}
+
+	// A block that could not be stored is processed again on the following polls. Only the records it newly stored are
+	// delivered to the webhooks, right away as processing the block again doesn't store them again.
+	recorded, err := p.store.SaveBlock(block)
+	if err != nil {
+		log.Printf("Storing Block %d failed, waiting for a retry: %s\n", blockNumber, err.Error())
+		return false
+	}
+	p.notifyWebhooks(recorded)
+	if err := p.store.SetCurrentBlock(blockNumber); err != nil {
+		log.Printf("Storing Block %d failed, waiting for a retry: %s\n", blockNumber, err.Error())
+		return false
+	}
+	p.records.notify()
+	p.reconcileMempool(block)
 
 	log.Println("Processing Block Completed:", blockNumber)
+	return true
+}
+
+// rollback walks back from the given block, discarding every stored block that is no longer part of the
+// canonical chain, and rewinds the current block to the common ancestor so the canonical blocks get re-processed.
+// It returns the number of discarded blocks.
+func (p *TxParser) rollback(ctx context.Context, blockNumber int) int {
+	discarded := 0
+	for ; discarded < maxReorgDepth; blockNumber-- {
+		stored, exists := p.store.Block(blockNumber)
+		if !exists {
+			break
+		}
+		canonical, err := p.blockChain.ParseBlock(ctx, blockNumber)
+		if err != nil {
+			log.Println(err.Error())
+			break
+		}
+		if canonical.Hash == stored.Hash {
+			break
+		}
+
+		log.Println("Rolling back orphaned block:", blockNumber)
+		if err := p.store.RemoveBlock(blockNumber); err != nil {
+			log.Println("Error rolling back orphaned block:", err)
+			break
+		}
+		if err := p.store.SetCurrentBlock(blockNumber - 1); err != nil {
+			log.Println("Error rolling back orphaned block:", err)
+			break
+		}
+		p.webhooks.discardBlock(blockNumber)
+		discarded++
+	}
+	return discarded
 }
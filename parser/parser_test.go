@@ -2,10 +2,20 @@ package parser_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/mo-mohamed/txparser/blockchain"
 	"github.com/mo-mohamed/txparser/mock"
 	"github.com/mo-mohamed/txparser/parser"
 	store "github.com/mo-mohamed/txparser/storage"
@@ -16,7 +26,7 @@ func TestGetCurrentBlock(t *testing.T) {
 	store := store.NewMemoryStore()
 	store.SetCurrentBlock(blockNumberInTest)
 	blockchain := &mock.BlockchainMock{
-		LatestNetworkBlockFunc: func() int { return blockNumberInTest },
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return blockNumberInTest, nil },
 	}
 	parser := parser.NewTxParser(store, blockchain)
 
@@ -29,16 +39,16 @@ func TestGetCurrentBlock(t *testing.T) {
 func TestSubscribe(t *testing.T) {
 	store := store.NewMemoryStore()
 	blockchain := &mock.BlockchainMock{
-		LatestNetworkBlockFunc: func() int { return 10 },
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 10, nil },
 	}
 	parser := parser.NewTxParser(store, blockchain)
-	address := "0x123456789abcdef"
+	const address = "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"
 
-	if !parser.Subscribe(address) {
+	if subscribed, err := parser.Subscribe(address); !subscribed || err != nil {
 		t.Errorf("Expected subscription to succeed")
 	}
 
-	if parser.Subscribe(address) {
+	if subscribed, _ := parser.Subscribe(address); subscribed {
 		t.Errorf("Expected subscription to fail for already subscribed address")
 	}
 }
@@ -46,10 +56,10 @@ func TestSubscribe(t *testing.T) {
 func TestGetTransactions(t *testing.T) {
 	store := store.NewMemoryStore()
 	blockchain := &mock.BlockchainMock{
-		LatestNetworkBlockFunc: func() int { return 10 },
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 10, nil },
 	}
 	parser := parser.NewTxParser(store, blockchain)
-	address := "0x123456789abcdef"
+	const address = "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"
 
 	transactions := parser.GetTransactions(address)
 	if len(transactions) != 0 {
@@ -60,15 +70,18 @@ func TestGetTransactions(t *testing.T) {
 func TestStartPolling(t *testing.T) {
 	storage := store.NewMemoryStore()
 	mockBlockchain := &mock.BlockchainMock{
-		LatestNetworkBlockFunc: func() int { return 100 },
-		ParseBlockFunc: func(block int) ([]store.Transaction, error) {
-			return []store.Transaction{
-				{Hash: "0x" + strconv.Itoa(block), From: "0xabc", To: "0xdef", Value: "500", BlockNumber: strconv.Itoa(block)},
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			return store.Block{
+				BlockHeader: store.BlockHeader{Number: block, Hash: "0xb" + strconv.Itoa(block), ParentHash: "0xb" + strconv.Itoa(block-1)},
+				Transactions: []store.Transaction{
+					{Hash: "0x" + strconv.Itoa(block), From: "0xabc", To: "0xdef", Value: "500", BlockNumber: strconv.Itoa(block)},
+				},
 			}, nil
 		},
 	}
 	parser := parser.NewTxParser(storage, mockBlockchain)
-	mockBlockchain.LatestNetworkBlockFunc = func() int { return 105 }
+	mockBlockchain.LatestNetworkBlockFunc = func(ctx context.Context) (int, error) { return 105, nil }
 	parser.Subscribe("0xabc")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
@@ -96,3 +109,1171 @@ func TestStartPolling(t *testing.T) {
 		}
 	}
 }
+
+func TestStartPollingReorg(t *testing.T) {
+	storage := store.NewMemoryStore()
+	// forkBlock builds a block of the given fork, carrying a single transaction from the subscribed address.
+	forkBlock := func(fork string, block int) store.Block {
+		parentFork := fork
+		if block <= 101 {
+			parentFork = "a"
+		}
+		return store.Block{
+			BlockHeader: store.BlockHeader{Number: block, Hash: fork + strconv.Itoa(block), ParentHash: parentFork + strconv.Itoa(block-1)},
+			Transactions: []store.Transaction{
+				{Hash: "0x" + fork + strconv.Itoa(block), From: "0xabc", To: "0xdef", Value: "500", BlockNumber: strconv.Itoa(block)},
+			},
+		}
+	}
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			if block <= 100 {
+				return forkBlock("a", block), nil
+			}
+			return forkBlock("b", block), nil
+		},
+	}
+	parser := parser.NewTxParser(storage, mockBlockchain)
+	parser.Subscribe("0xabc")
+
+	// Blocks 101 and 102 were processed from fork "a", which got replaced by fork "b" on the network.
+	storage.SaveBlock(forkBlock("a", 100))
+	storage.SaveBlock(forkBlock("a", 101))
+	storage.SaveBlock(forkBlock("a", 102))
+	storage.SetCurrentBlock(102)
+	mockBlockchain.LatestNetworkBlockFunc = func(ctx context.Context) (int, error) { return 103, nil }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	go parser.StartPolling(ctx)
+
+	<-ctx.Done()
+
+	if parser.GetCurrentBlock() != 103 {
+		t.Errorf("Expected current block to be updated to 103, got %d", parser.GetCurrentBlock())
+	}
+
+	hashes := map[string]bool{}
+	for _, tx := range parser.GetTransactions("0xabc") {
+		hashes[tx.Hash] = true
+	}
+	for _, orphaned := range []string{"0xa101", "0xa102"} {
+		if hashes[orphaned] {
+			t.Errorf("Expected orphaned transaction %s to be rolled back", orphaned)
+		}
+	}
+	for _, canonical := range []string{"0xa100", "0xb101", "0xb102", "0xb103"} {
+		if !hashes[canonical] {
+			t.Errorf("Expected canonical transaction %s to be stored", canonical)
+		}
+	}
+	if header, _ := storage.Block(102); header.Hash != "b102" {
+		t.Errorf("Expected block 102 to be replaced by the canonical block, got %s", header.Hash)
+	}
+}
+
+func TestStartPollingStaleBlock(t *testing.T) {
+	storage := store.NewMemoryStore()
+	var stale atomic.Bool
+	stale.Store(true)
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			parentHash := "0xb" + strconv.Itoa(block-1)
+			// The first fetch of block 101 returns a block of a fork that lost, whose parent was never stored.
+			if block == 101 && stale.CompareAndSwap(true, false) {
+				parentHash = "0xc100"
+			}
+			return store.Block{BlockHeader: store.BlockHeader{Number: block, Hash: "0xb" + strconv.Itoa(block), ParentHash: parentHash}}, nil
+		},
+	}
+	parser := parser.NewTxParser(storage, mockBlockchain, parser.WithRetryBackoff(10*time.Millisecond, 10*time.Millisecond))
+	storage.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: 100, Hash: "0xb100", ParentHash: "0xb99"}})
+	mockBlockchain.LatestNetworkBlockFunc = func(ctx context.Context) (int, error) { return 102, nil }
+
+	// The stored block is canonical, nothing is rolled back and the stale block is fetched again well before the next poll.
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	go parser.StartPolling(ctx)
+
+	<-ctx.Done()
+
+	if parser.GetCurrentBlock() != 102 {
+		t.Errorf("Expected current block to be updated to 102, got %d", parser.GetCurrentBlock())
+	}
+	if header, _ := storage.Block(101); header.ParentHash != "0xb100" {
+		t.Errorf("Expected block 101 to extend the stored block, got %v", header)
+	}
+}
+
+func TestGetTransactionsStatus(t *testing.T) {
+	storage := store.NewMemoryStore()
+	blockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		BlockNumberByTagFunc: func(ctx context.Context, tag string) (int, error) {
+			if tag == "finalized" {
+				return 90, nil
+			}
+			return 94, nil
+		},
+	}
+	parser := parser.NewTxParser(storage, blockchain, parser.WithConfirmations(5), parser.WithFinalityTags())
+	parser.Subscribe("0xabc")
+	storage.SaveTransactions([]store.Transaction{
+		{Hash: "0x1", From: "0xabc", To: "0xdef", Value: "500", BlockNumber: "0x5a"},
+		{Hash: "0x2", From: "0xabc", To: "0xdef", Value: "500", BlockNumber: "0x5e"},
+		{Hash: "0x3", From: "0xabc", To: "0xdef", Value: "500", BlockNumber: "0x60"},
+		{Hash: "0x4", From: "0xabc", To: "0xdef", Value: "500", BlockNumber: "0x63"},
+	})
+
+	expectedStatuses := map[string]store.TransactionStatus{
+		"0x1": store.StatusFinalized,
+		"0x2": store.StatusConfirmed,
+		"0x3": store.StatusConfirmed,
+		"0x4": store.StatusPendingConfirmation,
+	}
+	for _, tx := range parser.GetTransactions("0xabc") {
+		if tx.Status != expectedStatuses[tx.Hash] {
+			t.Errorf("Expected transaction %s to be %s, got %s", tx.Hash, expectedStatuses[tx.Hash], tx.Status)
+		}
+	}
+
+	confirmed := parser.GetTransactions("0xabc", store.StatusConfirmed, store.StatusFinalized)
+	if len(confirmed) != 3 {
+		t.Errorf("Expected 3 confirmed or finalized transactions, got %d", len(confirmed))
+	}
+
+	// The queries only scan the blocks holding the statuses.
+	for expected, statuses := range map[string][]store.TransactionStatus{
+		"[0x1]":         {store.StatusFinalized},
+		"[0x2 0x3]":     {store.StatusConfirmed},
+		"[0x4]":         {store.StatusPendingConfirmation},
+		"[0x1 0x4]":     {store.StatusFinalized, store.StatusPendingConfirmation},
+		"[0x2 0x3 0x4]": {store.StatusConfirmed, store.StatusPendingConfirmation},
+		"[]":            {store.StatusPending},
+	} {
+		page, err := parser.QueryTransactions(store.TransactionQuery{Address: "0xabc", FromBlock: 1}, statuses...)
+		var hashes []string
+		for _, tx := range page.Transactions {
+			hashes = append(hashes, tx.Hash)
+		}
+		if got := fmt.Sprint(hashes); err != nil || got != expected {
+			t.Errorf("Expected %v to select %s, got %s %v", statuses, expected, got, err)
+		}
+	}
+}
+
+func TestNewTxParserResumesFromStoredBlock(t *testing.T) {
+	store := store.NewMemoryStore()
+	store.SetCurrentBlock(90)
+	blockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	parser := parser.NewTxParser(store, blockchain)
+
+	if block := parser.GetCurrentBlock(); block != 90 {
+		t.Errorf("Expected parser to resume from block 90, got %d", block)
+	}
+}
+
+func TestNewTxParserCatchUpWindow(t *testing.T) {
+	storage := store.NewMemoryStore()
+	storage.SetCurrentBlock(50)
+	blockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+
+	parser := parser.NewTxParser(storage, blockchain, parser.WithMaxCatchUp(20))
+
+	status := parser.GetSyncStatus()
+	if status.CurrentBlock != 80 || status.NetworkBlock != 100 || status.Lag != 20 {
+		t.Errorf("Expected to catch up from block 80 with a lag of 20, got %+v", status)
+	}
+}
+
+func TestNewTxParserStartFromHead(t *testing.T) {
+	storage := store.NewMemoryStore()
+	storage.SetCurrentBlock(50)
+	blockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+
+	parser := parser.NewTxParser(storage, blockchain, parser.WithStartFromHead())
+
+	if status := parser.GetSyncStatus(); status.CurrentBlock != 100 || status.Lag != 0 {
+		t.Errorf("Expected to start from block 100 without lag, got %+v", status)
+	}
+}
+
+func TestSubscribeFromBackfills(t *testing.T) {
+	storage := store.NewMemoryStore()
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			return store.Block{
+				BlockHeader: store.BlockHeader{Number: block, Hash: "0xb" + strconv.Itoa(block), ParentHash: "0xb" + strconv.Itoa(block-1)},
+				Transactions: []store.Transaction{
+					{Hash: "0x" + strconv.Itoa(block), From: "0xabc", To: "0xdef", Value: "500", BlockNumber: strconv.Itoa(block)},
+				},
+			}, nil
+		},
+	}
+	p := parser.NewTxParser(storage, mockBlockchain)
+	p.Subscribe("0xdef")
+	// Block 100 got polled after the subscription of 0xabc, the backfill must not duplicate its transaction.
+	storage.SaveBlock(store.Block{
+		BlockHeader:  store.BlockHeader{Number: 100, Hash: "0xb100", ParentHash: "0xb99"},
+		Transactions: []store.Transaction{{Hash: "0x100", From: "0xabc", To: "0xdef", Value: "500", BlockNumber: "100"}},
+	})
+
+	if subscribed, err := p.SubscribeWithOptions("0xabc", parser.SubscribeOptions{FromBlock: 96}); !subscribed || err != nil {
+		t.Errorf("Expected subscription to succeed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	go p.StartPolling(ctx)
+
+	<-ctx.Done()
+
+	jobs := p.GetBackfillJobs("0xabc")
+	if len(jobs) != 1 || jobs[0].Status != store.BackfillCompleted || jobs[0].ScannedBlock != 100 {
+		t.Errorf("Expected backfill job to complete at block 100, got %+v", jobs)
+	}
+	if transactions := p.GetTransactions("0xabc"); len(transactions) != 5 {
+		t.Errorf("Expected 5 backfilled transactions, got %d", len(transactions))
+	}
+	if transactions := p.GetTransactions("0xdef"); len(transactions) != 1 {
+		t.Errorf("Expected the transactions of other subscribers to be left untouched, got %d", len(transactions))
+	}
+}
+
+func TestSubscribeFromGenesisLocatesFirstActivity(t *testing.T) {
+	storage := store.NewMemoryStore()
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			return store.Block{
+				BlockHeader:  store.BlockHeader{Number: block, Hash: "0xb" + strconv.Itoa(block), ParentHash: "0xb" + strconv.Itoa(block-1)},
+				Transactions: []store.Transaction{{Hash: "0x" + strconv.Itoa(block), From: "0xdef", To: "0xabc", Value: "500", BlockNumber: strconv.Itoa(block)}},
+			}, nil
+		},
+		// 0xabc receives ether at block 40 and sends its first transaction at block 60.
+		TransactionCountFunc: func(ctx context.Context, address store.Address, block int) (int, error) {
+			if block >= 60 {
+				return 1, nil
+			}
+			return 0, nil
+		},
+		BalanceFunc: func(ctx context.Context, address store.Address, block int) (*store.Quantity, error) {
+			if block >= 40 {
+				return store.NewQuantity(500), nil
+			}
+			return store.NewQuantity(0), nil
+		},
+	}
+	p := parser.NewTxParser(storage, mockBlockchain)
+
+	if subscribed, err := p.SubscribeWithOptions("0xabc", parser.SubscribeOptions{FromGenesis: true}); !subscribed || err != nil {
+		t.Fatalf("Expected subscription to succeed, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	go p.StartPolling(ctx)
+
+	<-ctx.Done()
+
+	jobs := p.GetBackfillJobs("0xabc")
+	if len(jobs) != 1 || jobs[0].Status != store.BackfillCompleted || jobs[0].FromBlock != 40 || jobs[0].ScannedBlock != 100 {
+		t.Errorf("Expected the backfill to start from block 40 and complete, got %+v", jobs)
+	}
+	if len(jobs) == 1 && !jobs[0].Approximate {
+		t.Errorf("Expected the start located from the balance to be approximate")
+	}
+	if transactions := p.GetTransactions("0xabc"); len(transactions) != 61 {
+		t.Errorf("Expected 61 backfilled transactions, got %d", len(transactions))
+	}
+	if stored := storage.BackfillJobs(); len(stored) != 1 || stored[0] != jobs[0] {
+		t.Errorf("Expected the completed job to be stored, got %+v", stored)
+	}
+}
+
+func TestSubscribeFromGenesisNeverActive(t *testing.T) {
+	storage := store.NewMemoryStore()
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			return store.Block{BlockHeader: store.BlockHeader{Number: block, Hash: "0xb" + strconv.Itoa(block), ParentHash: "0xb" + strconv.Itoa(block-1)}}, nil
+		},
+		// 0xabc only ever holds tokens, it never sends a transaction nor holds ether.
+		TransactionCountFunc: func(ctx context.Context, address store.Address, block int) (int, error) { return 0, nil },
+		BalanceFunc: func(ctx context.Context, address store.Address, block int) (*store.Quantity, error) {
+			return store.NewQuantity(0), nil
+		},
+	}
+	p := parser.NewTxParser(storage, mockBlockchain)
+
+	if subscribed, err := p.SubscribeWithOptions("0xabc", parser.SubscribeOptions{FromGenesis: true}); !subscribed || err != nil {
+		t.Fatalf("Expected subscription to succeed, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	go p.StartPolling(ctx)
+
+	<-ctx.Done()
+
+	jobs := p.GetBackfillJobs("0xabc")
+	if len(jobs) != 1 || jobs[0].Status != store.BackfillUnsupported || jobs[0].ScannedBlock != 0 {
+		t.Errorf("Expected the backfill to be marked unsupported without scanning, got %+v", jobs)
+	}
+	if stored := storage.BackfillJobs(); len(stored) != 1 || stored[0].Status != store.BackfillUnsupported {
+		t.Errorf("Expected the unsupported job to be stored, got %+v", stored)
+	}
+}
+
+func TestMaxBackfillBlocks(t *testing.T) {
+	storage := store.NewMemoryStore()
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			return store.Block{
+				BlockHeader:  store.BlockHeader{Number: block, Hash: "0xb" + strconv.Itoa(block), ParentHash: "0xb" + strconv.Itoa(block-1)},
+				Transactions: []store.Transaction{{Hash: "0x" + strconv.Itoa(block), From: "0xabc", To: "0xdef", Value: "500", BlockNumber: strconv.Itoa(block)}},
+			}, nil
+		},
+		// 0xabc sends its first transaction at block 10.
+		TransactionCountFunc: func(ctx context.Context, address store.Address, block int) (int, error) {
+			if block >= 10 {
+				return 1, nil
+			}
+			return 0, nil
+		},
+		BalanceFunc: func(ctx context.Context, address store.Address, block int) (*store.Quantity, error) {
+			return store.NewQuantity(0), nil
+		},
+	}
+	p := parser.NewTxParser(storage, mockBlockchain, parser.WithMaxBackfillBlocks(20))
+
+	if subscribed, err := p.SubscribeWithOptions("0xdef", parser.SubscribeOptions{FromBlock: 80}); subscribed || !errors.Is(err, parser.ErrBackfillTooLong) {
+		t.Errorf("Expected the backfill from block 80 to be rejected, got %v %v", subscribed, err)
+	}
+	if subscribed, err := p.SubscribeWithOptions("0xdef", parser.SubscribeOptions{FromBlock: 81}); !subscribed || err != nil {
+		t.Errorf("Expected the backfill from block 81 to be accepted, got %v", err)
+	}
+	if subscribed, err := p.SubscribeWithOptions("0xabc", parser.SubscribeOptions{FromGenesis: true}); !subscribed || err != nil {
+		t.Fatalf("Expected subscription to succeed, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	go p.StartPolling(ctx)
+
+	<-ctx.Done()
+
+	jobs := p.GetBackfillJobs("0xabc")
+	if len(jobs) != 1 || jobs[0].Status != store.BackfillCompleted || jobs[0].FromBlock != 81 || !jobs[0].Approximate {
+		t.Errorf("Expected the backfill to be cut to start from block 81 and be approximate, got %+v", jobs)
+	}
+}
+
+func TestSubscribeFromGenesisUnsupported(t *testing.T) {
+	storage := store.NewMemoryStore()
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	// Embedding the interface hides the account history methods of the mock.
+	p := parser.NewTxParser(storage, struct{ blockchain.IBlockchain }{mockBlockchain})
+
+	if subscribed, err := p.SubscribeWithOptions("0xabc", parser.SubscribeOptions{FromGenesis: true}); subscribed || !errors.Is(err, parser.ErrBackfillUnsupported) {
+		t.Errorf("Expected the subscription to be rejected, got %v %v", subscribed, err)
+	}
+	if _, subscribed := storage.Subscription("0xabc"); subscribed {
+		t.Errorf("Expected the address not to be subscribed")
+	}
+}
+
+func TestBackfillResumesPersistedJob(t *testing.T) {
+	storage := store.NewMemoryStore()
+	storage.SetCurrentBlock(100)
+	storage.Subscribe(store.Subscription{Address: "0xabc"})
+	// The job was interrupted after scanning block 94.
+	storage.SaveBackfillJob(store.BackfillJob{Address: "0xabc", FromBlock: 90, ToBlock: 99, ScannedBlock: 94, Status: store.BackfillRunning})
+	var fetched []int
+	var mu sync.Mutex
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			mu.Lock()
+			fetched = append(fetched, block)
+			mu.Unlock()
+			return store.Block{
+				BlockHeader:  store.BlockHeader{Number: block, Hash: "0xb" + strconv.Itoa(block), ParentHash: "0xb" + strconv.Itoa(block-1)},
+				Transactions: []store.Transaction{{Hash: "0x" + strconv.Itoa(block), From: "0xabc", To: "0xdef", Value: "500", BlockNumber: strconv.Itoa(block)}},
+			}, nil
+		},
+	}
+	p := parser.NewTxParser(storage, mockBlockchain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	go p.StartPolling(ctx)
+
+	<-ctx.Done()
+
+	jobs := p.GetBackfillJobs("0xabc")
+	if len(jobs) != 1 || jobs[0].Status != store.BackfillCompleted || jobs[0].ScannedBlock != 100 {
+		t.Errorf("Expected the persisted job to resume and complete, got %+v", jobs)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(fetched) == 0 || fetched[0] != 95 {
+		t.Errorf("Expected the backfill to resume from block 95, got %v", fetched)
+	}
+	if transactions := p.GetTransactions("0xabc"); len(transactions) != 6 {
+		t.Errorf("Expected 6 backfilled transactions, got %d", len(transactions))
+	}
+}
+
+// jobRecorder records the backfill jobs saved to the store, which forgets the jobs of the unsubscribed addresses.
+type jobRecorder struct {
+	*store.MemoryStore
+	mu    sync.Mutex
+	saved []store.BackfillJob
+}
+
+func (r *jobRecorder) SaveBackfillJob(job store.BackfillJob) error {
+	r.mu.Lock()
+	r.saved = append(r.saved, job)
+	r.mu.Unlock()
+	return r.MemoryStore.SaveBackfillJob(job)
+}
+
+func TestBackfillSavesCanceledJob(t *testing.T) {
+	for name, options := range map[string]parser.SubscribeOptions{
+		"scanning": {FromBlock: 90},
+		"locating": {FromGenesis: true},
+	} {
+		storage := &jobRecorder{MemoryStore: store.NewMemoryStore()}
+		var p *parser.TxParser
+		var unsubscribe sync.Once
+		// The address is unsubscribed while its backfill fetches a block or locates its first activity.
+		mockBlockchain := &mock.BlockchainMock{
+			LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+			ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+				unsubscribe.Do(func() { p.Unsubscribe("0xabc", false) })
+				return store.Block{BlockHeader: store.BlockHeader{Number: block}}, nil
+			},
+			TransactionCountFunc: func(ctx context.Context, address store.Address, block int) (int, error) {
+				unsubscribe.Do(func() { p.Unsubscribe("0xabc", false) })
+				return 0, nil
+			},
+			BalanceFunc: func(ctx context.Context, address store.Address, block int) (*store.Quantity, error) {
+				return store.NewQuantity(0), nil
+			},
+		}
+		p = parser.NewTxParser(storage, mockBlockchain)
+		p.SubscribeWithOptions("0xabc", options)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		go p.StartPolling(ctx)
+		<-ctx.Done()
+		cancel()
+
+		storage.mu.Lock()
+		if last := storage.saved[len(storage.saved)-1]; last.Status != store.BackfillCanceled {
+			t.Errorf("Expected the canceled job to be saved while %s, got %+v", name, last)
+		}
+		storage.mu.Unlock()
+	}
+}
+
+func TestStreamTransactionsOnce(t *testing.T) {
+	storage := store.NewMemoryStore()
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(storage, mockBlockchain)
+	p.Subscribe("0xabc")
+	p.Subscribe("0xdef")
+	block := store.Block{
+		BlockHeader:  store.BlockHeader{Number: 80, Hash: "0xb80"},
+		Transactions: []store.Transaction{{Hash: "0xa", From: "0xabc", To: "0x123", BlockNumber: "80"}},
+	}
+	storage.SaveBlock(block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	stream := p.StreamTransactions(ctx, []store.Address{"0xabc", "0xdef"}, 0)
+	if tx := <-stream; tx.Hash != "0xa" {
+		t.Fatalf("Expected transaction 0xa to be streamed, got %+v", tx)
+	}
+
+	// The block is rolled back and recorded again, its transaction gets a new sequence number.
+	storage.RemoveBlock(80)
+	storage.SaveBlock(block)
+	// The backfill of a streamed address records an older transaction, which is not streamed.
+	storage.BackfillTransactions("0xdef", store.Block{
+		BlockHeader:  store.BlockHeader{Number: 70},
+		Transactions: []store.Transaction{{Hash: "0xc", From: "0xabc", To: "0xdef", BlockNumber: "70"}},
+	})
+	storage.SaveBlock(store.Block{
+		BlockHeader:  store.BlockHeader{Number: 81, Hash: "0xb81"},
+		Transactions: []store.Transaction{{Hash: "0xb", From: "0x123", To: "0xdef", BlockNumber: "81"}},
+	})
+
+	if tx := <-stream; tx.Hash != "0xb" {
+		t.Errorf("Expected the recorded again and the backfilled transactions to be skipped, got %+v", tx)
+	}
+}
+
+func TestStartPollingWaitsForWorkers(t *testing.T) {
+	storage := store.NewMemoryStore()
+	var backfillStopped atomic.Bool
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		// The backfill keeps writing for a while after the shutdown.
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			<-ctx.Done()
+			time.Sleep(50 * time.Millisecond)
+			backfillStopped.Store(true)
+			return store.Block{}, ctx.Err()
+		},
+	}
+	p := parser.NewTxParser(storage, mockBlockchain)
+	p.SubscribeWithOptions("0xabc", parser.SubscribeOptions{FromBlock: 90})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	p.StartPolling(ctx)
+
+	if !backfillStopped.Load() {
+		t.Error("Expected polling to stop once the backfill worker stopped")
+	}
+}
+
+// checkpointStore records the blocks the current block is moved to.
+type checkpointStore struct {
+	*store.MemoryStore
+	checkpoints []int
+	mu          sync.Mutex
+}
+
+func (c *checkpointStore) SetCurrentBlock(blockNumber int) error {
+	c.mu.Lock()
+	c.checkpoints = append(c.checkpoints, blockNumber)
+	c.mu.Unlock()
+	return c.MemoryStore.SetCurrentBlock(blockNumber)
+}
+
+func TestStartPollingConcurrentFetchOrderedCommit(t *testing.T) {
+	storage := &checkpointStore{MemoryStore: store.NewMemoryStore()}
+	var fetching, maxFetching int32
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			current := atomic.AddInt32(&fetching, 1)
+			defer atomic.AddInt32(&fetching, -1)
+			for {
+				observed := atomic.LoadInt32(&maxFetching)
+				if current <= observed || atomic.CompareAndSwapInt32(&maxFetching, observed, current) {
+					break
+				}
+			}
+			// Later blocks are fetched faster, so they complete out of order.
+			time.Sleep(time.Duration(140-block) * 100 * time.Microsecond)
+			return store.Block{
+				BlockHeader: store.BlockHeader{Number: block, Hash: "0xb" + strconv.Itoa(block), ParentHash: "0xb" + strconv.Itoa(block-1)},
+				Transactions: []store.Transaction{
+					{Hash: "0x" + strconv.Itoa(block), From: "0xabc", To: "0xdef", Value: "500", BlockNumber: strconv.Itoa(block)},
+				},
+			}, nil
+		},
+	}
+	parser := parser.NewTxParser(storage, mockBlockchain, parser.WithConcurrency(4, 8), parser.WithBatchSize(3))
+	mockBlockchain.LatestNetworkBlockFunc = func(ctx context.Context) (int, error) { return 140, nil }
+	parser.Subscribe("0xabc")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	go parser.StartPolling(ctx)
+
+	<-ctx.Done()
+
+	if parser.GetCurrentBlock() != 140 {
+		t.Errorf("Expected current block to be updated to 140, got %d", parser.GetCurrentBlock())
+	}
+	if max := atomic.LoadInt32(&maxFetching); max > 4 {
+		t.Errorf("Expected at most 4 blocks fetched at the same time, got %d", max)
+	}
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	for i, checkpoint := range storage.checkpoints[1:] {
+		if checkpoint != storage.checkpoints[i]+1 {
+			t.Fatalf("Expected blocks to be committed in order, got %v", storage.checkpoints)
+		}
+	}
+	if transactions := parser.GetTransactions("0xabc"); len(transactions) != 40 {
+		t.Errorf("Expected 40 transactions, got %d", len(transactions))
+	}
+}
+
+func TestStartPollingRetriesFailedBlock(t *testing.T) {
+	storage := store.NewMemoryStore()
+	var recovered atomic.Bool
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			if block == 102 && !recovered.Load() {
+				return store.Block{}, errors.New("rate limited")
+			}
+			return store.Block{
+				BlockHeader: store.BlockHeader{Number: block, Hash: "0xb" + strconv.Itoa(block), ParentHash: "0xb" + strconv.Itoa(block-1)},
+				Transactions: []store.Transaction{
+					{Hash: "0x" + strconv.Itoa(block), From: "0xabc", To: "0xdef", Value: "500", BlockNumber: strconv.Itoa(block)},
+				},
+			}, nil
+		},
+	}
+	p := parser.NewTxParser(storage, mockBlockchain, parser.WithRetryBackoff(10*time.Millisecond, 20*time.Millisecond))
+	mockBlockchain.LatestNetworkBlockFunc = func(ctx context.Context) (int, error) { return 105, nil }
+	p.Subscribe("0xabc")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go p.StartPolling(ctx)
+
+	time.Sleep(200 * time.Millisecond)
+	if block := p.GetCurrentBlock(); block != 101 {
+		t.Errorf("Expected current block to stay before the failed block, got %d", block)
+	}
+	stuck := p.GetStuckBlocks()
+	if len(stuck) != 1 || stuck[0].Number != 102 || stuck[0].Attempts < 2 || stuck[0].LastError != "rate limited" {
+		t.Errorf("Expected block 102 to be retried, got %+v", stuck)
+	}
+	if status := p.GetSyncStatus(); status.StuckBlocks != 1 {
+		t.Errorf("Expected 1 stuck block in the sync status, got %d", status.StuckBlocks)
+	}
+
+	recovered.Store(true)
+	time.Sleep(200 * time.Millisecond)
+	if block := p.GetCurrentBlock(); block != 105 {
+		t.Errorf("Expected current block to be updated to 105 once recovered, got %d", block)
+	}
+	if stuck := p.GetStuckBlocks(); len(stuck) != 0 {
+		t.Errorf("Expected no stuck blocks once recovered, got %+v", stuck)
+	}
+	if transactions := p.GetTransactions("0xabc"); len(transactions) != 5 {
+		t.Errorf("Expected 5 transactions, got %d", len(transactions))
+	}
+}
+
+func TestStartPollingWaitsForRetryOfLaterBlock(t *testing.T) {
+	storage := store.NewMemoryStore()
+	var failedOnce atomic.Bool
+	var mu sync.Mutex
+	var fetchedAt, failedAt []time.Time
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			switch {
+			case block == 101 && !failedOnce.Swap(true):
+				return store.Block{}, errors.New("rate limited")
+			case block == 103:
+				// Block 103 fails after block 101 but before its retry, so it is due for a retry later.
+				mu.Lock()
+				fetchedAt = append(fetchedAt, time.Now())
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				failedAt = append(failedAt, time.Now())
+				mu.Unlock()
+				return store.Block{}, errors.New("header not found")
+			}
+			return store.Block{BlockHeader: store.BlockHeader{Number: block, Hash: "0xb" + strconv.Itoa(block), ParentHash: "0xb" + strconv.Itoa(block-1)}}, nil
+		},
+	}
+	p := parser.NewTxParser(storage, mockBlockchain, parser.WithRetryBackoff(50*time.Millisecond, 50*time.Millisecond), parser.WithBatchSize(1))
+	mockBlockchain.LatestNetworkBlockFunc = func(ctx context.Context) (int, error) { return 105, nil }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	p.StartPolling(ctx)
+
+	if block := p.GetCurrentBlock(); block != 102 {
+		t.Errorf("Expected current block to stay before block 103, got %d", block)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(fetchedAt) < 2 {
+		t.Fatalf("Expected block 103 to be retried, got %d attempts", len(fetchedAt))
+	}
+	for i := 1; i < len(fetchedAt); i++ {
+		if i > len(failedAt) || fetchedAt[i].Before(failedAt[i-1].Add(50*time.Millisecond)) {
+			t.Errorf("Expected attempt %d at block 103 to wait for its retry", i+1)
+		}
+	}
+}
+
+func TestNewTxParserResumesOnFirstPoll(t *testing.T) {
+	storage := store.NewMemoryStore()
+	var reachable atomic.Bool
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) {
+			if !reachable.Load() {
+				return 0, errors.New("connection refused")
+			}
+			return 100, nil
+		},
+	}
+	p := parser.NewTxParser(storage, mockBlockchain)
+	if block := p.GetCurrentBlock(); block != 0 {
+		t.Errorf("Expected current block to be left unset, got %d", block)
+	}
+
+	reachable.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	go p.StartPolling(ctx)
+
+	<-ctx.Done()
+
+	if block := p.GetCurrentBlock(); block != 100 {
+		t.Errorf("Expected polling to start from the latest block 100, got %d", block)
+	}
+}
+
+// headSource announces the heads sent on the channel.
+type headSource chan int
+
+func (h headSource) SubscribeHeads(ctx context.Context) <-chan int {
+	return h
+}
+
+func TestStartPollingAnnouncedHead(t *testing.T) {
+	storage := store.NewMemoryStore()
+	storage.SetCurrentBlock(100)
+	mockBlockchain := &mock.BlockchainMock{
+		// The JSON-RPC endpoint lags behind the announced head.
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			return store.Block{BlockHeader: store.BlockHeader{Number: block}}, nil
+		},
+	}
+	heads := make(headSource, 1)
+	p := parser.NewTxParser(storage, mockBlockchain, parser.WithHeadSource(heads))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go p.StartPolling(ctx)
+
+	time.Sleep(100 * time.Millisecond)
+	heads <- 101
+	for p.GetCurrentBlock() != 101 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if block := p.GetCurrentBlock(); block != 101 {
+		t.Errorf("Expected the announced block 101 to be processed before the next poll, got %d", block)
+	}
+}
+
+func TestMempool(t *testing.T) {
+	const sender = "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"
+	const recipient = "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"
+	storage := store.NewMemoryStore()
+	storage.SetCurrentBlock(100)
+	replacement := store.Transaction{Hash: "0xa2", From: sender, To: recipient, Value: "0x1", Nonce: "0x7"}
+	var mu sync.Mutex
+	pool := []store.Transaction{
+		{Hash: "0xa", From: sender, To: recipient, Value: "0x1", Nonce: "0x7"},
+		{Hash: "0xb", From: sender, To: recipient, Value: "0x1", Nonce: "0x8"},
+		{Hash: "0xc", From: "0xabc", To: "0xdef", Value: "0x1", Nonce: "0x1"},
+	}
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			mined := replacement
+			mined.BlockNumber = strconv.Itoa(block)
+			return store.Block{BlockHeader: store.BlockHeader{Number: block}, Transactions: []store.Transaction{mined}}, nil
+		},
+		PendingTransactionsFunc: func(ctx context.Context) ([]store.Transaction, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]store.Transaction(nil), pool...), nil
+		},
+	}
+	heads := make(headSource, 1)
+	p := parser.NewTxParser(storage, mockBlockchain, parser.WithHeadSource(heads),
+		parser.WithMempool(mockBlockchain, 10*time.Millisecond, 50*time.Millisecond))
+	p.Subscribe(sender)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go p.StartPolling(ctx)
+
+	for len(p.GetTransactions(sender, store.StatusPending)) != 2 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pending := p.GetTransactions(sender, store.StatusPending); len(pending) != 2 || pending[0].Status != store.StatusPending {
+		t.Fatalf("Expected the 2 pending transactions of the subscribed address, got %+v", pending)
+	}
+
+	// The transaction with nonce 7 gets replaced, the one with nonce 8 leaves the mempool without being mined.
+	mu.Lock()
+	pool = []store.Transaction{replacement}
+	mu.Unlock()
+
+	statuses := func() map[string]parser.PendingTransaction {
+		byHash := make(map[string]parser.PendingTransaction)
+		for _, tx := range p.GetPendingTransactions(sender) {
+			byHash[tx.Hash] = tx
+		}
+		return byHash
+	}
+	for statuses()["0xb"].Status != store.StatusDropped && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	byHash := statuses()
+	if tx := byHash["0xa"]; tx.Status != store.StatusReplaced || tx.ReplacedBy != "0xa2" {
+		t.Errorf("Expected 0xa to be replaced by 0xa2, got %+v", tx)
+	}
+	if tx := byHash["0xb"]; tx.Status != store.StatusDropped {
+		t.Errorf("Expected 0xb to be dropped, got %+v", tx)
+	}
+
+	// Once mined, the replacement is reported with the transactions of its block.
+	heads <- 101
+	for p.GetCurrentBlock() != 101 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pending := p.GetTransactions(sender, store.StatusPending); len(pending) != 0 {
+		t.Errorf("Expected no pending transactions left, got %+v", pending)
+	}
+	if mined := p.GetTransactions(sender); len(mined) != 1 || mined[0].Hash != "0xa2" || mined[0].Status != store.StatusPendingConfirmation {
+		t.Errorf("Expected the mined replacement, got %+v", mined)
+	}
+}
+
+func TestMempoolReplacementNotInvolvingSubscribed(t *testing.T) {
+	const sender = "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"
+	const recipient = "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"
+	storage := store.NewMemoryStore()
+	storage.SetCurrentBlock(100)
+	// The sender cancels the payment to the subscribed recipient by sending itself a transaction with the same nonce.
+	cancellation := store.Transaction{Hash: "0xa2", From: sender, To: sender, Value: "0x0", Nonce: "0x7", BlockNumber: "101"}
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			return store.Block{BlockHeader: store.BlockHeader{Number: block}, Transactions: []store.Transaction{cancellation}}, nil
+		},
+		PendingTransactionsFunc: func(ctx context.Context) ([]store.Transaction, error) {
+			return []store.Transaction{{Hash: "0xa", From: sender, To: recipient, Value: "0x1", Nonce: "0x7"}}, nil
+		},
+	}
+	heads := make(headSource, 1)
+	p := parser.NewTxParser(storage, mockBlockchain, parser.WithHeadSource(heads),
+		parser.WithMempool(mockBlockchain, 10*time.Millisecond, time.Minute))
+	p.Subscribe(recipient)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go p.StartPolling(ctx)
+
+	for len(p.GetTransactions(recipient, store.StatusPending)) != 1 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	heads <- 101
+	for p.GetCurrentBlock() != 101 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	pending := p.GetPendingTransactions(recipient)
+	if len(pending) != 1 || pending[0].Status != store.StatusReplaced || pending[0].ReplacedBy != "0xa2" {
+		t.Errorf("Expected 0xa to be replaced by the cancellation, got %+v", pending)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	const address = "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"
+	type request struct {
+		path      string
+		event     string
+		signature string
+		body      []byte
+	}
+	var mu sync.Mutex
+	var requests []request
+	failing := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, request{r.URL.Path, r.Header.Get(parser.EventHeader), r.Header.Get(parser.SignatureHeader), body})
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	storage := store.NewMemoryStore()
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			return store.Block{
+				BlockHeader:  store.BlockHeader{Number: block},
+				Transactions: []store.Transaction{{Hash: "0xa" + strconv.Itoa(block), From: address, To: "0xdef", Value: "0x1", BlockNumber: strconv.Itoa(block)}},
+			}, nil
+		},
+	}
+	p := parser.NewTxParser(storage, mockBlockchain, parser.WithConfirmations(1), parser.WithWebhookRetry(10*time.Millisecond, 20*time.Millisecond, 2))
+	if _, registered, _ := p.RegisterWebhook(address, parser.WebhookOptions{URL: server.URL}); registered {
+		t.Fatal("Expected the webhook of an address not subscribed to be rejected")
+	}
+	p.Subscribe(address)
+	webhook, _, _ := p.RegisterWebhook(address, parser.WebhookOptions{URL: server.URL + "/tx", Events: []store.EventType{store.EventTransaction}, Secret: "s3cret"})
+	p.RegisterWebhook(address, parser.WebhookOptions{URL: server.URL + "/withdrawals", Events: []store.EventType{store.EventWithdrawal}})
+	if webhooks := p.GetWebhooks(address); len(webhooks) != 2 || webhooks[0].Secret != "" {
+		t.Errorf("Expected the webhooks without their secrets, got %+v", webhooks)
+	}
+	mockBlockchain.LatestNetworkBlockFunc = func(ctx context.Context) (int, error) { return 101, nil }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go p.StartPolling(ctx)
+
+	// The endpoint fails both attempts, the delivery is moved to the dead letters.
+	for len(p.GetDeadLetters(webhook.ID)) == 0 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	deadLetters := p.GetDeadLetters(webhook.ID)
+	if len(deadLetters) != 1 || deadLetters[0].Attempts != 2 || deadLetters[0].Event.Type != store.EventTransaction {
+		t.Fatalf("Expected the failed delivery in the dead letters, got %+v", deadLetters)
+	}
+	attempts := p.GetWebhookDeliveries(webhook.ID)
+	if len(attempts) != 2 || attempts[0].State != store.DeliveryFailed || attempts[1].State != store.DeliveryPending || attempts[0].StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected the 2 failed attempts, the most recent first, got %+v", attempts)
+	}
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	if replayed := p.ReplayDeadLetters(webhook.ID, ""); replayed != 1 {
+		t.Fatalf("Expected the dead letter to be replayed, got %d", replayed)
+	}
+	for len(p.GetWebhookDeliveries(webhook.ID)) != 3 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if attempts := p.GetWebhookDeliveries(webhook.ID); len(attempts) != 3 || attempts[0].State != store.DeliveryDelivered {
+		t.Fatalf("Expected the replayed delivery to succeed, got %+v", attempts)
+	}
+	if deadLetters := p.GetDeadLetters(webhook.ID); len(deadLetters) != 0 {
+		t.Errorf("Expected no dead letters left, got %+v", deadLetters)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	last := requests[len(requests)-1]
+	var event struct {
+		Type    store.EventType   `json:"type"`
+		Address store.Address     `json:"address"`
+		Data    store.Transaction `json:"data"`
+	}
+	json.Unmarshal(last.body, &event)
+	if last.path != "/tx" || last.event != "transaction" || event.Address != address || event.Data.Hash != "0xa101" {
+		t.Errorf("Expected the transaction of block 101 to be posted, got %s %s %s", last.path, last.event, last.body)
+	}
+	if last.signature != parser.SignPayload("s3cret", last.body) {
+		t.Errorf("Expected the payload to be signed with the secret, got %q", last.signature)
+	}
+	for _, r := range requests {
+		if r.path != "/tx" {
+			t.Errorf("Expected no event posted to the withdrawal webhook, got %s", r.body)
+		}
+	}
+}
+
+func TestWebhooksSkipReprocessedBlocks(t *testing.T) {
+	const address = "0xabc"
+	var mu sync.Mutex
+	var hashes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event struct {
+			Data store.Transaction `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&event)
+		mu.Lock()
+		defer mu.Unlock()
+		hashes = append(hashes, event.Data.Hash)
+	}))
+	defer server.Close()
+
+	storage := store.NewMemoryStore()
+	block := func(number int) store.Block {
+		return store.Block{
+			BlockHeader:  store.BlockHeader{Number: number},
+			Transactions: []store.Transaction{{Hash: "0xa" + strconv.Itoa(number), From: address, To: "0xdef", Value: "0x1", BlockNumber: strconv.Itoa(number)}},
+		}
+	}
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc:         func(ctx context.Context, number int) (store.Block, error) { return block(number), nil },
+	}
+	p := parser.NewTxParser(storage, mockBlockchain, parser.WithConfirmations(1))
+	p.Subscribe(address)
+	p.RegisterWebhook(address, parser.WebhookOptions{URL: server.URL})
+	// Block 101 was stored before a restart, which happened before the current block moved past it.
+	storage.SaveBlock(block(101))
+	mockBlockchain.LatestNetworkBlockFunc = func(ctx context.Context) (int, error) { return 102, nil }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go p.StartPolling(ctx)
+
+	for ctx.Err() == nil {
+		mu.Lock()
+		delivered := len(hashes)
+		mu.Unlock()
+		if delivered > 0 && p.GetCurrentBlock() == 102 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(hashes) != "[0xa102]" {
+		t.Errorf("Expected only the transaction of block 102 to be delivered, got %v", hashes)
+	}
+}
+
+func TestWebhooksHoldUnconfirmedBlocks(t *testing.T) {
+	const address = "0xabc"
+	var mu sync.Mutex
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event struct {
+			Data store.Transaction `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&event)
+		mu.Lock()
+		posted = append(posted, event.Data.Hash+" "+string(event.Data.Status))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	storage := store.NewMemoryStore()
+	storage.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: 100, Hash: "a100"}})
+	storage.SetCurrentBlock(100)
+	// Block 101 is first produced on fork "a", which gets replaced by fork "b" before it is confirmed.
+	fork := "a"
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+		ParseBlockFunc: func(ctx context.Context, block int) (store.Block, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			name, parent := fork+strconv.Itoa(block), fork+strconv.Itoa(block-1)
+			if block <= 100 {
+				name = "a" + strconv.Itoa(block)
+			}
+			if block <= 101 {
+				parent = "a" + strconv.Itoa(block-1)
+			}
+			return store.Block{
+				BlockHeader:  store.BlockHeader{Number: block, Hash: name, ParentHash: parent},
+				Transactions: []store.Transaction{{Hash: "0x" + name, From: address, To: "0xdef", BlockNumber: strconv.Itoa(block)}},
+			}, nil
+		},
+	}
+	heads := make(headSource, 1)
+	p := parser.NewTxParser(storage, mockBlockchain, parser.WithConfirmations(2), parser.WithHeadSource(heads))
+	p.Subscribe(address)
+	p.RegisterWebhook(address, parser.WebhookOptions{URL: server.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go p.StartPolling(ctx)
+
+	time.Sleep(100 * time.Millisecond)
+	heads <- 101
+	for p.GetCurrentBlock() != 101 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	fork = "b"
+	mu.Unlock()
+	heads <- 103
+	for p.GetCurrentBlock() != 103 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(posted)
+	// The events of the orphaned block 101 and of the unconfirmed block 103 are not delivered.
+	if got, expected := fmt.Sprint(posted), "[0xb101 confirmed 0xb102 confirmed]"; got != expected {
+		t.Errorf("Expected the events of the confirmed canonical blocks %s, got %s", expected, got)
+	}
+}
+
+func TestWebhooksResumePersistedDeliveries(t *testing.T) {
+	const address = "0xabc"
+	posted := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted <- r.Header.Get(parser.DeliveryHeader)
+	}))
+	defer server.Close()
+
+	// The deliveries were persisted by a previous run, one still queued and one dead lettered.
+	storage := store.NewMemoryStore()
+	storage.SetCurrentBlock(100)
+	storage.Subscribe(store.Subscription{Address: address})
+	storage.SaveWebhook(store.Webhook{ID: "w1", Address: address, URL: server.URL, Secret: "s3cret"})
+	event := store.WebhookEvent{ID: "e1", Type: store.EventTransaction, Address: address, BlockNumber: 80, Data: json.RawMessage(`{}`)}
+	storage.SaveDeliveries([]store.Delivery{
+		{ID: "queued", WebhookID: "w1", Event: event, State: store.DeliveryPending, Attempts: 1, NextAttempt: time.Now()},
+		{ID: "dead", WebhookID: "w1", Event: event, State: store.DeliveryFailed, Attempts: 8},
+	})
+	mockBlockchain := &mock.BlockchainMock{
+		LatestNetworkBlockFunc: func(ctx context.Context) (int, error) { return 100, nil },
+	}
+	p := parser.NewTxParser(storage, mockBlockchain)
+	if deadLetters := p.GetDeadLetters("w1"); len(deadLetters) != 1 || deadLetters[0].ID != "dead" {
+		t.Errorf("Expected the dead letter to be restored, got %+v", deadLetters)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go p.StartPolling(ctx)
+
+	select {
+	case id := <-posted:
+		if id != "queued" {
+			t.Errorf("Expected the queued delivery to be posted, got %s", id)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected the queued delivery to be posted")
+	}
+	for len(storage.Deliveries()) != 1 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if deliveries := storage.Deliveries(); len(deliveries) != 1 || deliveries[0].ID != "dead" {
+		t.Errorf("Expected the delivered event to leave the store, got %+v", deliveries)
+	}
+}
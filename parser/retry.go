@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRetryBackoff is the delay before the first retry of a block that could not be fetched.
+	defaultRetryBackoff = time.Second
+	// defaultMaxRetryBackoff caps the delay between the retries of a block, which doubles after every failure.
+	defaultMaxRetryBackoff = 5 * time.Minute
+)
+
+// FailedBlock describes a block that could not be fetched and waits to be retried.
+type FailedBlock struct {
+	// Number is the number of the block.
+	Number int `json:"number"`
+	// Attempts is the number of failed attempts at fetching the block.
+	Attempts int `json:"attempts"`
+	// LastError is the error of the latest attempt.
+	LastError string `json:"lastError"`
+	// NextRetry is the time the block can be fetched again.
+	NextRetry time.Time `json:"nextRetry"`
+}
+
+// retryQueue holds the blocks that could not be fetched, delaying their retries with an exponential backoff.
+type retryQueue struct {
+	// backoff is the delay before the first retry.
+	backoff time.Duration
+	// maxBackoff caps the delay between retries.
+	maxBackoff time.Duration
+	// blocks holds the failed blocks, indexed by block number.
+	blocks map[int]*FailedBlock
+	// mu guards the failed blocks.
+	mu sync.Mutex
+}
+
+func newRetryQueue(backoff time.Duration, maxBackoff time.Duration) *retryQueue {
+	return &retryQueue{
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+		blocks:     make(map[int]*FailedBlock),
+	}
+}
+
+// fail records a failed attempt at fetching the block and schedules its next retry.
+func (q *retryQueue) fail(blockNumber int, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	failed, exists := q.blocks[blockNumber]
+	if !exists {
+		failed = &FailedBlock{Number: blockNumber}
+		q.blocks[blockNumber] = failed
+	}
+	failed.Attempts++
+	failed.LastError = err.Error()
+
+	delay := q.backoff
+	for i := 1; i < failed.Attempts && delay < q.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > q.maxBackoff {
+		delay = q.maxBackoff
+	}
+	failed.NextRetry = time.Now().Add(delay)
+}
+
+// recover removes a block fetched successfully from the queue.
+func (q *retryQueue) recover(blockNumber int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.blocks, blockNumber)
+}
+
+// retryIn returns how long to wait before the block can be fetched, zero when it isn't waiting for a retry.
+func (q *retryQueue) retryIn(blockNumber int) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	failed, exists := q.blocks[blockNumber]
+	if !exists {
+		return 0
+	}
+	if wait := time.Until(failed.NextRetry); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// list returns the failed blocks ordered by block number.
+func (q *retryQueue) list() []FailedBlock {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	blocks := make([]FailedBlock, 0, len(q.blocks))
+	for _, failed := range q.blocks {
+		blocks = append(blocks, *failed)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].Number < blocks[j].Number
+	})
+	return blocks
+}
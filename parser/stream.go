@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+const (
+	// streamPollInterval is the longest delay between two checks of the store for new transactions, which are
+	// usually announced as soon as they are recorded.
+	streamPollInterval = time.Second
+	// streamBatchSize is the number of transactions read from the store at once.
+	streamBatchSize = 100
+)
+
+// recordNotifier announces that new records were stored to the goroutines waiting for them.
+type recordNotifier struct {
+	// recorded is closed when new records are stored, and replaced by a new channel.
+	recorded chan struct{}
+	// mu guards the channel.
+	mu sync.Mutex
+}
+
+func newRecordNotifier() *recordNotifier {
+	return &recordNotifier{recorded: make(chan struct{})}
+}
+
+// wait returns a channel closed when new records are stored.
+func (n *recordNotifier) wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.recorded
+}
+
+// notify wakes up the goroutines waiting for new records.
+func (n *recordNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	close(n.recorded)
+	n.recorded = make(chan struct{})
+}
+
+// GetLatestSequence returns the sequence number of the latest recorded transaction.
+func (p *TxParser) GetLatestSequence() uint64 {
+	return p.store.LatestSequence()
+}
+
+// StreamTransactions streams the transactions of the given addresses recorded after the sequence number, in the
+// order they are recorded, along with their status. The backfilled transactions are not streamed, and a transaction
+// recorded again under a new sequence number, such as after a reorganization, is only streamed once. The channel is
+// closed once the context is canceled.
+func (p *TxParser) StreamTransactions(ctx context.Context, addresses []store.Address, sequence uint64) <-chan store.Transaction {
+	stream := make(chan store.Transaction)
+	go func() {
+		defer close(stream)
+		// streamed holds the hashes of the streamed transactions within the reorg window, along with their block.
+		streamed := make(map[string]int)
+		latestBlock := 0
+		for {
+			// The channel is taken before reading the store, so the records stored in between are not missed.
+			recorded := p.records.wait()
+			transactions := p.store.TransactionsSince(addresses, sequence, streamBatchSize)
+			for _, tx := range transactions {
+				if _, sent := streamed[tx.Hash]; sent {
+					sequence = tx.Sequence
+					continue
+				}
+				tx.Status = p.blockStatus(tx.BlockHeight())
+				tx.Details = nil
+				select {
+				case <-ctx.Done():
+					return
+				case stream <- tx:
+				}
+				sequence = tx.Sequence
+				streamed[tx.Hash] = tx.BlockHeight()
+				latestBlock = max(latestBlock, tx.BlockHeight())
+			}
+			for hash, block := range streamed {
+				if block < latestBlock-store.ReorgWindow {
+					delete(streamed, hash)
+				}
+			}
+			if len(transactions) == streamBatchSize {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-recorded:
+			case <-time.After(streamPollInterval):
+			}
+		}
+	}()
+	return stream
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"context"
+
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+// fetchResult is the outcome of fetching a block.
+type fetchResult struct {
+	block store.Block
+	err   error
+}
+
+// syncBlocks processes the blocks following the current block up to the given block. Blocks are fetched
+// concurrently but committed in order, so the current block never moves past a block that wasn't processed.
+func (p *TxParser) syncBlocks(ctx context.Context, toBlock int) {
+	for ctx.Err() == nil && p.store.CurrentBlock() < toBlock {
+		if !p.syncRange(ctx, p.store.CurrentBlock()+1, toBlock) {
+			return
+		}
+	}
+}
+
+// syncRange fetches and commits the blocks of the range, keeping up to fetchWindow blocks in flight. It stops early
+// when a committed block rewinds the current block, reporting whether polling can go on from the new current block.
+// Blocks that fail to be fetched are queued for a retry, and the range stops before the first block that isn't due.
+func (p *TxParser) syncRange(ctx context.Context, fromBlock int, toBlock int) bool {
+	if p.retries.retryIn(fromBlock) > 0 {
+		return false
+	}
+
+	workers := make(chan struct{}, p.concurrency)
+	var inFlight []chan fetchResult
+	nextFetch := fromBlock
+
+	for nextCommit := fromBlock; nextCommit <= toBlock; nextCommit++ {
+		// Blocks are fetched in batches, a batch is dispatched once the window has room for it.
+		for size := min(p.batchSize, toBlock-nextFetch+1); size > 0 && nextFetch-nextCommit+size <= p.fetchWindow; size = min(p.batchSize, toBlock-nextFetch+1) {
+			// The range ends before the first block still waiting for its retry.
+			for i := 0; i < size; i++ {
+				if p.retries.retryIn(nextFetch+i) > 0 {
+					toBlock, size = nextFetch+i-1, i
+					break
+				}
+			}
+			if size == 0 {
+				break
+			}
+			batch := make([]int, size)
+			results := make([]chan fetchResult, size)
+			for i := range batch {
+				batch[i] = nextFetch + i
+				results[i] = make(chan fetchResult, 1)
+			}
+			inFlight = append(inFlight, results...)
+			nextFetch += size
+			go p.fetchBatch(ctx, workers, batch, results)
+		}
+
+		var fetched fetchResult
+		select {
+		case <-ctx.Done():
+			return false
+		case fetched = <-inFlight[0]:
+		}
+		inFlight = inFlight[1:]
+
+		if !p.commitBlock(ctx, nextCommit, fetched.block, fetched.err) {
+			return false
+		}
+		// A reorganization rewinds the current block, the blocks still in flight are discarded.
+		if p.store.CurrentBlock() != nextCommit {
+			return true
+		}
+	}
+	return true
+}
+
+// fetchBatch fetches a batch of blocks once a worker is available, and sends the outcome of each block to its result.
+func (p *TxParser) fetchBatch(ctx context.Context, workers chan struct{}, batch []int, results []chan fetchResult) {
+	workers <- struct{}{}
+	blocks, errs := p.blockChain.ParseBlocks(ctx, batch)
+	<-workers
+
+	for i, blockNumber := range batch {
+		switch {
+		case ctx.Err() != nil:
+			// Fetches interrupted by the shutdown are not failures of the block.
+		case errs[i] != nil:
+			p.retries.fail(blockNumber, errs[i])
+		default:
+			p.retries.recover(blockNumber)
+		}
+		results[i] <- fetchResult{block: blocks[i], err: errs[i]}
+	}
+}
@@ -0,0 +1,573 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+const (
+	// defaultWebhookBackoff is the delay before the first retry of a failed delivery.
+	defaultWebhookBackoff = 5 * time.Second
+	// defaultWebhookMaxBackoff caps the delay between the retries of a delivery, which doubles after every failure.
+	defaultWebhookMaxBackoff = 10 * time.Minute
+	// defaultWebhookAttempts is the number of attempts at a delivery before it is moved to the dead letters.
+	defaultWebhookAttempts = 8
+	// webhookTimeout bounds a single delivery attempt.
+	webhookTimeout = 10 * time.Second
+	// webhookIdleInterval is the longest delay between two checks of the queued deliveries.
+	webhookIdleInterval = time.Minute
+	// deliveryLogSize is the number of delivery attempts kept per webhook.
+	deliveryLogSize = 100
+	// maxDeadLetters is the number of dead letters kept per webhook, the oldest are dropped beyond.
+	maxDeadLetters = 1000
+	// webhookWorkers is the number of deliveries attempted at the same time.
+	webhookWorkers = 8
+)
+
+// Headers of the requests posting events to webhooks.
+const (
+	// SignatureHeader holds the signature of the payload, see SignPayload.
+	SignatureHeader = "X-Txparser-Signature"
+	// EventHeader holds the type of the event.
+	EventHeader = "X-Txparser-Event"
+	// DeliveryHeader holds the id of the delivery, which is kept across its attempts.
+	DeliveryHeader = "X-Txparser-Delivery"
+)
+
+// DeliveryAttempt records an attempt at delivering an event to a webhook.
+type DeliveryAttempt struct {
+	// DeliveryID is the id of the delivery.
+	DeliveryID string `json:"deliveryId"`
+	// EventID is the id of the delivered event.
+	EventID string `json:"eventId"`
+	// EventType is the type of the delivered event.
+	EventType store.EventType `json:"eventType"`
+	// Attempt is the number of the attempt, starting at 1.
+	Attempt int `json:"attempt"`
+	// State is "delivered" when the endpoint accepted the event, "pending" when it is retried and "failed" when it
+	// was moved to the dead letters.
+	State store.DeliveryState `json:"state"`
+	// StatusCode is the status of the response, zero when no response was received.
+	StatusCode int `json:"statusCode,omitempty"`
+	// Error describes why the attempt failed.
+	Error string `json:"error,omitempty"`
+	// Time is the time of the attempt.
+	Time time.Time `json:"time"`
+}
+
+// webhookDispatcher delivers the events to the webhooks, retrying the failed deliveries with an exponential backoff
+// until they run out of attempts and are moved to the dead letters. The events of a block are held until the block is
+// confirmed, and dropped when it gets orphaned by a reorganization before then. The queued deliveries and the dead
+// letters are persisted in the store, so they survive a restart.
+type webhookDispatcher struct {
+	// store persists the deliveries.
+	store store.IStore
+	// client posts the events.
+	client *http.Client
+	// backoff is the delay before the first retry.
+	backoff time.Duration
+	// maxBackoff caps the delay between retries.
+	maxBackoff time.Duration
+	// maxAttempts is the number of attempts at a delivery before it is moved to the dead letters.
+	maxAttempts int
+	// queue holds the deliveries waiting for an attempt, indexed by id.
+	queue map[string]*store.Delivery
+	// inFlight marks the queued deliveries being attempted.
+	inFlight map[string]bool
+	// attempts holds the latest delivery attempts, oldest first, indexed by webhook id.
+	attempts map[string][]DeliveryAttempt
+	// deadLetters holds the deliveries that failed every attempt, oldest first, indexed by webhook id.
+	deadLetters map[string][]store.Delivery
+	// wake signals the dispatcher that deliveries are due.
+	wake chan struct{}
+	// mu guards the deliveries.
+	mu sync.Mutex
+}
+
+func newWebhookDispatcher(backoff time.Duration, maxBackoff time.Duration, maxAttempts int) *webhookDispatcher {
+	return &webhookDispatcher{
+		client:      &http.Client{Timeout: webhookTimeout},
+		backoff:     backoff,
+		maxBackoff:  maxBackoff,
+		maxAttempts: max(maxAttempts, 1),
+		queue:       make(map[string]*store.Delivery),
+		inFlight:    make(map[string]bool),
+		attempts:    make(map[string][]DeliveryAttempt),
+		deadLetters: make(map[string][]store.Delivery),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// load restores the queued deliveries and the dead letters persisted in the store.
+func (w *webhookDispatcher) load(storage store.IStore) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.store = storage
+	for _, delivery := range storage.Deliveries() {
+		delivery := delivery
+		if delivery.State == store.DeliveryFailed {
+			w.deadLetters[delivery.WebhookID] = append(w.deadLetters[delivery.WebhookID], delivery)
+		} else {
+			w.queue[delivery.ID] = &delivery
+		}
+	}
+}
+
+// SignPayload returns the signature of a payload posted to a webhook, the hex encoded HMAC-SHA256 of the payload keyed
+// with the secret of the webhook, prefixed with "sha256=".
+func SignPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// RegisterWebhook registers a webhook notified of the activity of a subscribed address, a secret is generated when
+// none is given. It fails when the address is not subscribed, or when the webhook cannot be stored.
+func (p *TxParser) RegisterWebhook(address store.Address, options WebhookOptions) (store.Webhook, bool, error) {
+	webhook := store.Webhook{
+		ID:        randomHex(8),
+		Address:   address,
+		URL:       options.URL,
+		Events:    options.Events,
+		Secret:    options.Secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = randomHex(32)
+	}
+	if saved, err := p.store.SaveWebhook(webhook); !saved || err != nil {
+		return store.Webhook{}, false, err
+	}
+	return webhook, true, nil
+}
+
+// UnregisterWebhook removes a webhook, along with its queued deliveries, delivery log and dead letters.
+func (p *TxParser) UnregisterWebhook(id string) (bool, error) {
+	if removed, err := p.store.RemoveWebhook(id); !removed || err != nil {
+		return false, err
+	}
+	p.webhooks.forget(id)
+	return true, nil
+}
+
+// GetWebhooks returns the webhooks registered for an address, or every webhook when the address is empty, without
+// their secrets.
+func (p *TxParser) GetWebhooks(address store.Address) []store.Webhook {
+	webhooks := p.store.Webhooks(address)
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks
+}
+
+// GetWebhookDeliveries returns the latest delivery attempts of a webhook, the most recent first.
+func (p *TxParser) GetWebhookDeliveries(id string) []DeliveryAttempt {
+	w := p.webhooks
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	attempts := make([]DeliveryAttempt, 0, len(w.attempts[id]))
+	for i := len(w.attempts[id]) - 1; i >= 0; i-- {
+		attempts = append(attempts, w.attempts[id][i])
+	}
+	return attempts
+}
+
+// GetDeadLetters returns the deliveries to a webhook that failed every attempt, oldest first.
+func (p *TxParser) GetDeadLetters(id string) []store.Delivery {
+	w := p.webhooks
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return append([]store.Delivery{}, w.deadLetters[id]...)
+}
+
+// ReplayDeadLetters queues the dead letters of a webhook for delivery again, with a fresh set of attempts, either
+// every dead letter or the one with the given delivery id. It returns the number of replayed deliveries.
+func (p *TxParser) ReplayDeadLetters(id string, deliveryID string) int {
+	w := p.webhooks
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var kept, replayed []store.Delivery
+	for _, delivery := range w.deadLetters[id] {
+		if deliveryID != "" && delivery.ID != deliveryID {
+			kept = append(kept, delivery)
+			continue
+		}
+		delivery.State = store.DeliveryPending
+		delivery.Attempts = 0
+		delivery.LastError = ""
+		delivery.NextAttempt = time.Now()
+		w.queue[delivery.ID] = &delivery
+		replayed = append(replayed, delivery)
+	}
+	if len(kept) == 0 {
+		delete(w.deadLetters, id)
+	} else {
+		w.deadLetters[id] = kept
+	}
+	if len(replayed) > 0 {
+		w.save(replayed...)
+		w.signal()
+	}
+	return len(replayed)
+}
+
+// notifyWebhooks queues the delivery of the activity newly recorded from a block to the webhooks of the involved
+// addresses, and wakes the dispatcher up as the blocks held back may have been confirmed.
+func (p *TxParser) notifyWebhooks(block store.Block) {
+	defer p.webhooks.signal()
+
+	webhooks := make(map[store.Address][]store.Webhook)
+	for _, webhook := range p.store.Webhooks("") {
+		webhooks[webhook.Address] = append(webhooks[webhook.Address], webhook)
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	// The events are delivered once their block is confirmed, which their status reflects.
+	status := p.blockStatus(block.Number)
+	if status == store.StatusPendingConfirmation {
+		status = store.StatusConfirmed
+	}
+	now := time.Now().UTC()
+	var deliveries []store.Delivery
+	for address, hooks := range webhooks {
+		emit := func(eventType store.EventType, data interface{}) {
+			payload, err := json.Marshal(data)
+			if err != nil {
+				log.Println("Error encoding webhook event:", err)
+				return
+			}
+			event := store.WebhookEvent{ID: randomHex(8), Type: eventType, Address: address, BlockNumber: block.Number, CreatedAt: now, Data: payload}
+			for _, webhook := range hooks {
+				if webhook.Accepts(eventType) {
+					deliveries = append(deliveries, store.Delivery{ID: randomHex(8), WebhookID: webhook.ID, Event: event, State: store.DeliveryPending, NextAttempt: now})
+				}
+			}
+		}
+		for _, tx := range block.Transactions {
+			if tx.Involves(address) {
+				tx.Status = status
+				tx.Details = nil
+				emit(store.EventTransaction, tx)
+			}
+		}
+		for _, transfer := range block.TokenTransfers {
+			if transfer.Involves(address) {
+				transfer.Status = status
+				emit(store.EventTokenTransfer, transfer)
+			}
+		}
+		for _, call := range block.InternalTransactions {
+			if call.Involves(address) {
+				call.Status = status
+				emit(store.EventInternalTransaction, call)
+			}
+		}
+		for _, withdrawal := range block.Withdrawals {
+			if withdrawal.Address == address {
+				withdrawal.Status = status
+				emit(store.EventWithdrawal, withdrawal)
+			}
+		}
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+
+	w := p.webhooks
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.save(deliveries...)
+	for i := range deliveries {
+		w.queue[deliveries[i].ID] = &deliveries[i]
+	}
+}
+
+// confirmedBlock returns the latest processed block buried under enough blocks to be confirmed.
+func (p *TxParser) confirmedBlock() int {
+	return p.store.CurrentBlock() - max(p.confirmations, 1) + 1
+}
+
+// runWebhooks delivers the queued events of the confirmed blocks as they become due on a fixed pool of workers, until
+// the context is canceled. It returns once the workers are done.
+func (p *TxParser) runWebhooks(ctx context.Context) {
+	w := p.webhooks
+	deliveries := make(chan store.Delivery)
+	var workers sync.WaitGroup
+	for i := 0; i < webhookWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for delivery := range deliveries {
+				p.deliver(ctx, delivery)
+			}
+		}()
+	}
+	defer workers.Wait()
+	defer close(deliveries)
+
+	for {
+		confirmedBlock := p.confirmedBlock()
+		for _, delivery := range w.due(time.Now(), confirmedBlock) {
+			select {
+			case deliveries <- delivery:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		case <-time.After(w.nextIn(time.Now(), confirmedBlock)):
+		}
+	}
+}
+
+// deliver attempts to post an event to its webhook and records the outcome. The deliveries to removed webhooks are
+// dropped.
+func (p *TxParser) deliver(ctx context.Context, delivery store.Delivery) {
+	var webhook store.Webhook
+	found := false
+	for _, registered := range p.store.Webhooks("") {
+		if registered.ID == delivery.WebhookID {
+			webhook, found = registered, true
+			break
+		}
+	}
+	if !found {
+		p.webhooks.forget(delivery.WebhookID)
+		return
+	}
+	statusCode, err := p.webhooks.post(ctx, webhook, delivery)
+	if ctx.Err() != nil {
+		// The attempt was interrupted by the shutdown, it is not held against the delivery.
+		p.webhooks.release(delivery.ID)
+		return
+	}
+	if err != nil {
+		log.Printf("Delivery %s to webhook %s failed: %s\n", delivery.ID, webhook.ID, err.Error())
+	}
+	p.webhooks.record(delivery, statusCode, err)
+}
+
+// post sends the event of a delivery to a webhook, and returns the status of the response.
+func (w *webhookDispatcher) post(ctx context.Context, webhook store.Webhook, delivery store.Delivery) (int, error) {
+	payload, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(delivery.Event.Type))
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, SignPayload(webhook.Secret, payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// due returns the queued deliveries of the confirmed blocks due for an attempt, and marks them in flight.
+func (w *webhookDispatcher) due(now time.Time, confirmedBlock int) []store.Delivery {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var due []store.Delivery
+	for id, delivery := range w.queue {
+		if !w.inFlight[id] && delivery.Event.BlockNumber <= confirmedBlock && !delivery.NextAttempt.After(now) {
+			w.inFlight[id] = true
+			due = append(due, *delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttempt.Before(due[j].NextAttempt)
+	})
+	return due
+}
+
+// nextIn returns how long to wait before the next queued delivery of the confirmed blocks is due, the deliveries held
+// back are woken up by the following blocks.
+func (w *webhookDispatcher) nextIn(now time.Time, confirmedBlock int) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wait := webhookIdleInterval
+	for id, delivery := range w.queue {
+		if !w.inFlight[id] && delivery.Event.BlockNumber <= confirmedBlock {
+			wait = min(wait, max(delivery.NextAttempt.Sub(now), 0))
+		}
+	}
+	return wait
+}
+
+// record logs an attempt at a delivery. A successful delivery leaves the queue, a failed one is retried after the
+// backoff, or moved to the dead letters once it ran out of attempts, dropping the oldest dead letters beyond the limit.
+func (w *webhookDispatcher) record(delivery store.Delivery, statusCode int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.inFlight, delivery.ID)
+	queued, exists := w.queue[delivery.ID]
+	if !exists {
+		return
+	}
+	now := time.Now().UTC()
+	attempt := DeliveryAttempt{
+		DeliveryID: delivery.ID,
+		EventID:    delivery.Event.ID,
+		EventType:  delivery.Event.Type,
+		Attempt:    queued.Attempts + 1,
+		StatusCode: statusCode,
+		Time:       now,
+	}
+
+	switch {
+	case err == nil:
+		attempt.State = store.DeliveryDelivered
+		delete(w.queue, delivery.ID)
+		w.remove(delivery.ID)
+	case queued.Attempts+1 >= w.maxAttempts:
+		attempt.State = store.DeliveryFailed
+		queued.Attempts++
+		queued.State = store.DeliveryFailed
+		queued.LastError = err.Error()
+		deadLetters := append(w.deadLetters[delivery.WebhookID], *queued)
+		delete(w.queue, delivery.ID)
+		w.save(*queued)
+		if len(deadLetters) > maxDeadLetters {
+			var dropped []string
+			for _, deadLetter := range deadLetters[:len(deadLetters)-maxDeadLetters] {
+				dropped = append(dropped, deadLetter.ID)
+			}
+			deadLetters = append([]store.Delivery(nil), deadLetters[len(deadLetters)-maxDeadLetters:]...)
+			w.remove(dropped...)
+		}
+		w.deadLetters[delivery.WebhookID] = deadLetters
+	default:
+		attempt.State = store.DeliveryPending
+		queued.Attempts++
+		queued.LastError = err.Error()
+		delay := w.backoff
+		for i := 1; i < queued.Attempts && delay < w.maxBackoff; i++ {
+			delay *= 2
+		}
+		queued.NextAttempt = now.Add(min(delay, w.maxBackoff))
+		w.save(*queued)
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	attempts := append(w.attempts[delivery.WebhookID], attempt)
+	if len(attempts) > deliveryLogSize {
+		attempts = attempts[len(attempts)-deliveryLogSize:]
+	}
+	w.attempts[delivery.WebhookID] = attempts
+	w.signal()
+}
+
+// release puts back an interrupted delivery in the queue without counting the attempt.
+func (w *webhookDispatcher) release(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.inFlight, id)
+}
+
+// forget drops the queued deliveries, the delivery log and the dead letters of a webhook, whose persisted deliveries
+// are removed by the store along with it.
+func (w *webhookDispatcher) forget(webhookID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for id, delivery := range w.queue {
+		if delivery.WebhookID == webhookID {
+			delete(w.queue, id)
+			delete(w.inFlight, id)
+		}
+	}
+	delete(w.attempts, webhookID)
+	delete(w.deadLetters, webhookID)
+}
+
+// discardBlock drops the queued deliveries of the activity recorded from a block orphaned by a reorganization.
+func (w *webhookDispatcher) discardBlock(number int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var discarded []string
+	for id, delivery := range w.queue {
+		if delivery.Event.BlockNumber == number {
+			delete(w.queue, id)
+			delete(w.inFlight, id)
+			discarded = append(discarded, id)
+		}
+	}
+	if len(discarded) > 0 {
+		w.remove(discarded...)
+	}
+}
+
+// save persists the deliveries, which are kept in memory when they could not be persisted.
+// The caller must hold the lock.
+func (w *webhookDispatcher) save(deliveries ...store.Delivery) {
+	if err := w.store.SaveDeliveries(deliveries); err != nil {
+		log.Println("Error persisting webhook deliveries:", err)
+	}
+}
+
+// remove discards the persisted deliveries.
+// The caller must hold the lock.
+func (w *webhookDispatcher) remove(ids ...string) {
+	if err := w.store.RemoveDeliveries(ids); err != nil {
+		log.Println("Error discarding webhook deliveries:", err)
+	}
+}
+
+// signal wakes the dispatcher up without blocking.
+func (w *webhookDispatcher) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// randomHex returns the hex encoding of the given number of random bytes.
+func randomHex(size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		log.Println("Error generating random bytes:", err)
+	}
+	return hex.EncodeToString(b)
+}
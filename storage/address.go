@@ -0,0 +1,79 @@
+package store
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/mo-mohamed/txparser/keccak"
+)
+
+var (
+	// ErrInvalidAddressLength is returned for addresses not made of 20 bytes.
+	ErrInvalidAddressLength = errors.New("address must be 40 hex characters long")
+	// ErrInvalidAddressHex is returned for addresses holding non hex characters.
+	ErrInvalidAddressHex = errors.New("address must only contain hex characters")
+	// ErrInvalidAddressChecksum is returned for mixed case addresses failing the EIP-55 checksum.
+	ErrInvalidAddressChecksum = errors.New("address does not match its EIP-55 checksum")
+)
+
+// Address is an Ethereum address, normalized to its "0x" prefixed lowercase form so it can be compared and used as a key.
+type Address string
+
+// ParseAddress validates and normalizes an Ethereum address. Mixed case addresses must match their EIP-55 checksum,
+// while all lowercase or all uppercase addresses carry no checksum.
+func ParseAddress(s string) (Address, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(digits) != 40 {
+		return "", ErrInvalidAddressLength
+	}
+	if _, err := hex.DecodeString(digits); err != nil {
+		return "", ErrInvalidAddressHex
+	}
+
+	address := Address("0x" + strings.ToLower(digits))
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && "0x"+digits != address.Checksum() {
+		return "", ErrInvalidAddressChecksum
+	}
+	return address, nil
+}
+
+// String returns the normalized form of the address.
+func (a Address) String() string {
+	return string(a)
+}
+
+// Checksum returns the EIP-55 mixed case form of the address, where a letter is uppercased when the matching nibble
+// of the Keccak-256 hash of the lowercase address is 8 or more.
+func (a Address) Checksum() string {
+	digits := strings.TrimPrefix(string(a), "0x")
+	hash := keccak.Sum256([]byte(digits))
+
+	checksummed := []byte(digits)
+	for i, c := range checksummed {
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if c >= 'a' && c <= 'f' && nibble >= 8 {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(checksummed)
+}
+
+// UnmarshalJSON decodes an address, normalizing its case. A null address, such as the recipient of a contract creation,
+// decodes to an empty address.
+func (a *Address) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == nil {
+		*a = ""
+		return nil
+	}
+	*a = Address(strings.ToLower(*s))
+	return nil
+}
@@ -0,0 +1,53 @@
+package store_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+func TestParseAddress(t *testing.T) {
+	checksummed := []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	}
+	for _, s := range checksummed {
+		address, err := store.ParseAddress(s)
+		if err != nil {
+			t.Errorf("Expected %s to be valid, got %v", s, err)
+		}
+		if address.Checksum() != s {
+			t.Errorf("Expected checksum of %s to be %s, got %s", address, s, address.Checksum())
+		}
+	}
+
+	address, err := store.ParseAddress("0X5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED")
+	if err != nil || address != "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed" {
+		t.Errorf("Expected uppercase address to be normalized, got %s %v", address, err)
+	}
+
+	invalid := map[string]error{
+		"0x123456789abcdef":                          store.ErrInvalidAddressLength,
+		"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaeg": store.ErrInvalidAddressHex,
+		"0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed": store.ErrInvalidAddressChecksum,
+	}
+	for s, expected := range invalid {
+		if _, err := store.ParseAddress(s); err != expected {
+			t.Errorf("Expected %s to fail with %v, got %v", s, expected, err)
+		}
+	}
+}
+
+func TestAddressUnmarshalJSON(t *testing.T) {
+	var tx store.Transaction
+	err := json.Unmarshal([]byte(`{"from":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed","to":null}`), &tx)
+	if err != nil {
+		t.Fatalf("Could not decode transaction: %v", err)
+	}
+	if tx.From != "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed" || tx.To != "" {
+		t.Errorf("Expected addresses to be normalized, got from %s to %s", tx.From, tx.To)
+	}
+}
@@ -0,0 +1,608 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// snapshotFileName is the name of the file holding the latest snapshot of the store.
+	snapshotFileName = "snapshot.json"
+	// logFileName is the name of the append-only file holding the changes made since the latest snapshot.
+	logFileName = "changes.log"
+	// snapshotInterval is the number of changes appended to the log before a new snapshot is taken.
+	snapshotInterval = 1000
+)
+
+// Operations recorded in the log.
+const (
+	opSubscribe        = "subscribe"
+	opUnsubscribe      = "unsubscribe"
+	opSetCurrentBlock  = "setCurrentBlock"
+	opSaveTransactions = "saveTransactions"
+	opSaveBlock        = "saveBlock"
+	opRemoveBlock      = "removeBlock"
+	opBackfill         = "backfill"
+	opSaveWebhook      = "saveWebhook"
+	opRemoveWebhook    = "removeWebhook"
+	opSaveDeliveries   = "saveDeliveries"
+	opRemoveDeliveries = "removeDeliveries"
+	opSaveBackfillJob  = "saveBackfillJob"
+)
+
+// ErrStoreClosed is returned by the changes made to a closed store.
+var ErrStoreClosed = errors.New("store closed")
+
+// ErrLogCorrupted is returned when opening a store whose log holds a corrupted change followed by others.
+var ErrLogCorrupted = errors.New("store log corrupted")
+
+/*
+FileStore is a durable store keeping its state in memory and persisting every change to an append-only log
+before applying it. The log is periodically compacted into a snapshot, and on startup the snapshot is loaded
+and the log replayed on top of it. A change torn by a crash mid-write fails its checksum and is discarded.
+*/
+type FileStore struct {
+	// memory holds the current state of the store.
+	memory *MemoryStore
+	// dir is the directory holding the snapshot and the log.
+	dir string
+	// log is the append-only file the changes are written to.
+	log *os.File
+	// seq is the sequence number of the latest change written to the log.
+	seq uint64
+	// pending is the number of changes written to the log since the latest snapshot.
+	pending int
+	// closed tells whether the store was closed, after which changes are rejected.
+	closed bool
+	// mu serializes the changes so they are applied in the order they are logged.
+	mu sync.Mutex
+}
+
+// logEntry is a single change recorded in the log.
+type logEntry struct {
+	Seq          uint64        `json:"seq"`
+	Op           string        `json:"op"`
+	Address      Address       `json:"address,omitempty"`
+	Subscription *Subscription `json:"subscription,omitempty"`
+	Purge        bool          `json:"purge,omitempty"`
+	BlockNumber  int           `json:"blockNumber,omitempty"`
+	Transactions []Transaction `json:"transactions,omitempty"`
+	Block        *Block        `json:"block,omitempty"`
+	Webhook      *Webhook      `json:"webhook,omitempty"`
+	WebhookID    string        `json:"webhookId,omitempty"`
+	Deliveries   []Delivery    `json:"deliveries,omitempty"`
+	DeliveryIDs  []string      `json:"deliveryIds,omitempty"`
+	BackfillJob  *BackfillJob  `json:"backfillJob,omitempty"`
+}
+
+// fileSnapshot is the content of the snapshot file.
+type fileSnapshot struct {
+	// Seq is the sequence number of the latest change included in the snapshot.
+	Seq   uint64         `json:"seq"`
+	State memorySnapshot `json:"state"`
+}
+
+// NewFileStore opens the store persisted in the given directory, creating it when it doesn't exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating store directory: %w", err)
+	}
+
+	f := &FileStore{
+		memory: NewMemoryStore(),
+		dir:    dir,
+	}
+	if err := f.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	changes, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening store log: %w", err)
+	}
+	f.log = changes
+	if err := f.replayLog(); err != nil {
+		changes.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Close takes a final snapshot and closes the log, the changes made afterwards fail with ErrStoreClosed. Closing the
+// store again does nothing.
+func (f *FileStore) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	if err := f.takeSnapshot(); err != nil {
+		f.log.Close()
+		return err
+	}
+	return f.log.Close()
+}
+
+// Transactions fetches transactions records for a given address
+func (f *FileStore) Transactions(address Address) []Transaction {
+	return f.memory.Transactions(address)
+}
+
+// QueryTransactions fetches a page of the transactions of an address selected by the query
+func (f *FileStore) QueryTransactions(query TransactionQuery) (TransactionPage, error) {
+	return f.memory.QueryTransactions(query)
+}
+
+// TransactionsSince fetches the transactions of the given addresses recorded after the sequence number
+func (f *FileStore) TransactionsSince(addresses []Address, sequence uint64, limit int) []Transaction {
+	return f.memory.TransactionsSince(addresses, sequence, limit)
+}
+
+// LatestSequence retrieves the sequence number of the latest recorded transaction
+func (f *FileStore) LatestSequence() uint64 {
+	return f.memory.LatestSequence()
+}
+
+// TokenTransfers fetches the token transfers sent or received by a given address
+func (f *FileStore) TokenTransfers(address Address) []TokenTransfer {
+	return f.memory.TokenTransfers(address)
+}
+
+// InternalTransactions fetches the internal transactions sending ether to or from a given address
+func (f *FileStore) InternalTransactions(address Address) []InternalTransaction {
+	return f.memory.InternalTransactions(address)
+}
+
+// Withdrawals fetches the beacon chain withdrawals credited to a given address
+func (f *FileStore) Withdrawals(address Address) []Withdrawal {
+	return f.memory.Withdrawals(address)
+}
+
+// CurrentBlock retrieves the latest processed block
+func (f *FileStore) CurrentBlock() int {
+	return f.memory.CurrentBlock()
+}
+
+// Block retrieves the header of a processed block within the reorg window.
+func (f *FileStore) Block(number int) (BlockHeader, bool) {
+	return f.memory.Block(number)
+}
+
+// BlockTime retrieves the time of a block that contributed records to the store.
+func (f *FileStore) BlockTime(number int) (time.Time, bool) {
+	return f.memory.BlockTime(number)
+}
+
+// BlockAt retrieves the latest block that contributed records to the store produced at or before the given time.
+func (f *FileStore) BlockAt(t time.Time) (int, bool) {
+	return f.memory.BlockAt(t)
+}
+
+// SaveTransactions persists and stores transaction in the transactions store
+func (f *FileStore) SaveTransactions(transactions []Transaction) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.commit(logEntry{Op: opSaveTransactions, Transactions: f.memory.subscribedTransactions(transactions)})
+}
+
+// Subscribe persists and adds an address to the list of subscribers.
+func (f *FileStore) Subscribe(subscription Subscription) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.memory.Subscription(subscription.Address); exists {
+		return false, nil
+	}
+	if err := f.commit(logEntry{Op: opSubscribe, Subscription: &subscription}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Unsubscribe persists and removes an address from the list of subscribers, along with its transactions when purging.
+func (f *FileStore) Unsubscribe(address Address, purge bool) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.memory.Subscription(address); !exists {
+		return false, nil
+	}
+	if err := f.commit(logEntry{Op: opUnsubscribe, Address: address, Purge: purge}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Subscription retrieves the subscription of an address.
+func (f *FileStore) Subscription(address Address) (Subscription, bool) {
+	return f.memory.Subscription(address)
+}
+
+// Subscriptions retrieves every subscription, ordered by address.
+func (f *FileStore) Subscriptions() []Subscription {
+	return f.memory.Subscriptions()
+}
+
+// SaveWebhook persists and registers a webhook for a subscribed address.
+func (f *FileStore) SaveWebhook(webhook Webhook) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.memory.Subscription(webhook.Address); !exists {
+		return false, nil
+	}
+	if err := f.commit(logEntry{Op: opSaveWebhook, Webhook: &webhook}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoveWebhook persists and removes a webhook by its id.
+func (f *FileStore) RemoveWebhook(id string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.memory.hasWebhook(id) {
+		return false, nil
+	}
+	if err := f.commit(logEntry{Op: opRemoveWebhook, WebhookID: id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Webhooks retrieves the webhooks registered for an address, or every webhook when the address is empty.
+func (f *FileStore) Webhooks(address Address) []Webhook {
+	return f.memory.Webhooks(address)
+}
+
+// SaveDeliveries persists and stores webhook deliveries, replacing the ones with the same id.
+func (f *FileStore) SaveDeliveries(deliveries []Delivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.commit(logEntry{Op: opSaveDeliveries, Deliveries: deliveries})
+}
+
+// RemoveDeliveries persists and discards webhook deliveries by their ids.
+func (f *FileStore) RemoveDeliveries(ids []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.commit(logEntry{Op: opRemoveDeliveries, DeliveryIDs: ids})
+}
+
+// Deliveries retrieves the stored webhook deliveries, ordered by the time of their next attempt.
+func (f *FileStore) Deliveries() []Delivery {
+	return f.memory.Deliveries()
+}
+
+// SaveBackfillJob persists and stores the progress of the backfill job of a subscribed address.
+func (f *FileStore) SaveBackfillJob(job BackfillJob) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.memory.Subscription(job.Address); !exists {
+		return nil
+	}
+	return f.commit(logEntry{Op: opSaveBackfillJob, BackfillJob: &job})
+}
+
+// BackfillJobs retrieves the backfill jobs, in scheduling order.
+func (f *FileStore) BackfillJobs() []BackfillJob {
+	return f.memory.BackfillJobs()
+}
+
+// SetCurrentBlock persists and stores the latest processed block
+func (f *FileStore) SetCurrentBlock(blockNumber int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.commit(logEntry{Op: opSetCurrentBlock, BlockNumber: blockNumber})
+}
+
+// SaveBlock persists and stores the block header and the transactions, token transfers, internal transactions and
+// withdrawals of the block involving subscribed addresses, and returns the block holding the records newly stored.
+func (f *FileStore) SaveBlock(block Block) (Block, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Only the transactions the store keeps are logged, which replays to the same state.
+	block.Transactions = f.memory.subscribedTransactions(block.Transactions)
+	block.TokenTransfers = f.memory.subscribedTokenTransfers(block.TokenTransfers)
+	block.InternalTransactions = f.memory.subscribedInternalTransactions(block.InternalTransactions)
+	block.Withdrawals = f.memory.subscribedWithdrawals(block.Withdrawals)
+	var recorded Block
+	err := f.commitApplying(logEntry{Op: opSaveBlock, Block: &block}, func() { recorded, _ = f.memory.SaveBlock(block) })
+	return recorded, err
+}
+
+// BackfillTransactions persists and stores the transactions, token transfers, internal transactions and withdrawals
+// of the block involving the address.
+func (f *FileStore) BackfillTransactions(address Address, block Block) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	block.Transactions = addressTransactions(address, block.Transactions)
+	block.TokenTransfers = addressTokenTransfers(address, block.TokenTransfers)
+	block.InternalTransactions = addressInternalTransactions(address, block.InternalTransactions)
+	block.Withdrawals = addressWithdrawals(address, block.Withdrawals)
+	return f.commit(logEntry{Op: opBackfill, Address: address, Block: &block})
+}
+
+// RemoveBlock persists and discards the block header and every transaction the block contributed.
+func (f *FileStore) RemoveBlock(number int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.commit(logEntry{Op: opRemoveBlock, BlockNumber: number})
+}
+
+// commit writes the change to the log, applies it to the state and takes a snapshot when due. A change that could
+// not be written is not applied, and the error is returned.
+// The caller must hold the lock.
+func (f *FileStore) commit(entry logEntry) error {
+	return f.commitApplying(entry, func() { f.apply(entry) })
+}
+
+// commitApplying commits the change as commit does, applying it to the state with the given function.
+// The caller must hold the lock.
+func (f *FileStore) commitApplying(entry logEntry, apply func()) error {
+	if f.closed {
+		return ErrStoreClosed
+	}
+	entry.Seq = f.seq + 1
+	if err := f.appendLog(entry); err != nil {
+		return fmt.Errorf("error persisting store change: %w", err)
+	}
+	f.seq = entry.Seq
+	apply()
+
+	f.pending++
+	if f.pending >= snapshotInterval {
+		if err := f.takeSnapshot(); err != nil {
+			log.Println("Error taking store snapshot:", err)
+		}
+	}
+	return nil
+}
+
+// apply applies a logged change to the state.
+func (f *FileStore) apply(entry logEntry) {
+	switch entry.Op {
+	case opSubscribe:
+		f.memory.Subscribe(*entry.Subscription)
+	case opUnsubscribe:
+		f.memory.Unsubscribe(entry.Address, entry.Purge)
+	case opSetCurrentBlock:
+		f.memory.SetCurrentBlock(entry.BlockNumber)
+	case opSaveTransactions:
+		f.memory.SaveTransactions(entry.Transactions)
+	case opSaveBlock:
+		f.memory.SaveBlock(*entry.Block)
+	case opRemoveBlock:
+		f.memory.RemoveBlock(entry.BlockNumber)
+	case opBackfill:
+		f.memory.BackfillTransactions(entry.Address, *entry.Block)
+	case opSaveWebhook:
+		f.memory.SaveWebhook(*entry.Webhook)
+	case opRemoveWebhook:
+		f.memory.RemoveWebhook(entry.WebhookID)
+	case opSaveDeliveries:
+		f.memory.SaveDeliveries(entry.Deliveries)
+	case opRemoveDeliveries:
+		f.memory.RemoveDeliveries(entry.DeliveryIDs)
+	case opSaveBackfillJob:
+		f.memory.SaveBackfillJob(*entry.BackfillJob)
+	}
+}
+
+// appendLog writes a change to the log as a line prefixed with its checksum, and flushes it to disk. A change that
+// could not be written entirely is cut off the log, so the following changes are not discarded along with it.
+func (f *FileStore) appendLog(entry logEntry) error {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	offset, err := f.log.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(payload), payload)
+	if _, err = f.log.WriteString(line); err == nil {
+		err = f.log.Sync()
+	}
+	if err != nil {
+		if truncateErr := f.log.Truncate(offset); truncateErr == nil {
+			f.log.Seek(offset, io.SeekStart)
+		}
+		return err
+	}
+	return nil
+}
+
+// replayLog applies the changes logged after the snapshot, and truncates the log after the last intact change. Only
+// the last change can be torn by a crash, a corrupted change followed by others fails with ErrLogCorrupted.
+func (f *FileStore) replayLog() error {
+	reader := bufio.NewReader(f.log)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A line without its terminating newline was torn by a crash.
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading store log: %w", err)
+		}
+		entry, ok := decodeLogLine(line)
+		if !ok {
+			if _, err := reader.Peek(1); err != io.EOF {
+				return fmt.Errorf("%w at offset %d", ErrLogCorrupted, offset)
+			}
+			log.Println("Discarding corrupted store log from offset:", offset)
+			break
+		}
+		offset += int64(len(line))
+		if entry.Seq <= f.seq {
+			continue
+		}
+		f.seq = entry.Seq
+		f.apply(entry)
+		f.pending++
+	}
+
+	if err := f.log.Truncate(offset); err != nil {
+		return fmt.Errorf("error truncating store log: %w", err)
+	}
+	if _, err := f.log.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("error seeking store log: %w", err)
+	}
+	return nil
+}
+
+// decodeLogLine verifies the checksum of a log line and decodes the change it holds.
+func decodeLogLine(line []byte) (logEntry, bool) {
+	var entry logEntry
+	checksum, payload, found := bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte(" "))
+	if !found {
+		return entry, false
+	}
+	var expected uint32
+	if _, err := fmt.Sscanf(string(checksum), "%08x", &expected); err != nil || crc32.ChecksumIEEE(payload) != expected {
+		return entry, false
+	}
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+// loadSnapshot restores the state from the snapshot file, if any.
+func (f *FileStore) loadSnapshot() error {
+	content, err := os.ReadFile(filepath.Join(f.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading store snapshot: %w", err)
+	}
+
+	var snapshot fileSnapshot
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return fmt.Errorf("error decoding store snapshot: %w", err)
+	}
+	f.memory.restore(snapshot.State)
+	f.seq = snapshot.Seq
+	return nil
+}
+
+// takeSnapshot atomically replaces the snapshot file with the current state and empties the log.
+// The caller must hold the lock.
+func (f *FileStore) takeSnapshot() error {
+	content, err := json.Marshal(fileSnapshot{Seq: f.seq, State: f.memory.snapshot()})
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filepath.Join(f.dir, snapshotFileName+".tmp")
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(f.dir, snapshotFileName)); err != nil {
+		return err
+	}
+	if err := syncDir(f.dir); err != nil {
+		return err
+	}
+
+	// The changes in the log are part of the snapshot now, a crash before the truncation is harmless
+	// as the replay skips the changes already included in the snapshot.
+	if err := f.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	f.pending = 0
+	return nil
+}
+
+// addressTransactions filters the transactions involving the address.
+func addressTransactions(address Address, transactions []Transaction) []Transaction {
+	var filtered []Transaction
+	for _, tx := range transactions {
+		if tx.Involves(address) {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
+
+// addressTokenTransfers filters the token transfers involving the address.
+func addressTokenTransfers(address Address, transfers []TokenTransfer) []TokenTransfer {
+	var filtered []TokenTransfer
+	for _, transfer := range transfers {
+		if transfer.Involves(address) {
+			filtered = append(filtered, transfer)
+		}
+	}
+	return filtered
+}
+
+// addressInternalTransactions filters the internal transactions involving the address.
+func addressInternalTransactions(address Address, calls []InternalTransaction) []InternalTransaction {
+	var filtered []InternalTransaction
+	for _, call := range calls {
+		if call.Involves(address) {
+			filtered = append(filtered, call)
+		}
+	}
+	return filtered
+}
+
+// addressWithdrawals filters the withdrawals credited to the address.
+func addressWithdrawals(address Address, withdrawals []Withdrawal) []Withdrawal {
+	var filtered []Withdrawal
+	for _, withdrawal := range withdrawals {
+		if withdrawal.Address == address {
+			filtered = append(filtered, withdrawal)
+		}
+	}
+	return filtered
+}
+
+// syncDir flushes the directory entries to disk, making a rename durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,189 @@
+package store_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+// populate subscribes an address with a webhook, a queued delivery and a backfill job, and saves a block with a transaction involving
+// it.
+func populate(t *testing.T, fileStore *store.FileStore) {
+	t.Helper()
+	fileStore.Subscribe(store.Subscription{Address: "0x123"})
+	fileStore.SaveWebhook(store.Webhook{ID: "w1", Address: "0x123", URL: "https://example.com/hook", Secret: "s3cret"})
+	fileStore.SaveDeliveries([]store.Delivery{{ID: "d1", WebhookID: "w1", State: store.DeliveryPending, Attempts: 2}})
+	fileStore.SaveBackfillJob(store.BackfillJob{Address: "0x123", FromBlock: 1, ToBlock: 10, ScannedBlock: 4, Status: store.BackfillRunning})
+	details := store.TransactionDetails{Hash: "0xabc", From: "0x123", To: "0x456", Value: *store.NewQuantity(1000),
+		BlockNumber: store.NewQuantity(1)}
+	fileStore.SaveBlock(store.Block{
+		BlockHeader:  store.BlockHeader{Number: 1, Hash: "0xb1", ParentHash: "0xb0"},
+		Transactions: []store.Transaction{details.Slim()},
+	})
+	fileStore.SetCurrentBlock(1)
+}
+
+// assertPopulated checks the store holds the state written by populate.
+func assertPopulated(t *testing.T, fileStore *store.FileStore) {
+	t.Helper()
+	if fileStore.CurrentBlock() != 1 {
+		t.Errorf("Expected current block to be 1, got %d", fileStore.CurrentBlock())
+	}
+	if subscribed, _ := fileStore.Subscribe(store.Subscription{Address: "0x123"}); subscribed {
+		t.Error("Expected subscription to be restored")
+	}
+	if header, exists := fileStore.Block(1); !exists || header.Hash != "0xb1" {
+		t.Errorf("Expected block 1 to be restored with hash '0xb1', got '%s'", header.Hash)
+	}
+	transactions := fileStore.Transactions("0x123")
+	if len(transactions) != 1 || transactions[0].Hash != "0xabc" || transactions[0].Sequence != 1 ||
+		transactions[0].Details == nil || transactions[0].Details.Value.Int64() != 1000 {
+		t.Errorf("Expected transaction '0xabc' to be restored, got %v", transactions)
+	}
+	if streamed := fileStore.TransactionsSince([]store.Address{"0x123"}, 0, 0); len(streamed) != 1 || streamed[0].Hash != "0xabc" {
+		t.Errorf("Expected transaction '0xabc' to be listed by sequence once restored, got %v", streamed)
+	}
+	if webhooks := fileStore.Webhooks("0x123"); len(webhooks) != 1 || webhooks[0].Secret != "s3cret" {
+		t.Errorf("Expected webhook 'w1' to be restored, got %v", webhooks)
+	}
+	if deliveries := fileStore.Deliveries(); len(deliveries) != 1 || deliveries[0].ID != "d1" || deliveries[0].Attempts != 2 {
+		t.Errorf("Expected delivery 'd1' to be restored, got %v", deliveries)
+	}
+	if jobs := fileStore.BackfillJobs(); len(jobs) != 1 || jobs[0].ScannedBlock != 4 {
+		t.Errorf("Expected the backfill job of '0x123' to be restored, got %v", jobs)
+	}
+}
+
+func TestFileStoreRecoversFromLog(t *testing.T) {
+	dir := t.TempDir()
+	fileStore, err := store.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Could not open store: %v", err)
+	}
+	populate(t, fileStore)
+
+	// The store is reopened without being closed, as it happens after a crash.
+	reopened, err := store.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Could not reopen store: %v", err)
+	}
+	assertPopulated(t, reopened)
+}
+
+func TestFileStoreRecoversFromSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	fileStore, err := store.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Could not open store: %v", err)
+	}
+	populate(t, fileStore)
+	if err := fileStore.Close(); err != nil {
+		t.Fatalf("Could not close store: %v", err)
+	}
+
+	reopened, err := store.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Could not reopen store: %v", err)
+	}
+	assertPopulated(t, reopened)
+
+	reopened.SetCurrentBlock(2)
+	reopened, err = store.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Could not reopen store: %v", err)
+	}
+	if reopened.CurrentBlock() != 2 {
+		t.Errorf("Expected current block to be 2, got %d", reopened.CurrentBlock())
+	}
+}
+
+func TestFileStoreDiscardsTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	fileStore, err := store.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Could not open store: %v", err)
+	}
+	populate(t, fileStore)
+
+	// Simulate a crash in the middle of writing a change.
+	changes, err := os.OpenFile(filepath.Join(dir, "changes.log"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("Could not open log: %v", err)
+	}
+	changes.WriteString(`1a2b3c4d {"seq":4,"op":"setCurrentBl`)
+	changes.Close()
+
+	reopened, err := store.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Could not reopen store: %v", err)
+	}
+	assertPopulated(t, reopened)
+
+	reopened.SetCurrentBlock(3)
+	reopened, err = store.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Could not reopen store: %v", err)
+	}
+	if reopened.CurrentBlock() != 3 {
+		t.Errorf("Expected current block to be 3 after the torn write was discarded, got %d", reopened.CurrentBlock())
+	}
+}
+
+func TestFileStoreRejectsCorruptedLog(t *testing.T) {
+	dir := t.TempDir()
+	fileStore, err := store.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Could not open store: %v", err)
+	}
+	populate(t, fileStore)
+
+	// Corrupt the first change, the changes logged after it can't be trusted to apply.
+	path := filepath.Join(dir, "changes.log")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read log: %v", err)
+	}
+	content[len("1a2b3c4d {")] ^= 0xff
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("Could not write log: %v", err)
+	}
+
+	if _, err := store.NewFileStore(dir); !errors.Is(err, store.ErrLogCorrupted) {
+		t.Errorf("Expected ErrLogCorrupted, got %v", err)
+	}
+	if truncated, _ := os.ReadFile(path); len(truncated) != len(content) {
+		t.Errorf("Expected the corrupted log to be left untouched, got %d bytes instead of %d", len(truncated), len(content))
+	}
+}
+
+func TestFileStoreRejectsChangesAfterClose(t *testing.T) {
+	dir := t.TempDir()
+	fileStore, err := store.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Could not open store: %v", err)
+	}
+	populate(t, fileStore)
+	if err := fileStore.Close(); err != nil {
+		t.Fatalf("Could not close store: %v", err)
+	}
+	if err := fileStore.Close(); err != nil {
+		t.Errorf("Expected closing the store again to do nothing, got %v", err)
+	}
+
+	if err := fileStore.SetCurrentBlock(2); !errors.Is(err, store.ErrStoreClosed) {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+	if subscribed, err := fileStore.Subscribe(store.Subscription{Address: "0x456"}); subscribed || !errors.Is(err, store.ErrStoreClosed) {
+		t.Errorf("Expected subscription to fail with ErrStoreClosed, got %v", err)
+	}
+	// The rejected changes are not applied.
+	if fileStore.CurrentBlock() != 1 {
+		t.Errorf("Expected current block to stay 1, got %d", fileStore.CurrentBlock())
+	}
+	if _, exists := fileStore.Subscription("0x456"); exists {
+		t.Error("Expected rejected subscription not to be applied")
+	}
+}
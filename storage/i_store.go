@@ -3,20 +3,101 @@ Package store provides data access to transactions, subscribers and blocks data.
 */
 package store
 
-// IStore defines an interface for storing and managing blockchain data.
+import "time"
+
+// IStore defines an interface for storing and managing blockchain data. The changes fail when they cannot be
+// persisted, and are not applied then.
 type IStore interface {
 	// CurrentBlock returns the most recently processed block number.
 	CurrentBlock() int
 
 	// Transactions retrieves all transactions associated with the specified address.
-	Transactions(address string) []Transaction
+	Transactions(address Address) []Transaction
+
+	// QueryTransactions retrieves a page of the transactions of an address selected by the query, it fails with
+	// ErrInvalidCursor when the cursor of the query is invalid.
+	QueryTransactions(query TransactionQuery) (TransactionPage, error)
+
+	// TokenTransfers retrieves all token transfers sent or received by the specified address.
+	TokenTransfers(address Address) []TokenTransfer
+
+	// InternalTransactions retrieves all internal transactions sending ether to or from the specified address.
+	InternalTransactions(address Address) []InternalTransaction
+
+	// Withdrawals retrieves all beacon chain withdrawals credited to the specified address.
+	Withdrawals(address Address) []Withdrawal
+
+	// TransactionsSince retrieves up to limit transactions of the given addresses recorded after the sequence number,
+	// ordered by sequence number, leaving out the backfilled transactions.
+	TransactionsSince(addresses []Address, sequence uint64, limit int) []Transaction
+
+	// LatestSequence returns the sequence number of the latest recorded transaction.
+	LatestSequence() uint64
 
 	// SaveTransactions stores a list of transactions in the store.
-	SaveTransactions(transactions []Transaction)
+	SaveTransactions(transactions []Transaction) error
 
 	// SetCurrentBlock updates the current block number in the store.
-	SetCurrentBlock(blockNumber int)
+	SetCurrentBlock(blockNumber int) error
 
 	// Subscribe adds an address to the list of monitored addresses for transactions.
-	Subscribe(address string) bool
+	Subscribe(subscription Subscription) (bool, error)
+
+	// Unsubscribe removes an address from the list of monitored addresses, purging its stored transactions when asked to.
+	Unsubscribe(address Address, purge bool) (bool, error)
+
+	// Subscription retrieves the subscription of an address.
+	Subscription(address Address) (Subscription, bool)
+
+	// Subscriptions retrieves every subscription.
+	Subscriptions() []Subscription
+
+	// SaveWebhook registers a webhook for a subscribed address, it fails when the address is not subscribed.
+	// The webhooks of an address are removed along with its subscription.
+	SaveWebhook(webhook Webhook) (bool, error)
+
+	// RemoveWebhook removes a webhook by its id.
+	RemoveWebhook(id string) (bool, error)
+
+	// Webhooks retrieves the webhooks registered for an address, or every webhook when the address is empty.
+	Webhooks(address Address) []Webhook
+
+	// SaveDeliveries stores the webhook deliveries waiting for an attempt and the dead lettered ones, replacing the
+	// ones with the same id. The deliveries of a webhook are removed along with it.
+	SaveDeliveries(deliveries []Delivery) error
+
+	// RemoveDeliveries discards webhook deliveries by their ids.
+	RemoveDeliveries(ids []string) error
+
+	// Deliveries retrieves the stored webhook deliveries.
+	Deliveries() []Delivery
+
+	// SaveBackfillJob stores the progress of the backfill job of a subscribed address, replacing the previous one.
+	// The job of an address is removed along with its subscription.
+	SaveBackfillJob(job BackfillJob) error
+
+	// BackfillJobs retrieves the backfill jobs, in scheduling order.
+	BackfillJobs() []BackfillJob
+
+	// SaveBlock stores the header of a processed block along with its transactions, token transfers, internal
+	// transactions and withdrawals, and returns the block holding the records it newly stored, without the ones the
+	// store already held.
+	SaveBlock(block Block) (Block, error)
+
+	// Block retrieves the header of a processed block within the reorg window by its number.
+	Block(number int) (BlockHeader, bool)
+
+	// BlockTime retrieves the time of a block that contributed records to the store by its number.
+	BlockTime(number int) (time.Time, bool)
+
+	// BlockAt retrieves the number of the latest block that contributed records to the store produced at or before the
+	// given time.
+	BlockAt(t time.Time) (int, bool)
+
+	// BackfillTransactions stores the transactions, token transfers, internal transactions and withdrawals of a
+	// historical block involving the given address, skipping the already stored ones.
+	BackfillTransactions(address Address, block Block) error
+
+	// RemoveBlock discards a processed block and everything it contributed to the store.
+	RemoveBlock(number int) error
 }
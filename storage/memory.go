@@ -1,22 +1,62 @@
 package store
 
 import (
+	"sort"
 	"sync"
+	"time"
 )
 
+// ReorgWindow is the number of latest processed blocks whose headers are kept to detect reorganizations, along with
+// the hashes of the transactions they contributed to roll them back.
+const ReorgWindow = 64
+
 type MemoryStore struct {
 	// currentBlock stores the recent block that has been fetched.
 	currentBlock int
 	/*
-		subscribedAddr is a map where keys are Ethereum addresses, and values indicate
-		whether the address is subscribed for transaction monitoring.
+		subscriptions is a map where keys are the Ethereum addresses subscribed for transaction monitoring,
+		and values describe the subscriptions.
 	*/
-	subscribedAddr map[string]bool
+	subscriptions map[Address]Subscription
 	/*
 		transactions is a map that holds lists of transactions, indexed by Ethereum address.
-		Each key corresponds to an address, and the associated value is a slice of Transaction structs.
+		Each key corresponds to an address, and the associated value is a slice of Transaction structs,
+		ordered by block and by hash within a block so queries can binary search block ranges and cursors.
 	*/
-	transactions map[string][]Transaction
+	transactions map[Address][]Transaction
+	// directions indexes the positions of the transactions of every address by direction, ordered as the transactions,
+	// so a query for a direction doesn't scan the transactions of the other directions.
+	directions map[Address]map[Direction][]position
+	// details holds the complete transactions retrieved from blocks, indexed by the key of the transaction. A transaction
+	// stored for several addresses shares its details, the stored transactions don't carry them.
+	details map[string]*TransactionDetails
+	// tokenTransfers holds the token transfers, indexed by the addresses sending and receiving the tokens.
+	tokenTransfers map[Address][]TokenTransfer
+	// internalTransactions holds the internal transactions, indexed by the addresses sending and receiving the ether.
+	internalTransactions map[Address][]InternalTransaction
+	// withdrawals holds the beacon chain withdrawals, indexed by the credited address.
+	withdrawals map[Address][]Withdrawal
+	// blocks holds the headers of the processed blocks within the reorg window, indexed by block number.
+	blocks map[int]BlockHeader
+	// blockTransactions holds the hashes of the stored transactions, indexed by the number of the block within the reorg
+	// window that contributed them.
+	blockTransactions map[int][]string
+	// sequence is the sequence number of the latest recorded transaction.
+	sequence uint64
+	// sequenced indexes the streamed transactions by sequence number, every transaction being listed once for every
+	// address it is stored for. The backfilled transactions are not indexed.
+	sequenced []sequenceEntry
+	// webhooks holds the webhooks registered for subscribed addresses, indexed by id.
+	webhooks map[string]Webhook
+	// deliveries holds the webhook deliveries waiting for an attempt and the dead lettered ones, indexed by id.
+	deliveries map[string]Delivery
+	// backfillJobs holds the progress of the backfill jobs of subscribed addresses, indexed by address.
+	backfillJobs map[Address]BackfillJob
+	// blockTimes indexes the times of the blocks that contributed records to the store, ordered by block number. These
+	// are enough to turn a time range into the range of blocks holding the records produced within it.
+	blockTimes []blockTime
+	// keys indexes the keys of the records stored for every address, so a record is never stored twice for an address.
+	keys recordKeys
 	// mu is a mutex that ensures safe concurrent access to the TxParser's state.
 	mu sync.Mutex
 }
@@ -24,50 +64,1009 @@ type MemoryStore struct {
 // NewMemoryStore initializes a new Memory store.
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		subscribedAddr: make(map[string]bool),
-		transactions:   make(map[string][]Transaction),
+		subscriptions:        make(map[Address]Subscription),
+		transactions:         make(map[Address][]Transaction),
+		directions:           make(map[Address]map[Direction][]position),
+		details:              make(map[string]*TransactionDetails),
+		tokenTransfers:       make(map[Address][]TokenTransfer),
+		internalTransactions: make(map[Address][]InternalTransaction),
+		withdrawals:          make(map[Address][]Withdrawal),
+		blocks:               make(map[int]BlockHeader),
+		blockTransactions:    make(map[int][]string),
+		webhooks:             make(map[string]Webhook),
+		deliveries:           make(map[string]Delivery),
+		backfillJobs:         make(map[Address]BackfillJob),
+		keys:                 make(recordKeys),
+	}
+}
+
+// recordKeys holds the keys of the records stored for every address.
+type recordKeys map[Address]map[string]bool
+
+// add records the key for the address, and reports whether it was not recorded yet.
+func (k recordKeys) add(address Address, key string) bool {
+	keys, exists := k[address]
+	if !exists {
+		keys = make(map[string]bool)
+		k[address] = keys
+	}
+	if keys[key] {
+		return false
+	}
+	keys[key] = true
+	return true
+}
+
+// remove forgets the key of a record discarded for the address.
+func (k recordKeys) remove(address Address, key string) {
+	delete(k[address], key)
+}
+
+// Transactions fetches transactions records for a given address, ordered by block
+func (m *MemoryStore) Transactions(address Address) []Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.withDetails(append([]Transaction(nil), m.transactions[address]...))
+}
+
+// storeDetails moves the details of a transaction to the details shared by the addresses it is stored for.
+// The caller must hold the lock.
+func (m *MemoryStore) storeDetails(tx *Transaction) {
+	if tx.Details == nil {
+		return
+	}
+	if _, exists := m.details[tx.key()]; !exists {
+		m.details[tx.key()] = tx.Details
+	}
+	tx.Details = nil
+}
+
+// withDetails attaches their details to the transactions, in place.
+// The caller must hold the lock.
+func (m *MemoryStore) withDetails(transactions []Transaction) []Transaction {
+	for i := range transactions {
+		transactions[i].Details = m.details[transactions[i].key()]
+	}
+	return transactions
+}
+
+// QueryTransactions fetches a page of the transactions of an address selected by the query
+func (m *MemoryStore) QueryTransactions(query TransactionQuery) (TransactionPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The time range is narrowed down to the blocks produced within it, block times being increasing.
+	if !query.FromTime.IsZero() {
+		i := sort.Search(len(m.blockTimes), func(i int) bool { return m.blockTimes[i].Timestamp >= query.FromTime.Unix() })
+		if i == len(m.blockTimes) {
+			return TransactionPage{Transactions: []Transaction{}}, nil
+		}
+		query.FromBlock = max(query.FromBlock, m.blockTimes[i].Number)
+	}
+	if !query.ToTime.IsZero() {
+		i := sort.Search(len(m.blockTimes), func(i int) bool { return m.blockTimes[i].Timestamp > query.ToTime.Unix() })
+		if i == 0 {
+			return TransactionPage{Transactions: []Transaction{}}, nil
+		}
+		if query.ToBlock == 0 || m.blockTimes[i-1].Number < query.ToBlock {
+			query.ToBlock = m.blockTimes[i-1].Number
+		}
+	}
+	transactions := m.transactions[query.Address]
+	count, at := len(transactions), func(i int) Transaction { return transactions[i] }
+	if query.Direction != "" {
+		positions := m.directions[query.Address][query.Direction]
+		count, at = len(positions), func(i int) Transaction { return transactions[searchTransaction(transactions, positions[i])] }
+	}
+	page, err := queryTransactions(count, at, query)
+	m.withDetails(page.Transactions)
+	return page, err
+}
+
+// TransactionsSince fetches up to limit transactions of the given addresses recorded after the sequence number,
+// ordered by sequence number, leaving out the backfilled transactions. A transaction involving several of the
+// addresses is only listed once.
+func (m *MemoryStore) TransactionsSince(addresses []Address, sequence uint64, limit int) []Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wanted := make(map[Address]bool, len(addresses))
+	for _, address := range addresses {
+		wanted[address] = true
+	}
+	transactions := []Transaction{}
+	listed := make(map[string]bool)
+	start := sort.Search(len(m.sequenced), func(i int) bool { return m.sequenced[i].sequence > sequence })
+	for _, entry := range m.sequenced[start:] {
+		if limit > 0 && len(transactions) == limit {
+			break
+		}
+		if !wanted[entry.address] || listed[entry.position.hash] {
+			continue
+		}
+		if tx, exists := m.sequencedTransaction(entry); exists {
+			listed[entry.position.hash] = true
+			transactions = append(transactions, tx)
+		}
+	}
+	return m.withDetails(transactions)
+}
+
+// sequenceEntry locates a transaction stored for an address in the sequence index.
+type sequenceEntry struct {
+	sequence uint64
+	address  Address
+	position position
+}
+
+// sequencedTransaction returns the transaction an entry of the sequence index refers to, false when it was discarded
+// since.
+// The caller must hold the lock.
+func (m *MemoryStore) sequencedTransaction(entry sequenceEntry) (Transaction, bool) {
+	transactions := m.transactions[entry.address]
+	i := searchTransaction(transactions, entry.position)
+	if i == len(transactions) || positionOf(transactions[i]) != entry.position || transactions[i].Sequence != entry.sequence {
+		return Transaction{}, false
+	}
+	return transactions[i], true
+}
+
+// unsequence drops the entries of the sequence index matching the predicate.
+// The caller must hold the lock.
+func (m *MemoryStore) unsequence(drop func(sequenceEntry) bool) {
+	kept := m.sequenced[:0]
+	for _, entry := range m.sequenced {
+		if !drop(entry) {
+			kept = append(kept, entry)
+		}
+	}
+	m.sequenced = kept
+}
+
+// LatestSequence retrieves the sequence number of the latest recorded transaction
+func (m *MemoryStore) LatestSequence() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sequence
+}
+
+// blockTime is the time of a block, in seconds since the Unix epoch.
+type blockTime struct {
+	Number    int   `json:"number"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// indexBlockTime records the time of a block.
+// The caller must hold the lock.
+func (m *MemoryStore) indexBlockTime(header BlockHeader) {
+	if header.Timestamp == 0 {
+		return
+	}
+	i := sort.Search(len(m.blockTimes), func(i int) bool { return m.blockTimes[i].Number >= header.Number })
+	if i < len(m.blockTimes) && m.blockTimes[i].Number == header.Number {
+		m.blockTimes[i].Timestamp = header.Timestamp
+		return
 	}
+	m.blockTimes = append(m.blockTimes, blockTime{})
+	copy(m.blockTimes[i+1:], m.blockTimes[i:])
+	m.blockTimes[i] = blockTime{Number: header.Number, Timestamp: header.Timestamp}
 }
 
-// Transactions fetches transactions records for a given address
-func (m *MemoryStore) Transactions(address string) []Transaction {
+// BlockTime retrieves the time of a block that contributed records to the store.
+func (m *MemoryStore) BlockTime(number int) (time.Time, bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	return m.transactions[address]
+	i := sort.Search(len(m.blockTimes), func(i int) bool { return m.blockTimes[i].Number >= number })
+	if i == len(m.blockTimes) || m.blockTimes[i].Number != number {
+		return time.Time{}, false
+	}
+	return time.Unix(m.blockTimes[i].Timestamp, 0).UTC(), true
+}
+
+// BlockAt retrieves the latest block that contributed records to the store produced at or before the given time.
+func (m *MemoryStore) BlockAt(t time.Time) (int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := sort.Search(len(m.blockTimes), func(i int) bool { return m.blockTimes[i].Timestamp > t.Unix() })
+	if i == 0 {
+		return 0, false
+	}
+	return m.blockTimes[i-1].Number, true
+}
+
+// TokenTransfers fetches the token transfers sent or received by a given address
+func (m *MemoryStore) TokenTransfers(address Address) []TokenTransfer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]TokenTransfer(nil), m.tokenTransfers[address]...)
+}
+
+// InternalTransactions fetches the internal transactions sending ether to or from a given address
+func (m *MemoryStore) InternalTransactions(address Address) []InternalTransaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]InternalTransaction(nil), m.internalTransactions[address]...)
+}
+
+// Withdrawals fetches the beacon chain withdrawals credited to a given address
+func (m *MemoryStore) Withdrawals(address Address) []Withdrawal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Withdrawal(nil), m.withdrawals[address]...)
 }
 
 // CurrentBlock retrieves the latest processed block
 func (m *MemoryStore) CurrentBlock() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	return m.currentBlock
 }
 
 // SaveTransactions stores transaction in the transactions store
-func (m *MemoryStore) SaveTransactions(transactions []Transaction) {
+func (m *MemoryStore) SaveTransactions(transactions []Transaction) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.saveTransactions(transactions)
+	return nil
+}
+
+// saveTransactions stores the transactions involving subscribed addresses under each address they involve, and returns
+// their hashes along with the transactions newly recorded. A transaction already stored for an address is not stored
+// again, a newly recorded transaction is given the next sequence number.
+// The caller must hold the lock.
+func (m *MemoryStore) saveTransactions(transactions []Transaction) ([]string, []Transaction) {
+	var saved []string
+	var recorded []Transaction
 	for _, tx := range transactions {
-		if m.subscribedAddr[tx.From] || m.subscribedAddr[tx.To] {
-			m.transactions[tx.From] = append(m.transactions[tx.From], tx)
-			m.transactions[tx.To] = append(m.transactions[tx.To], tx)
+		if m.involvesSubscribed(tx) {
+			tx.Sequence = 0
+			m.storeDetails(&tx)
+			for _, address := range []Address{tx.From, tx.To, tx.ContractAddress} {
+				if address != "" && m.keys.add(address, tx.key()) {
+					if tx.Sequence == 0 {
+						m.sequence++
+						tx.Sequence = m.sequence
+					}
+					m.insertTransaction(address, tx)
+					m.sequenced = append(m.sequenced, sequenceEntry{sequence: tx.Sequence, address: address, position: positionOf(tx)})
+				}
+			}
+			saved = append(saved, tx.Hash)
+			if tx.Sequence > 0 {
+				recorded = append(recorded, tx)
+			}
+		}
+	}
+	return saved, recorded
+}
+
+// saveTokenTransfers stores the token transfers involving subscribed addresses, skipping the ones already stored, and
+// reports whether any involves a subscribed address along with the transfers newly recorded.
+// The caller must hold the lock.
+func (m *MemoryStore) saveTokenTransfers(transfers []TokenTransfer) (bool, []TokenTransfer) {
+	involved := false
+	var recorded []TokenTransfer
+	for _, transfer := range transfers {
+		if !m.subscribed(transfer.From) && !m.subscribed(transfer.To) {
+			continue
+		}
+		involved = true
+		added := false
+		for _, address := range []Address{transfer.From, transfer.To} {
+			if m.keys.add(address, transfer.key()) {
+				m.tokenTransfers[address] = append(m.tokenTransfers[address], transfer)
+				added = true
+			}
+		}
+		if added {
+			recorded = append(recorded, transfer)
+		}
+	}
+	return involved, recorded
+}
+
+// saveInternalTransactions stores the internal transactions involving subscribed addresses, skipping the ones already
+// stored, and reports whether any involves a subscribed address along with the internal transactions newly recorded.
+// The caller must hold the lock.
+func (m *MemoryStore) saveInternalTransactions(calls []InternalTransaction) (bool, []InternalTransaction) {
+	involved := false
+	var recorded []InternalTransaction
+	for _, call := range calls {
+		if !m.subscribed(call.From) && !m.subscribed(call.To) {
+			continue
+		}
+		involved = true
+		added := false
+		for _, address := range []Address{call.From, call.To} {
+			if m.keys.add(address, call.key()) {
+				m.internalTransactions[address] = append(m.internalTransactions[address], call)
+				added = true
+			}
+		}
+		if added {
+			recorded = append(recorded, call)
+		}
+	}
+	return involved, recorded
+}
+
+// saveWithdrawals stores the withdrawals credited to subscribed addresses, skipping the ones already stored, and reports
+// whether any is credited to a subscribed address along with the withdrawals newly recorded.
+// The caller must hold the lock.
+func (m *MemoryStore) saveWithdrawals(withdrawals []Withdrawal) (bool, []Withdrawal) {
+	involved := false
+	var recorded []Withdrawal
+	for _, withdrawal := range withdrawals {
+		if !m.subscribed(withdrawal.Address) {
+			continue
+		}
+		involved = true
+		if m.keys.add(withdrawal.Address, withdrawal.key()) {
+			m.withdrawals[withdrawal.Address] = append(m.withdrawals[withdrawal.Address], withdrawal)
+			recorded = append(recorded, withdrawal)
 		}
 	}
+	return involved, recorded
 }
 
 // Subscribe adds an address to the list of subscribers.
-func (m *MemoryStore) Subscribe(address string) bool {
+func (m *MemoryStore) Subscribe(subscription Subscription) (bool, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.subscribedAddr[address]; exists {
-		return false
+	if m.subscribed(subscription.Address) {
+		return false, nil
 	}
-	m.subscribedAddr[address] = true
-	return true
+	m.subscriptions[subscription.Address] = subscription
+	return true, nil
+}
+
+// Unsubscribe removes an address from the list of subscribers, along with its transactions when purging.
+func (m *MemoryStore) Unsubscribe(address Address, purge bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.subscribed(address) {
+		return false, nil
+	}
+	delete(m.subscriptions, address)
+	delete(m.backfillJobs, address)
+	for id, webhook := range m.webhooks {
+		if webhook.Address == address {
+			m.removeWebhook(id)
+		}
+	}
+	if purge {
+		// The details of a transaction are kept as long as another address holds the transaction.
+		for _, tx := range m.transactions[address] {
+			held := false
+			for _, other := range []Address{tx.From, tx.To, tx.ContractAddress} {
+				held = held || (other != address && m.keys[other][tx.key()])
+			}
+			if !held {
+				delete(m.details, tx.key())
+			}
+		}
+		delete(m.transactions, address)
+		delete(m.directions, address)
+		delete(m.tokenTransfers, address)
+		delete(m.internalTransactions, address)
+		delete(m.withdrawals, address)
+		delete(m.keys, address)
+		m.unsequence(func(entry sequenceEntry) bool { return entry.address == address })
+	}
+	return true, nil
+}
+
+// Subscription retrieves the subscription of an address.
+func (m *MemoryStore) Subscription(address Address) (Subscription, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subscription, exists := m.subscriptions[address]
+	return subscription, exists
+}
+
+// Subscriptions retrieves every subscription, ordered by address.
+func (m *MemoryStore) Subscriptions() []Subscription {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subscriptions := make([]Subscription, 0, len(m.subscriptions))
+	for _, subscription := range m.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].Address < subscriptions[j].Address
+	})
+	return subscriptions
+}
+
+// SaveWebhook registers a webhook for a subscribed address.
+func (m *MemoryStore) SaveWebhook(webhook Webhook) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.subscribed(webhook.Address) {
+		return false, nil
+	}
+	m.webhooks[webhook.ID] = webhook
+	return true, nil
+}
+
+// RemoveWebhook removes a webhook by its id.
+func (m *MemoryStore) RemoveWebhook(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.webhooks[id]; !exists {
+		return false, nil
+	}
+	m.removeWebhook(id)
+	return true, nil
+}
+
+// removeWebhook removes a webhook along with its deliveries.
+// The caller must hold the lock.
+func (m *MemoryStore) removeWebhook(id string) {
+	delete(m.webhooks, id)
+	for deliveryID, delivery := range m.deliveries {
+		if delivery.WebhookID == id {
+			delete(m.deliveries, deliveryID)
+		}
+	}
+}
+
+// hasWebhook reports whether a webhook is registered with the id.
+func (m *MemoryStore) hasWebhook(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, exists := m.webhooks[id]
+	return exists
+}
+
+// Webhooks retrieves the webhooks registered for an address, or every webhook when the address is empty, ordered by
+// registration time.
+func (m *MemoryStore) Webhooks(address Address) []Webhook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhooks := []Webhook{}
+	for _, webhook := range m.webhooks {
+		if address == "" || webhook.Address == address {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+		}
+		return webhooks[i].ID < webhooks[j].ID
+	})
+	return webhooks
+}
+
+// SaveDeliveries stores webhook deliveries, replacing the ones with the same id. The deliveries to webhooks that are no
+// longer registered are skipped.
+func (m *MemoryStore) SaveDeliveries(deliveries []Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, delivery := range deliveries {
+		if _, exists := m.webhooks[delivery.WebhookID]; exists {
+			m.deliveries[delivery.ID] = delivery
+		}
+	}
+	return nil
+}
+
+// RemoveDeliveries discards webhook deliveries by their ids.
+func (m *MemoryStore) RemoveDeliveries(ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		delete(m.deliveries, id)
+	}
+	return nil
+}
+
+// Deliveries retrieves the stored webhook deliveries, ordered by the time of their next attempt.
+func (m *MemoryStore) Deliveries() []Delivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deliveries := make([]Delivery, 0, len(m.deliveries))
+	for _, delivery := range m.deliveries {
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].NextAttempt.Equal(deliveries[j].NextAttempt) {
+			return deliveries[i].NextAttempt.Before(deliveries[j].NextAttempt)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	return deliveries
+}
+
+// SaveBackfillJob stores the progress of the backfill job of a subscribed address, replacing the previous one.
+func (m *MemoryStore) SaveBackfillJob(job BackfillJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.subscribed(job.Address) {
+		m.backfillJobs[job.Address] = job
+	}
+	return nil
+}
+
+// BackfillJobs retrieves the backfill jobs, in scheduling order.
+func (m *MemoryStore) BackfillJobs() []BackfillJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]BackfillJob, 0, len(m.backfillJobs))
+	for _, job := range m.backfillJobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].ScheduledAt.Equal(jobs[j].ScheduledAt) {
+			return jobs[i].ScheduledAt.Before(jobs[j].ScheduledAt)
+		}
+		return jobs[i].Address < jobs[j].Address
+	})
+	return jobs
+}
+
+// subscribed reports whether the address is in the list of subscribers.
+// The caller must hold the lock.
+func (m *MemoryStore) subscribed(address Address) bool {
+	_, exists := m.subscriptions[address]
+	return exists
+}
+
+// involvesSubscribed reports whether the transaction involves a subscribed address.
+// The caller must hold the lock.
+func (m *MemoryStore) involvesSubscribed(tx Transaction) bool {
+	return m.subscribed(tx.From) || m.subscribed(tx.To) || (tx.ContractAddress != "" && m.subscribed(tx.ContractAddress))
+}
+
+// subscribedTransactions filters the transactions involving subscribed addresses.
+func (m *MemoryStore) subscribedTransactions(transactions []Transaction) []Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var filtered []Transaction
+	for _, tx := range transactions {
+		if m.involvesSubscribed(tx) {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
+
+// subscribedTokenTransfers filters the token transfers involving subscribed addresses.
+func (m *MemoryStore) subscribedTokenTransfers(transfers []TokenTransfer) []TokenTransfer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var filtered []TokenTransfer
+	for _, transfer := range transfers {
+		if m.subscribed(transfer.From) || m.subscribed(transfer.To) {
+			filtered = append(filtered, transfer)
+		}
+	}
+	return filtered
+}
+
+// subscribedInternalTransactions filters the internal transactions involving subscribed addresses.
+func (m *MemoryStore) subscribedInternalTransactions(calls []InternalTransaction) []InternalTransaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var filtered []InternalTransaction
+	for _, call := range calls {
+		if m.subscribed(call.From) || m.subscribed(call.To) {
+			filtered = append(filtered, call)
+		}
+	}
+	return filtered
+}
+
+// subscribedWithdrawals filters the withdrawals credited to subscribed addresses.
+func (m *MemoryStore) subscribedWithdrawals(withdrawals []Withdrawal) []Withdrawal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var filtered []Withdrawal
+	for _, withdrawal := range withdrawals {
+		if m.subscribed(withdrawal.Address) {
+			filtered = append(filtered, withdrawal)
+		}
+	}
+	return filtered
 }
 
 // SetCurrentBlock stores the latest processed block
-func (m *MemoryStore) SetCurrentBlock(blockNumber int) {
+func (m *MemoryStore) SetCurrentBlock(blockNumber int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.currentBlock = blockNumber
+	return nil
+}
+
+// SaveBlock stores the block header and the transactions, token transfers, internal transactions and withdrawals of
+// the block involving subscribed addresses, and returns the block holding the records newly stored. The headers of
+// the blocks falling out of the reorg window are discarded.
+func (m *MemoryStore) SaveBlock(block Block) (Block, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// A block processed again contributes the same transactions.
+	recorded := Block{BlockHeader: block.BlockHeader}
+	var hashes []string
+	var transfers, calls, withdrawals bool
+	hashes, recorded.Transactions = m.saveTransactions(block.Transactions)
+	m.blockTransactions[block.Number] = appendMissing(m.blockTransactions[block.Number], hashes...)
+	transfers, recorded.TokenTransfers = m.saveTokenTransfers(block.TokenTransfers)
+	calls, recorded.InternalTransactions = m.saveInternalTransactions(block.InternalTransactions)
+	withdrawals, recorded.Withdrawals = m.saveWithdrawals(block.Withdrawals)
+	if len(hashes) > 0 || transfers || calls || withdrawals {
+		m.indexBlockTime(block.BlockHeader)
+	}
+	m.blocks[block.Number] = block.BlockHeader
+	m.pruneBlocks(block.Number - ReorgWindow)
+	return recorded, nil
+}
+
+// pruneBlocks discards the headers of the blocks up to the given one, which no reorganization rolls back anymore.
+// The caller must hold the lock.
+func (m *MemoryStore) pruneBlocks(number int) {
+	for n := range m.blocks {
+		if n <= number {
+			delete(m.blocks, n)
+			delete(m.blockTransactions, n)
+		}
+	}
+}
+
+// BackfillTransactions stores the transactions, token transfers, internal transactions and withdrawals of the block
+// involving the address, skipping the ones already stored for it.
+func (m *MemoryStore) BackfillTransactions(address Address, block Block) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	involved := false
+	for _, tx := range block.Transactions {
+		if !tx.Involves(address) {
+			continue
+		}
+		involved = true
+		if !m.keys.add(address, tx.key()) {
+			continue
+		}
+		m.sequence++
+		tx.Sequence = m.sequence
+		tx.Backfilled = true
+		m.storeDetails(&tx)
+		m.insertTransaction(address, tx)
+		// Transactions of a tracked block are rolled back along with it.
+		if _, tracked := m.blocks[block.Number]; tracked {
+			m.blockTransactions[block.Number] = appendMissing(m.blockTransactions[block.Number], tx.Hash)
+		}
+	}
+	for _, transfer := range block.TokenTransfers {
+		if !transfer.Involves(address) {
+			continue
+		}
+		involved = true
+		if m.keys.add(address, transfer.key()) {
+			m.tokenTransfers[address] = append(m.tokenTransfers[address], transfer)
+		}
+	}
+	for _, call := range block.InternalTransactions {
+		if !call.Involves(address) {
+			continue
+		}
+		involved = true
+		if m.keys.add(address, call.key()) {
+			m.internalTransactions[address] = append(m.internalTransactions[address], call)
+		}
+	}
+	for _, withdrawal := range block.Withdrawals {
+		if withdrawal.Address != address {
+			continue
+		}
+		involved = true
+		if m.keys.add(address, withdrawal.key()) {
+			m.withdrawals[address] = append(m.withdrawals[address], withdrawal)
+		}
+	}
+	if involved {
+		m.indexBlockTime(block.BlockHeader)
+	}
+	return nil
+}
+
+// appendMissing appends the hashes missing from the list.
+func appendMissing(hashes []string, missing ...string) []string {
+	for _, hash := range missing {
+		found := false
+		for _, h := range hashes {
+			if h == hash {
+				found = true
+				break
+			}
+		}
+		if !found {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// Block retrieves the header of a processed block within the reorg window.
+func (m *MemoryStore) Block(number int) (BlockHeader, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	header, exists := m.blocks[number]
+	return header, exists
+}
+
+// RemoveBlock discards the block header and every transaction the block contributed.
+func (m *MemoryStore) RemoveBlock(number int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orphaned := make(map[string]bool, len(m.blockTransactions[number]))
+	for _, hash := range m.blockTransactions[number] {
+		orphaned[hash] = true
+		delete(m.details, Transaction{Hash: hash}.key())
+	}
+	if len(orphaned) > 0 {
+		for address, transactions := range m.transactions {
+			m.transactions[address] = discard(m.keys, address, transactions, func(tx Transaction) bool {
+				return orphaned[tx.Hash]
+			})
+			if len(m.transactions[address]) < len(transactions) {
+				m.indexDirections(address)
+			}
+		}
+		m.unsequence(func(entry sequenceEntry) bool {
+			_, exists := m.sequencedTransaction(entry)
+			return !exists
+		})
+	}
+	for address, transfers := range m.tokenTransfers {
+		m.tokenTransfers[address] = discard(m.keys, address, transfers, func(transfer TokenTransfer) bool {
+			return transfer.BlockHeight() == number
+		})
+	}
+	for address, calls := range m.internalTransactions {
+		m.internalTransactions[address] = discard(m.keys, address, calls, func(call InternalTransaction) bool {
+			return call.BlockHeight() == number
+		})
+	}
+	for address, withdrawals := range m.withdrawals {
+		m.withdrawals[address] = discard(m.keys, address, withdrawals, func(withdrawal Withdrawal) bool {
+			return withdrawal.BlockHeight() == number
+		})
+	}
+	if i := sort.Search(len(m.blockTimes), func(i int) bool { return m.blockTimes[i].Number >= number }); i < len(m.blockTimes) && m.blockTimes[i].Number == number {
+		m.blockTimes = append(m.blockTimes[:i], m.blockTimes[i+1:]...)
+	}
+	delete(m.blockTransactions, number)
+	delete(m.blocks, number)
+	return nil
+}
+
+// insertTransaction stores the transaction for the address at its position, and indexes it by direction.
+// The caller must hold the lock.
+func (m *MemoryStore) insertTransaction(address Address, tx Transaction) {
+	m.transactions[address] = insertTransaction(m.transactions[address], tx)
+	directions, exists := m.directions[address]
+	if !exists {
+		directions = make(map[Direction][]position)
+		m.directions[address] = directions
+	}
+	direction, p := tx.DirectionOf(address), positionOf(tx)
+	positions := directions[direction]
+	i := sort.Search(len(positions), func(i int) bool { return p.before(positions[i]) })
+	positions = append(positions, position{})
+	copy(positions[i+1:], positions[i:])
+	positions[i] = p
+	directions[direction] = positions
+}
+
+// indexDirections rebuilds the direction index of the transactions of the address.
+// The caller must hold the lock.
+func (m *MemoryStore) indexDirections(address Address) {
+	directions := make(map[Direction][]position)
+	for _, tx := range m.transactions[address] {
+		direction := tx.DirectionOf(address)
+		directions[direction] = append(directions[direction], positionOf(tx))
+	}
+	m.directions[address] = directions
+}
+
+// keyed is a record of the store, identified by its key.
+type keyed interface {
+	key() string
+}
+
+// discard removes the records of an address matching the predicate, along with their keys.
+func discard[T keyed](keys recordKeys, address Address, records []T, orphaned func(T) bool) []T {
+	var kept []T
+	for _, record := range records {
+		if orphaned(record) {
+			keys.remove(address, record.key())
+		} else {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// memorySnapshot is a copy of the whole state of a memory store.
+type memorySnapshot struct {
+	CurrentBlock         int                               `json:"currentBlock"`
+	Sequence             uint64                            `json:"sequence"`
+	Subscriptions        []Subscription                    `json:"subscriptions"`
+	Webhooks             []Webhook                         `json:"webhooks"`
+	Deliveries           []Delivery                        `json:"deliveries,omitempty"`
+	BackfillJobs         []BackfillJob                     `json:"backfillJobs,omitempty"`
+	Transactions         map[Address][]Transaction         `json:"transactions"`
+	Details              map[string]*TransactionDetails    `json:"details,omitempty"`
+	TokenTransfers       map[Address][]TokenTransfer       `json:"tokenTransfers"`
+	InternalTransactions map[Address][]InternalTransaction `json:"internalTransactions"`
+	Withdrawals          map[Address][]Withdrawal          `json:"withdrawals"`
+	Blocks               map[int]BlockHeader               `json:"blocks"`
+	BlockTransactions    map[int][]string                  `json:"blockTransactions"`
+	BlockTimes           []blockTime                       `json:"blockTimes"`
+}
+
+// snapshot copies the state of the store.
+func (m *MemoryStore) snapshot() memorySnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := memorySnapshot{
+		CurrentBlock:         m.currentBlock,
+		Sequence:             m.sequence,
+		Transactions:         make(map[Address][]Transaction, len(m.transactions)),
+		Details:              make(map[string]*TransactionDetails, len(m.details)),
+		TokenTransfers:       make(map[Address][]TokenTransfer, len(m.tokenTransfers)),
+		InternalTransactions: make(map[Address][]InternalTransaction, len(m.internalTransactions)),
+		Withdrawals:          make(map[Address][]Withdrawal, len(m.withdrawals)),
+		Blocks:               make(map[int]BlockHeader, len(m.blocks)),
+		BlockTransactions:    make(map[int][]string, len(m.blockTransactions)),
+		BlockTimes:           append([]blockTime(nil), m.blockTimes...),
+	}
+	for _, subscription := range m.subscriptions {
+		snapshot.Subscriptions = append(snapshot.Subscriptions, subscription)
+	}
+	for _, webhook := range m.webhooks {
+		snapshot.Webhooks = append(snapshot.Webhooks, webhook)
+	}
+	for _, delivery := range m.deliveries {
+		snapshot.Deliveries = append(snapshot.Deliveries, delivery)
+	}
+	for _, job := range m.backfillJobs {
+		snapshot.BackfillJobs = append(snapshot.BackfillJobs, job)
+	}
+	for address, transactions := range m.transactions {
+		snapshot.Transactions[address] = append([]Transaction(nil), transactions...)
+	}
+	for key, details := range m.details {
+		snapshot.Details[key] = details
+	}
+	for address, transfers := range m.tokenTransfers {
+		snapshot.TokenTransfers[address] = append([]TokenTransfer(nil), transfers...)
+	}
+	for address, calls := range m.internalTransactions {
+		snapshot.InternalTransactions[address] = append([]InternalTransaction(nil), calls...)
+	}
+	for address, withdrawals := range m.withdrawals {
+		snapshot.Withdrawals[address] = append([]Withdrawal(nil), withdrawals...)
+	}
+	for number, header := range m.blocks {
+		snapshot.Blocks[number] = header
+	}
+	for number, hashes := range m.blockTransactions {
+		snapshot.BlockTransactions[number] = append([]string(nil), hashes...)
+	}
+	return snapshot
+}
+
+// restore replaces the state of the store with the snapshot.
+func (m *MemoryStore) restore(snapshot memorySnapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.currentBlock = snapshot.CurrentBlock
+	m.sequence = snapshot.Sequence
+	m.subscriptions = make(map[Address]Subscription, len(snapshot.Subscriptions))
+	for _, subscription := range snapshot.Subscriptions {
+		m.subscriptions[subscription.Address] = subscription
+	}
+	m.webhooks = make(map[string]Webhook, len(snapshot.Webhooks))
+	for _, webhook := range snapshot.Webhooks {
+		m.webhooks[webhook.ID] = webhook
+	}
+	m.deliveries = make(map[string]Delivery, len(snapshot.Deliveries))
+	for _, delivery := range snapshot.Deliveries {
+		m.deliveries[delivery.ID] = delivery
+	}
+	m.backfillJobs = make(map[Address]BackfillJob, len(snapshot.BackfillJobs))
+	for _, job := range snapshot.BackfillJobs {
+		m.backfillJobs[job.Address] = job
+	}
+	// The keys are not persisted, they are rebuilt from the records.
+	m.keys = make(recordKeys)
+	m.details = make(map[string]*TransactionDetails, len(snapshot.Details))
+	for key, details := range snapshot.Details {
+		m.details[key] = details
+	}
+	m.transactions = make(map[Address][]Transaction, len(snapshot.Transactions))
+	for address, transactions := range snapshot.Transactions {
+		m.transactions[address] = deduplicate(m.keys, address, transactions)
+		sortTransactions(m.transactions[address])
+	}
+	m.directions = make(map[Address]map[Direction][]position, len(m.transactions))
+	for address := range m.transactions {
+		m.indexDirections(address)
+	}
+	m.tokenTransfers = make(map[Address][]TokenTransfer, len(snapshot.TokenTransfers))
+	for address, transfers := range snapshot.TokenTransfers {
+		m.tokenTransfers[address] = deduplicate(m.keys, address, transfers)
+	}
+	m.internalTransactions = make(map[Address][]InternalTransaction, len(snapshot.InternalTransactions))
+	for address, calls := range snapshot.InternalTransactions {
+		m.internalTransactions[address] = deduplicate(m.keys, address, calls)
+	}
+	m.withdrawals = make(map[Address][]Withdrawal, len(snapshot.Withdrawals))
+	for address, withdrawals := range snapshot.Withdrawals {
+		m.withdrawals[address] = deduplicate(m.keys, address, withdrawals)
+	}
+	m.sequenced = nil
+	for address, transactions := range m.transactions {
+		for _, tx := range transactions {
+			if tx.Sequence > 0 && !tx.Backfilled {
+				m.sequenced = append(m.sequenced, sequenceEntry{sequence: tx.Sequence, address: address, position: positionOf(tx)})
+			}
+		}
+	}
+	sort.Slice(m.sequenced, func(i, j int) bool { return m.sequenced[i].sequence < m.sequenced[j].sequence })
+	m.blocks = make(map[int]BlockHeader, len(snapshot.Blocks))
+	for number, header := range snapshot.Blocks {
+		m.blocks[number] = header
+	}
+	m.blockTimes = snapshot.BlockTimes
+	m.blockTransactions = make(map[int][]string, len(snapshot.BlockTransactions))
+	for number, hashes := range snapshot.BlockTransactions {
+		m.blockTransactions[number] = hashes
+	}
+}
+
+// deduplicate records the keys of the records of an address, dropping the records already recorded.
+func deduplicate[T keyed](keys recordKeys, address Address, records []T) []T {
+	var kept []T
+	for _, record := range records {
+		if address != "" && keys.add(address, record.key()) {
+			kept = append(kept, record)
+		}
+	}
+	return kept
 }
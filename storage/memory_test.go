@@ -1,6 +1,8 @@
 package store_test
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
 
 	store "github.com/mo-mohamed/txparser/storage"
@@ -12,7 +14,7 @@ func TestTransactions(t *testing.T) {
 		{Hash: "0xabc", From: "0x123", To: "0x456", Value: "1000", BlockNumber: "1"},
 	}
 
-	memoryStore.Subscribe("0x123")
+	memoryStore.Subscribe(store.Subscription{Address: "0x123"})
 	memoryStore.SaveTransactions(transactions)
 
 	transactions = memoryStore.Transactions("0x123")
@@ -27,8 +29,8 @@ func TestTransactions(t *testing.T) {
 
 func TestSaveTransactions(t *testing.T) {
 	memoryStore := store.NewMemoryStore()
-	memoryStore.Subscribe("0x123")
-	memoryStore.Subscribe("0x456")
+	memoryStore.Subscribe(store.Subscription{Address: "0x123"})
+	memoryStore.Subscribe(store.Subscription{Address: "0x456"})
 	transactions := []store.Transaction{
 		{Hash: "0xabc", From: "0x123", To: "0x456", Value: "1000", BlockNumber: "1"},
 	}
@@ -47,11 +49,11 @@ func TestSaveTransactions(t *testing.T) {
 func TestSubscribe(t *testing.T) {
 	memoryStore := store.NewMemoryStore()
 
-	if !memoryStore.Subscribe("0x123") {
+	if subscribed, err := memoryStore.Subscribe(store.Subscription{Address: "0x123"}); !subscribed || err != nil {
 		t.Error("Expected subscription to succeed for new address")
 	}
 
-	if memoryStore.Subscribe("0x123") {
+	if subscribed, _ := memoryStore.Subscribe(store.Subscription{Address: "0x123"}); subscribed {
 		t.Error("Expected subscription to fail for already subscribed address")
 	}
 }
@@ -65,3 +67,406 @@ func TestSetCurrentBlock(t *testing.T) {
 		t.Errorf("Expected current block to be 200, got %d", memoryStore.CurrentBlock())
 	}
 }
+
+func TestRemoveBlock(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0x123"})
+	memoryStore.SaveBlock(store.Block{
+		BlockHeader:  store.BlockHeader{Number: 1, Hash: "0xb1", ParentHash: "0xb0"},
+		Transactions: []store.Transaction{{Hash: "0xabc", From: "0x123", To: "0x456", Value: "1000", BlockNumber: "1"}},
+	})
+	memoryStore.SaveBlock(store.Block{
+		BlockHeader:  store.BlockHeader{Number: 2, Hash: "0xb2", ParentHash: "0xb1"},
+		Transactions: []store.Transaction{{Hash: "0xdef", From: "0x456", To: "0x123", Value: "2000", BlockNumber: "2"}},
+	})
+
+	if header, exists := memoryStore.Block(2); !exists || header.Hash != "0xb2" {
+		t.Errorf("Expected block 2 to be stored with hash '0xb2', got '%s'", header.Hash)
+	}
+
+	memoryStore.RemoveBlock(2)
+
+	if _, exists := memoryStore.Block(2); exists {
+		t.Error("Expected block 2 to be removed")
+	}
+	transactions := memoryStore.Transactions("0x123")
+	if len(transactions) != 1 || transactions[0].Hash != "0xabc" {
+		t.Errorf("Expected only the transaction of block 1 to remain, got %v", transactions)
+	}
+}
+
+func TestTransactionDetailsShared(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0x123"})
+	memoryStore.Subscribe(store.Subscription{Address: "0x456"})
+	details := store.TransactionDetails{Hash: "0xabc", From: "0x123", To: "0x456", Value: *store.NewQuantity(1000),
+		BlockNumber: store.NewQuantity(1)}
+	memoryStore.SaveTransactions([]store.Transaction{details.Slim()})
+
+	sent, received := memoryStore.Transactions("0x123"), memoryStore.Transactions("0x456")
+	if len(sent) != 1 || len(received) != 1 || sent[0].Details == nil || sent[0].Details != received[0].Details {
+		t.Errorf("Expected both addresses to share the details of the transaction, got %v and %v", sent, received)
+	}
+	if streamed := memoryStore.TransactionsSince([]store.Address{"0x123"}, 0, 0); len(streamed) != 1 || streamed[0].Details == nil {
+		t.Errorf("Expected the details to be listed by sequence, got %v", streamed)
+	}
+
+	// The details are kept while an address holds the transaction.
+	memoryStore.Unsubscribe("0x123", true)
+	if received := memoryStore.Transactions("0x456"); len(received) != 1 || received[0].Details == nil {
+		t.Errorf("Expected the details to be kept for '0x456', got %v", received)
+	}
+	memoryStore.Unsubscribe("0x456", true)
+	memoryStore.Subscribe(store.Subscription{Address: "0x456"})
+	memoryStore.SaveTransactions([]store.Transaction{{Hash: "0xabc", From: "0x123", To: "0x456", Value: "0x3e8", BlockNumber: "0x1"}})
+	if received := memoryStore.Transactions("0x456"); len(received) != 1 || received[0].Details != nil {
+		t.Errorf("Expected the details to be purged along with the transaction, got %v", received)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0x123", Label: "hot wallet"})
+	memoryStore.Subscribe(store.Subscription{Address: "0x456"})
+	memoryStore.SaveTransactions([]store.Transaction{
+		{Hash: "0xabc", From: "0x123", To: "0x456", Value: "1000", BlockNumber: "1"},
+	})
+
+	if subscriptions := memoryStore.Subscriptions(); len(subscriptions) != 2 || subscriptions[0].Label != "hot wallet" {
+		t.Errorf("Expected 2 subscriptions ordered by address, got %v", subscriptions)
+	}
+
+	if unsubscribed, err := memoryStore.Unsubscribe("0x123", false); !unsubscribed || err != nil {
+		t.Error("Expected unsubscription to succeed")
+	}
+	if unsubscribed, _ := memoryStore.Unsubscribe("0x123", false); unsubscribed {
+		t.Error("Expected unsubscription to fail for an address not subscribed")
+	}
+	if _, exists := memoryStore.Subscription("0x123"); exists {
+		t.Error("Expected subscription to be removed")
+	}
+	if transactions := memoryStore.Transactions("0x123"); len(transactions) != 1 {
+		t.Errorf("Expected transactions to be retained, got %d", len(transactions))
+	}
+
+	memoryStore.Unsubscribe("0x456", true)
+	if transactions := memoryStore.Transactions("0x456"); len(transactions) != 0 {
+		t.Errorf("Expected transactions to be purged, got %d", len(transactions))
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0x123"})
+	memoryStore.Subscribe(store.Subscription{Address: "0x456"})
+
+	if saved, _ := memoryStore.SaveWebhook(store.Webhook{ID: "w0", Address: "0x789"}); saved {
+		t.Error("Expected a webhook of an address not subscribed to be rejected")
+	}
+	memoryStore.SaveWebhook(store.Webhook{ID: "w1", Address: "0x123", Events: []store.EventType{store.EventWithdrawal}})
+	memoryStore.SaveWebhook(store.Webhook{ID: "w2", Address: "0x456"})
+	if webhooks := memoryStore.Webhooks(""); len(webhooks) != 2 {
+		t.Errorf("Expected 2 webhooks, got %v", webhooks)
+	}
+	if webhooks := memoryStore.Webhooks("0x123"); len(webhooks) != 1 || webhooks[0].Accepts(store.EventTransaction) || !webhooks[0].Accepts(store.EventWithdrawal) {
+		t.Errorf("Expected the withdrawal webhook of 0x123, got %v", webhooks)
+	}
+
+	memoryStore.Unsubscribe("0x123", false)
+	if webhooks := memoryStore.Webhooks("0x123"); len(webhooks) != 0 {
+		t.Errorf("Expected the webhooks to be removed along with the subscription, got %v", webhooks)
+	}
+	memoryStore.SaveDeliveries([]store.Delivery{{ID: "d1", WebhookID: "w2"}, {ID: "d2", WebhookID: "w0"}})
+	if deliveries := memoryStore.Deliveries(); len(deliveries) != 1 || deliveries[0].ID != "d1" {
+		t.Errorf("Expected only the delivery to a registered webhook to be stored, got %+v", deliveries)
+	}
+	removed, _ := memoryStore.RemoveWebhook("w2")
+	removedAgain, _ := memoryStore.RemoveWebhook("w2")
+	if !removed || removedAgain {
+		t.Error("Expected the webhook to be removed once")
+	}
+	if deliveries := memoryStore.Deliveries(); len(deliveries) != 0 {
+		t.Errorf("Expected the deliveries to be removed along with the webhook, got %+v", deliveries)
+	}
+}
+
+func TestSaveBlockPrunesOldBlocks(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0x123"})
+	// Block 1 holds a transaction of the subscribed address, the following blocks hold none.
+	for number := 1; number <= 2*store.ReorgWindow; number++ {
+		block := store.Block{BlockHeader: store.BlockHeader{Number: number, Hash: fmt.Sprintf("0x%x", number), Timestamp: int64(12 * number)}}
+		if number == 1 {
+			block.Transactions = []store.Transaction{{Hash: "0xabc", From: "0x123", To: "0x456", BlockNumber: "1"}}
+		}
+		memoryStore.SaveBlock(block)
+	}
+
+	if _, exists := memoryStore.Block(store.ReorgWindow); exists {
+		t.Errorf("Expected block %d to fall out of the reorg window", store.ReorgWindow)
+	}
+	if _, exists := memoryStore.Block(store.ReorgWindow + 1); !exists {
+		t.Errorf("Expected block %d to be within the reorg window", store.ReorgWindow+1)
+	}
+	if _, ok := memoryStore.BlockTime(1); !ok {
+		t.Error("Expected the time of a block holding records to be kept")
+	}
+	if _, ok := memoryStore.BlockTime(2); ok {
+		t.Error("Expected the time of a block holding no records not to be kept")
+	}
+	if transactions := memoryStore.Transactions("0x123"); len(transactions) != 1 {
+		t.Errorf("Expected the transactions of a pruned block to be kept, got %+v", transactions)
+	}
+}
+
+func TestSaveBlockContractCreation(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0xc0de"})
+
+	memoryStore.SaveBlock(store.Block{
+		BlockHeader: store.BlockHeader{Number: 1},
+		Transactions: []store.Transaction{
+			{Hash: "0xabc", From: "0x123", ContractAddress: "0xc0de", BlockNumber: "1"},
+			{Hash: "0xdef", From: "0x123", To: "0x456", BlockNumber: "1"},
+		},
+	})
+
+	transactions := memoryStore.Transactions("0xc0de")
+	if len(transactions) != 1 || transactions[0].Hash != "0xabc" {
+		t.Errorf("Expected the creation of the contract to be stored for its address, got %+v", transactions)
+	}
+
+	memoryStore.RemoveBlock(1)
+	if transactions := memoryStore.Transactions("0xc0de"); len(transactions) != 0 {
+		t.Errorf("Expected the creation of the contract to be rolled back, got %+v", transactions)
+	}
+}
+
+func TestSaveBlockTokenTransfers(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0x456"})
+	transfer := store.TokenTransfer{TransactionHash: "0xabc", LogIndex: 1, BlockNumber: "0x1", Token: "0x70c", From: "0x123", To: "0x456", Amount: "0x1"}
+
+	memoryStore.SaveBlock(store.Block{
+		BlockHeader:    store.BlockHeader{Number: 1},
+		Transactions:   []store.Transaction{{Hash: "0xabc", From: "0x123", To: "0x70c", BlockNumber: "0x1"}},
+		TokenTransfers: []store.TokenTransfer{transfer},
+	})
+	memoryStore.BackfillTransactions("0x456", store.Block{
+		BlockHeader:    store.BlockHeader{Number: 1},
+		TokenTransfers: []store.TokenTransfer{transfer},
+	})
+
+	if transfers := memoryStore.TokenTransfers("0x456"); len(transfers) != 1 || transfers[0] != transfer {
+		t.Errorf("Expected the token transfer to be stored once for its recipient, got %+v", transfers)
+	}
+	if transactions := memoryStore.Transactions("0x456"); len(transactions) != 0 {
+		t.Errorf("Expected the transaction to the token contract not to be stored for the recipient, got %+v", transactions)
+	}
+
+	memoryStore.RemoveBlock(1)
+	if transfers := memoryStore.TokenTransfers("0x456"); len(transfers) != 0 {
+		t.Errorf("Expected the token transfer to be rolled back, got %+v", transfers)
+	}
+}
+
+func TestSaveBlockInternalTransactions(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0xd1"})
+	call := store.InternalTransaction{TransactionHash: "0xa", TraceAddress: []int{0, 1}, BlockNumber: "0x1", Type: "call", From: "0xc1", To: "0xd1", Value: "0x2"}
+
+	memoryStore.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: 1}, InternalTransactions: []store.InternalTransaction{call}})
+	memoryStore.BackfillTransactions("0xd1", store.Block{BlockHeader: store.BlockHeader{Number: 1}, InternalTransactions: []store.InternalTransaction{call}})
+
+	if calls := memoryStore.InternalTransactions("0xd1"); len(calls) != 1 || calls[0].Value != "0x2" {
+		t.Errorf("Expected the internal transaction to be stored once for its recipient, got %+v", calls)
+	}
+
+	memoryStore.RemoveBlock(1)
+	if calls := memoryStore.InternalTransactions("0xd1"); len(calls) != 0 {
+		t.Errorf("Expected the internal transaction to be rolled back, got %+v", calls)
+	}
+}
+
+func TestSaveBlockWithdrawals(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0xd1"})
+	withdrawals := []store.Withdrawal{
+		{Index: "0x10", ValidatorIndex: "0x5", Address: "0xd1", Amount: "0x3b9aca00", BlockNumber: "0x1"},
+		{Index: "0x11", ValidatorIndex: "0x6", Address: "0xd2", Amount: "0x1", BlockNumber: "0x1"},
+	}
+
+	memoryStore.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: 1}, Withdrawals: withdrawals})
+	memoryStore.BackfillTransactions("0xd1", store.Block{BlockHeader: store.BlockHeader{Number: 1}, Withdrawals: withdrawals})
+
+	if stored := memoryStore.Withdrawals("0xd1"); len(stored) != 1 || stored[0].Index != "0x10" {
+		t.Errorf("Expected the withdrawal to be stored once for its address, got %+v", stored)
+	}
+	if stored := memoryStore.Withdrawals("0xd2"); len(stored) != 0 {
+		t.Errorf("Expected the withdrawal to an unsubscribed address to be ignored, got %+v", stored)
+	}
+
+	memoryStore.RemoveBlock(1)
+	if stored := memoryStore.Withdrawals("0xd1"); len(stored) != 0 {
+		t.Errorf("Expected the withdrawal to be rolled back, got %+v", stored)
+	}
+}
+
+func TestSaveTransactionsSelfTransfer(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0x123"})
+
+	memoryStore.SaveTransactions([]store.Transaction{
+		{Hash: "0xabc", From: "0x123", To: "0x123", Value: "1000", BlockNumber: "1"},
+		{Hash: "0xdef", From: "0x123", To: "", Value: "0", BlockNumber: "1"},
+	})
+	memoryStore.SaveTransactions([]store.Transaction{{Hash: "0xABC", From: "0x123", To: "0x123", Value: "1000", BlockNumber: "1"}})
+
+	if transactions := memoryStore.Transactions("0x123"); len(transactions) != 2 {
+		t.Errorf("Expected each transaction to be stored once, got %+v", transactions)
+	}
+	if transactions := memoryStore.Transactions(""); len(transactions) != 0 {
+		t.Errorf("Expected no transaction stored under an empty address, got %+v", transactions)
+	}
+}
+
+func TestReplayBlocksIsIdempotent(t *testing.T) {
+	blocks := []store.Block{
+		{
+			BlockHeader:    store.BlockHeader{Number: 1, Hash: "0xb1"},
+			Transactions:   []store.Transaction{{Hash: "0xa", From: "0x123", To: "0x456", Value: "0x1", BlockNumber: "0x1"}},
+			TokenTransfers: []store.TokenTransfer{{TransactionHash: "0xa", LogIndex: 0, BlockNumber: "0x1", From: "0x123", To: "0x123", Amount: "0x1"}},
+		},
+		{
+			BlockHeader:          store.BlockHeader{Number: 2, Hash: "0xb2", ParentHash: "0xb1"},
+			Transactions:         []store.Transaction{{Hash: "0xb", From: "0x456", To: "0x123", Value: "0x2", BlockNumber: "0x2"}},
+			InternalTransactions: []store.InternalTransaction{{TransactionHash: "0xb", TraceAddress: []int{0}, BlockNumber: "0x2", Type: "call", From: "0x789", To: "0x123", Value: "0x1"}},
+			Withdrawals:          []store.Withdrawal{{Index: "0x1", ValidatorIndex: "0x1", Address: "0x123", Amount: "0x1", BlockNumber: "0x2"}},
+		},
+	}
+	// count counts the records of a block.
+	count := func(block store.Block) int {
+		return len(block.Transactions) + len(block.TokenTransfers) + len(block.InternalTransactions) + len(block.Withdrawals)
+	}
+	// state captures everything the store holds for the subscribed address.
+	state := func(memoryStore *store.MemoryStore) []interface{} {
+		return []interface{}{memoryStore.Transactions("0x123"), memoryStore.TokenTransfers("0x123"),
+			memoryStore.InternalTransactions("0x123"), memoryStore.Withdrawals("0x123")}
+	}
+
+	once := store.NewMemoryStore()
+	once.Subscribe(store.Subscription{Address: "0x123"})
+	for _, block := range blocks {
+		if recorded, _ := once.SaveBlock(block); recorded.Number != block.Number || count(recorded) != count(block) {
+			t.Errorf("Expected every record of block %d to be newly stored, got %+v", block.Number, recorded)
+		}
+	}
+
+	// The blocks are processed again, and overlapped by a backfill of the address.
+	replayed := store.NewMemoryStore()
+	replayed.Subscribe(store.Subscription{Address: "0x123"})
+	for _, block := range blocks {
+		replayed.SaveBlock(block)
+		replayed.BackfillTransactions("0x123", block)
+	}
+	for _, block := range blocks {
+		recorded, _ := replayed.SaveBlock(block)
+		if count(recorded) > 0 {
+			t.Errorf("Expected no record of replayed block %d to be newly stored, got %+v", block.Number, recorded)
+		}
+	}
+
+	if !reflect.DeepEqual(state(once), state(replayed)) {
+		t.Errorf("Expected replaying the blocks to yield the same state, got %+v and %+v", state(once), state(replayed))
+	}
+
+	// A replayed block is still rolled back entirely, its transactions are recorded again with a new sequence number.
+	replayed.RemoveBlock(2)
+	if recorded, _ := replayed.SaveBlock(blocks[1]); len(recorded.Transactions) != 1 {
+		t.Errorf("Expected the transaction of the rolled back block to be newly stored, got %+v", recorded)
+	}
+	transactions := replayed.Transactions("0x123")
+	if len(transactions) != 2 || transactions[1].Sequence != 3 {
+		t.Fatalf("Expected the transaction of the rolled back block to be recorded again, got %+v", transactions)
+	}
+	rerecorded := transactions[1]
+	rerecorded.Sequence = 2
+	if !reflect.DeepEqual(state(once)[1:], state(replayed)[1:]) || !reflect.DeepEqual(once.Transactions("0x123"), []store.Transaction{transactions[0], rerecorded}) {
+		t.Errorf("Expected a rolled back block to be stored again, got %+v", state(replayed))
+	}
+}
+
+func TestTransactionsSince(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0x123"})
+	memoryStore.Subscribe(store.Subscription{Address: "0x456"})
+	memoryStore.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: 2}, Transactions: []store.Transaction{
+		{Hash: "0xa", From: "0x123", To: "0x456", BlockNumber: "2"},
+		{Hash: "0xb", From: "0x789", To: "0x456", BlockNumber: "2"},
+	}})
+	// A backfilled transaction is recorded after the ones of the processed blocks, although it is older, it is not
+	// streamed.
+	memoryStore.BackfillTransactions("0x123", store.Block{BlockHeader: store.BlockHeader{Number: 1}, Transactions: []store.Transaction{
+		{Hash: "0xc", From: "0x789", To: "0x123", BlockNumber: "1"},
+	}})
+	memoryStore.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: 3}, Transactions: []store.Transaction{
+		{Hash: "0xd", From: "0x123", To: "0x789", BlockNumber: "3"},
+	}})
+
+	if sequence := memoryStore.LatestSequence(); sequence != 4 {
+		t.Errorf("Expected 4 recorded transactions, got %d", sequence)
+	}
+	if transactions := memoryStore.Transactions("0x123"); len(transactions) != 3 || !transactions[0].Backfilled || transactions[1].Backfilled {
+		t.Errorf("Expected the backfilled transaction to be marked, got %+v", transactions)
+	}
+	var hashes []string
+	for _, tx := range memoryStore.TransactionsSince([]store.Address{"0x123", "0x456"}, 0, 0) {
+		hashes = append(hashes, fmt.Sprintf("%s:%d", tx.Hash, tx.Sequence))
+	}
+	if got := fmt.Sprint(hashes); got != "[0xa:1 0xb:2 0xd:4]" {
+		t.Errorf("Expected the transactions in the order they were recorded, got %s", got)
+	}
+	if transactions := memoryStore.TransactionsSince([]store.Address{"0x123"}, 1, 10); len(transactions) != 1 || transactions[0].Hash != "0xd" {
+		t.Errorf("Expected the transaction of 0x123 recorded after the first one, got %+v", transactions)
+	}
+	if transactions := memoryStore.TransactionsSince([]store.Address{"0x456"}, 0, 1); len(transactions) != 1 || transactions[0].Hash != "0xa" {
+		t.Errorf("Expected the limit to apply, got %+v", transactions)
+	}
+
+	// The transactions of a rolled back block are no longer listed.
+	memoryStore.RemoveBlock(3)
+	if transactions := memoryStore.TransactionsSince([]store.Address{"0x123"}, 1, 10); len(transactions) != 0 {
+		t.Errorf("Expected the rolled back transaction not to be listed, got %+v", transactions)
+	}
+}
+
+func TestConcurrentSaveAndRead(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0x123"})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The blocks are saved in reverse order, so every transaction is inserted ahead of the stored ones.
+		for block := 2000; block > 0; block-- {
+			memoryStore.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: block}, Transactions: []store.Transaction{
+				{Hash: fmt.Sprintf("0x%x", block), From: "0x123", To: "0x456", Value: "0x1", BlockNumber: fmt.Sprint(block)},
+			}})
+		}
+	}()
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+		for _, tx := range memoryStore.Transactions("0x123") {
+			if tx.Hash == "" {
+				t.Fatal("Expected every read transaction to be complete")
+			}
+		}
+	}
+	if transactions := memoryStore.Transactions("0x123"); len(transactions) != 2000 {
+		t.Errorf("Expected 2000 transactions, got %d", len(transactions))
+	}
+}
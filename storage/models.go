@@ -1,14 +1,487 @@
 package store
 
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TransactionStatus describes how final a transaction is.
+type TransactionStatus string
+
+const (
+	// StatusPendingConfirmation marks a transaction that is not yet buried under enough blocks.
+	StatusPendingConfirmation TransactionStatus = "pending-confirmation"
+	// StatusConfirmed marks a transaction that reached the confirmation depth.
+	StatusConfirmed TransactionStatus = "confirmed"
+	// StatusFinalized marks a transaction included in a finalized block.
+	StatusFinalized TransactionStatus = "finalized"
+	// StatusPending marks a transaction waiting in the mempool to be mined.
+	StatusPending TransactionStatus = "pending"
+	// StatusReplaced marks a pending transaction replaced by another transaction with the same sender and nonce.
+	StatusReplaced TransactionStatus = "replaced"
+	// StatusDropped marks a pending transaction that left the mempool without being mined.
+	StatusDropped TransactionStatus = "dropped"
+)
+
+// ExecutionStatus tells whether a transaction succeeded, according to its receipt.
+type ExecutionStatus string
+
+const (
+	// ExecutionSucceeded marks a transaction that was executed successfully.
+	ExecutionSucceeded ExecutionStatus = "success"
+	// ExecutionReverted marks a transaction that reverted, its value was not transferred although the fee was paid.
+	ExecutionReverted ExecutionStatus = "reverted"
+)
+
 type Transaction struct {
 	// Hash is the unique identifier for this transaction.
 	Hash string `json:"hash"`
 	// From is the Ethereum address that initiated the transaction.
-	From string `json:"from"`
+	From Address `json:"from"`
 	// To is the Ethereum address of the account that is the recipient of the transaction.
-	To string `json:"to"`
+	To Address `json:"to"`
 	// Value is the transaction amount.
 	Value string `json:"value"`
 	// BlockNumber is the number of the transaction.
 	BlockNumber string `json:"blockNumber"`
+	// Timestamp is the time of the block including the transaction, nil while pending.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	// Nonce is the number of transactions sent by the sender before this one.
+	Nonce string `json:"nonce,omitempty"`
+	// Status is the finality of the transaction at the time it was retrieved.
+	Status TransactionStatus `json:"status,omitempty"`
+	// ExecutionStatus tells whether the transaction succeeded or reverted.
+	ExecutionStatus ExecutionStatus `json:"executionStatus,omitempty"`
+	// GasUsed is the amount of gas consumed by the transaction.
+	GasUsed string `json:"gasUsed,omitempty"`
+	// EffectiveGasPrice is the price paid per unit of gas.
+	EffectiveGasPrice string `json:"effectiveGasPrice,omitempty"`
+	// Fee is the amount paid by the sender for the gas consumed, in wei.
+	Fee string `json:"fee,omitempty"`
+	// ContractAddress is the address of the contract created by the transaction, if any.
+	ContractAddress Address `json:"contractAddress,omitempty"`
+	// Details is the complete transaction as returned by the network, when it was retrieved from a block.
+	Details *TransactionDetails `json:"details,omitempty"`
+	// Sequence orders the transactions by the time the store recorded them, zero until recorded.
+	Sequence uint64 `json:"sequence,omitempty"`
+	// Backfilled tells whether the transaction was recorded by scanning the history of the address, such transactions
+	// are not streamed.
+	Backfilled bool `json:"backfilled,omitempty"`
+}
+
+// Involves reports whether the address sent, received or was created by the transaction.
+func (t Transaction) Involves(address Address) bool {
+	return t.From == address || t.To == address || (t.ContractAddress != "" && t.ContractAddress == address)
+}
+
+// key identifies the transaction among the records of the store.
+func (t Transaction) key() string {
+	return "tx:" + strings.ToLower(t.Hash)
+}
+
+// BlockHeight returns the block number of the transaction, which can either be hex encoded or decimal.
+func (t Transaction) BlockHeight() int {
+	height, err := strconv.ParseInt(t.BlockNumber, 0, 64)
+	if err != nil {
+		return 0
+	}
+	return int(height)
+}
+
+// TransactionType is the EIP-2718 type of a transaction.
+type TransactionType uint8
+
+const (
+	// TransactionLegacy marks transactions paying a single gas price.
+	TransactionLegacy TransactionType = 0
+	// TransactionAccessList marks EIP-2930 transactions declaring the addresses and storage keys they access.
+	TransactionAccessList TransactionType = 1
+	// TransactionDynamicFee marks EIP-1559 transactions paying a base fee along with a priority fee.
+	TransactionDynamicFee TransactionType = 2
+	// TransactionBlob marks EIP-4844 transactions carrying blobs.
+	TransactionBlob TransactionType = 3
+)
+
+// TransactionDetails is a transaction with every field returned by the network, the quantities being typed.
+// The fields a transaction type doesn't define are omitted.
+type TransactionDetails struct {
+	// Hash is the unique identifier for this transaction.
+	Hash string `json:"hash"`
+	// Type is the EIP-2718 type of the transaction.
+	Type Quantity `json:"type"`
+	// ChainID is the chain the transaction is signed for, legacy transactions may not be bound to a chain.
+	ChainID *Quantity `json:"chainId,omitempty"`
+	// Nonce is the number of transactions sent by the sender before this one.
+	Nonce Quantity `json:"nonce"`
+	// BlockHash is the hash of the block including the transaction, empty while pending.
+	BlockHash string `json:"blockHash,omitempty"`
+	// BlockNumber is the number of the block including the transaction, nil while pending.
+	BlockNumber *Quantity `json:"blockNumber,omitempty"`
+	// TransactionIndex is the position of the transaction in its block, nil while pending.
+	TransactionIndex *Quantity `json:"transactionIndex,omitempty"`
+	// From is the address that signed the transaction.
+	From Address `json:"from"`
+	// To is the recipient of the transaction, empty for contract creations.
+	To Address `json:"to,omitempty"`
+	// Value is the amount of wei transferred.
+	Value Quantity `json:"value"`
+	// Gas is the gas limit of the transaction.
+	Gas Quantity `json:"gas"`
+	// GasPrice is the price paid per unit of gas for legacy and access list transactions, the effective gas price
+	// for the others once mined.
+	GasPrice *Quantity `json:"gasPrice,omitempty"`
+	// MaxFeePerGas is the maximum price per unit of gas of EIP-1559 transactions, base fee included.
+	MaxFeePerGas *Quantity `json:"maxFeePerGas,omitempty"`
+	// MaxPriorityFeePerGas is the maximum tip per unit of gas of EIP-1559 transactions.
+	MaxPriorityFeePerGas *Quantity `json:"maxPriorityFeePerGas,omitempty"`
+	// MaxFeePerBlobGas is the maximum price per unit of blob gas of EIP-4844 transactions.
+	MaxFeePerBlobGas *Quantity `json:"maxFeePerBlobGas,omitempty"`
+	// Input is the raw call data of the transaction, hex encoded.
+	Input string `json:"input"`
+	// AccessList lists the addresses and storage keys EIP-2930 and later transactions declared they access.
+	AccessList []AccessTuple `json:"accessList,omitempty"`
+	// BlobVersionedHashes are the versioned hashes of the blobs carried by EIP-4844 transactions.
+	BlobVersionedHashes []string `json:"blobVersionedHashes,omitempty"`
+}
+
+// AccessTuple is an address along with the storage keys of the address a transaction accesses.
+type AccessTuple struct {
+	// Address is the accessed address.
+	Address Address `json:"address"`
+	// StorageKeys are the accessed storage slots of the address.
+	StorageKeys []string `json:"storageKeys"`
+}
+
+// TransactionType returns the EIP-2718 type of the transaction.
+func (t TransactionDetails) TransactionType() TransactionType {
+	return TransactionType(t.Type.Uint64())
+}
+
+// Slim returns the transaction reduced to the fields of the transaction list, which keeps the complete transaction
+// as its details.
+func (t TransactionDetails) Slim() Transaction {
+	tx := Transaction{
+		Hash:    t.Hash,
+		From:    t.From,
+		To:      t.To,
+		Value:   t.Value.Hex(),
+		Nonce:   t.Nonce.Hex(),
+		Details: &t,
+	}
+	if t.BlockNumber != nil {
+		tx.BlockNumber = t.BlockNumber.Hex()
+	}
+	return tx
+}
+
+// TokenStandard is the standard implemented by a token contract.
+type TokenStandard string
+
+const (
+	// StandardERC20 marks fungible tokens.
+	StandardERC20 TokenStandard = "erc20"
+	// StandardERC721 marks non-fungible tokens, each token id being unique.
+	StandardERC721 TokenStandard = "erc721"
+	// StandardERC1155 marks multi tokens, each token id having its own supply.
+	StandardERC1155 TokenStandard = "erc1155"
+)
+
+// TokenTransfer is a token transfer, decoded from a transfer event logged by a token contract.
+type TokenTransfer struct {
+	// TransactionHash is the hash of the transaction that logged the transfer.
+	TransactionHash string `json:"transactionHash"`
+	// LogIndex is the position of the log in the block, it identifies the transfer along with the transaction hash.
+	LogIndex int `json:"logIndex"`
+	// BatchIndex is the position of the transfer within an ERC-1155 batch transfer.
+	BatchIndex int `json:"batchIndex,omitempty"`
+	// BlockNumber is the number of the block including the transfer.
+	BlockNumber string `json:"blockNumber"`
+	// Timestamp is the time of the block including the transfer.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	// Standard is the standard of the token.
+	Standard TokenStandard `json:"standard"`
+	// Token is the address of the token contract.
+	Token Address `json:"token"`
+	// Operator is the address that made an ERC-1155 transfer on behalf of the sender.
+	Operator Address `json:"operator,omitempty"`
+	// From is the address the tokens were transferred from.
+	From Address `json:"from"`
+	// To is the address the tokens were transferred to.
+	To Address `json:"to"`
+	// TokenID identifies the transferred non-fungible token, it is empty for ERC-20 transfers.
+	TokenID string `json:"tokenId,omitempty"`
+	// Amount is the raw amount of tokens transferred, not scaled by the decimals of the token.
+	Amount string `json:"amount"`
+	// Status is the finality of the transfer at the time it was retrieved.
+	Status TransactionStatus `json:"status,omitempty"`
+}
+
+// BlockHeight returns the block number of the transfer, which can either be hex encoded or decimal.
+func (t TokenTransfer) BlockHeight() int {
+	return Transaction{BlockNumber: t.BlockNumber}.BlockHeight()
+}
+
+// Involves reports whether the address sent or received the tokens.
+func (t TokenTransfer) Involves(address Address) bool {
+	return t.From == address || t.To == address
+}
+
+// NonFungible reports whether the transfer moves non-fungible or multi tokens.
+func (t TokenTransfer) NonFungible() bool {
+	return t.Standard == StandardERC721 || t.Standard == StandardERC1155
+}
+
+// key identifies the transfer among the records of the store.
+func (t TokenTransfer) key() string {
+	return "transfer:" + strings.ToLower(t.TransactionHash) + ":" + strconv.Itoa(t.LogIndex) + ":" + strconv.Itoa(t.BatchIndex)
+}
+
+// InternalTransaction is a transfer of ether made by a contract during the execution of a transaction, which only
+// shows up in the traces of the transaction.
+type InternalTransaction struct {
+	// TransactionHash is the hash of the transaction the call was made in.
+	TransactionHash string `json:"transactionHash"`
+	// TraceAddress is the call path from the transaction to the call, the indexes of the calls made at each depth.
+	TraceAddress []int `json:"traceAddress"`
+	// BlockNumber is the number of the block including the transaction.
+	BlockNumber string `json:"blockNumber"`
+	// Timestamp is the time of the block including the transaction.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	// Type is the kind of call, one of "call", "create", "create2" or "selfdestruct".
+	Type string `json:"type"`
+	// From is the address of the contract that made the call.
+	From Address `json:"from"`
+	// To is the address that received the value, the created contract for creations.
+	To Address `json:"to"`
+	// Value is the amount of ether transferred.
+	Value string `json:"value"`
+	// Status is the finality of the call at the time it was retrieved.
+	Status TransactionStatus `json:"status,omitempty"`
+}
+
+// BlockHeight returns the block number of the call, which can either be hex encoded or decimal.
+func (t InternalTransaction) BlockHeight() int {
+	return Transaction{BlockNumber: t.BlockNumber}.BlockHeight()
+}
+
+// Involves reports whether the address sent or received the value.
+func (t InternalTransaction) Involves(address Address) bool {
+	return t.From == address || t.To == address
+}
+
+// key identifies the call among the records of the store.
+func (t InternalTransaction) key() string {
+	key := "call:" + strings.ToLower(t.TransactionHash)
+	for _, index := range t.TraceAddress {
+		key += ":" + strconv.Itoa(index)
+	}
+	return key
+}
+
+// Withdrawal is a withdrawal of ether from the beacon chain, credited to an address by the block without any transaction.
+type Withdrawal struct {
+	// Index is the position of the withdrawal among every withdrawal of the chain.
+	Index string `json:"index"`
+	// ValidatorIndex is the index of the validator the ether is withdrawn from.
+	ValidatorIndex string `json:"validatorIndex"`
+	// Address is the address credited with the withdrawn ether.
+	Address Address `json:"address"`
+	// Amount is the amount of ether withdrawn, in gwei.
+	Amount string `json:"amount"`
+	// BlockNumber is the number of the block including the withdrawal.
+	BlockNumber string `json:"blockNumber"`
+	// Timestamp is the time of the block including the withdrawal.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	// Status is the finality of the withdrawal at the time it was retrieved.
+	Status TransactionStatus `json:"status,omitempty"`
+}
+
+// BlockHeight returns the block number of the withdrawal, which can either be hex encoded or decimal.
+func (w Withdrawal) BlockHeight() int {
+	return Transaction{BlockNumber: w.BlockNumber}.BlockHeight()
+}
+
+// key identifies the withdrawal among the records of the store.
+func (w Withdrawal) key() string {
+	return "withdrawal:" + strings.ToLower(w.Index)
+}
+
+// BlockHeader identifies a processed block and links it to its parent, which allows detecting chain reorganizations.
+type BlockHeader struct {
+	// Number is the height of the block.
+	Number int `json:"number"`
+	// Hash is the hash of the block.
+	Hash string `json:"hash"`
+	// ParentHash is the hash of the block this block was built on.
+	ParentHash string `json:"parentHash"`
+	// Timestamp is the time the block was produced, in seconds since the Unix epoch.
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+// Time returns the time the block was produced, the zero time when unknown.
+func (h BlockHeader) Time() time.Time {
+	if h.Timestamp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(h.Timestamp, 0).UTC()
+}
+
+// Block is a block header along with the transactions included in the block.
+type Block struct {
+	BlockHeader
+	// Transactions are the transactions included in the block.
+	Transactions []Transaction `json:"transactions"`
+	// TokenTransfers are the token transfers logged by the transactions of the block.
+	TokenTransfers []TokenTransfer `json:"tokenTransfers,omitempty"`
+	// InternalTransactions are the transfers of ether made by contracts during the transactions of the block.
+	InternalTransactions []InternalTransaction `json:"internalTransactions,omitempty"`
+	// Withdrawals are the beacon chain withdrawals credited by the block.
+	Withdrawals []Withdrawal `json:"withdrawals,omitempty"`
+}
+
+// Subscription describes an address monitored for transactions.
+type Subscription struct {
+	// Address is the monitored Ethereum address.
+	Address Address `json:"address"`
+	// Label is a free form name given to the subscription.
+	Label string `json:"label,omitempty"`
+	// Owner identifies who the subscription belongs to.
+	Owner string `json:"owner,omitempty"`
+	// CreatedAtBlock is the current block at the time of the subscription.
+	CreatedAtBlock int `json:"createdAtBlock"`
+	// CreatedAt is the time of the subscription.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// EventType identifies a kind of activity delivered to webhooks.
+type EventType string
+
+const (
+	// EventTransaction is a transaction sent or received by the address.
+	EventTransaction EventType = "transaction"
+	// EventTokenTransfer is an ERC-20, ERC-721 or ERC-1155 transfer sent or received by the address.
+	EventTokenTransfer EventType = "token-transfer"
+	// EventInternalTransaction is an internal transaction sending ether to or from the address.
+	EventInternalTransaction EventType = "internal-transaction"
+	// EventWithdrawal is a beacon chain withdrawal credited to the address.
+	EventWithdrawal EventType = "withdrawal"
+)
+
+// Webhook describes an endpoint notified of the activity of a subscribed address.
+type Webhook struct {
+	// ID identifies the webhook.
+	ID string `json:"id"`
+	// Address is the subscribed address whose activity is delivered.
+	Address Address `json:"address"`
+	// URL is the endpoint the events are posted to.
+	URL string `json:"url"`
+	// Events lists the types of the delivered events, every type when empty.
+	Events []EventType `json:"events,omitempty"`
+	// Secret is the key the payloads are signed with.
+	Secret string `json:"secret,omitempty"`
+	// CreatedAt is the time of the registration.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Accepts reports whether the events of the given type are delivered to the webhook.
+func (w Webhook) Accepts(event EventType) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvent is the payload posted to a webhook when activity of its address is recorded.
+type WebhookEvent struct {
+	// ID identifies the event, which is shared by the deliveries to every webhook of the address.
+	ID string `json:"id"`
+	// Type is the type of the recorded activity.
+	Type EventType `json:"type"`
+	// Address is the subscribed address involved in the activity.
+	Address Address `json:"address"`
+	// BlockNumber is the block the activity was recorded from.
+	BlockNumber int `json:"blockNumber"`
+	// CreatedAt is the time the activity was recorded.
+	CreatedAt time.Time `json:"createdAt"`
+	// Data is the JSON encoding of the transaction, token transfer, internal transaction or withdrawal, along with its
+	// status.
+	Data json.RawMessage `json:"data"`
+}
+
+// DeliveryState is the state of the delivery of an event to a webhook.
+type DeliveryState string
+
+const (
+	// DeliveryPending marks a delivery waiting for an attempt, either its first one or a retry.
+	DeliveryPending DeliveryState = "pending"
+	// DeliveryDelivered marks a delivery accepted by the endpoint with a 2xx status.
+	DeliveryDelivered DeliveryState = "delivered"
+	// DeliveryFailed marks a delivery that failed every attempt and was moved to the dead letters.
+	DeliveryFailed DeliveryState = "failed"
+)
+
+// Delivery is the delivery of an event to a webhook.
+type Delivery struct {
+	// ID identifies the delivery.
+	ID string `json:"id"`
+	// WebhookID is the id of the webhook the event is delivered to.
+	WebhookID string `json:"webhookId"`
+	// Event is the delivered event.
+	Event WebhookEvent `json:"event"`
+	// State is "pending" while the event is being delivered, and "failed" once every attempt failed.
+	State DeliveryState `json:"state"`
+	// Attempts is the number of failed attempts.
+	Attempts int `json:"attempts"`
+	// LastError is the error of the latest attempt.
+	LastError string `json:"lastError,omitempty"`
+	// NextAttempt is the time the event can be delivered again.
+	NextAttempt time.Time `json:"nextAttempt"`
+}
+
+// BackfillStatus describes the state of a backfill job.
+type BackfillStatus string
+
+const (
+	// BackfillQueued marks a job waiting for the backfill worker.
+	BackfillQueued BackfillStatus = "queued"
+	// BackfillRunning marks a job being scanned by the backfill worker.
+	BackfillRunning BackfillStatus = "running"
+	// BackfillCompleted marks a job that caught up with the polled blocks.
+	BackfillCompleted BackfillStatus = "completed"
+	// BackfillCanceled marks a job stopped because its address got unsubscribed.
+	BackfillCanceled BackfillStatus = "canceled"
+	// BackfillUnsupported marks a job scanning from the first activity of an address that could not be located, the
+	// address never sending a transaction nor holding ether, such as an address only ever holding tokens.
+	BackfillUnsupported BackfillStatus = "unsupported"
+)
+
+// BackfillJob describes the progress of scanning the history of a subscribed address.
+type BackfillJob struct {
+	// Address is the subscribed address the history is scanned for.
+	Address Address `json:"address"`
+	// FromGenesis tells whether the history is scanned from the first block the address was active in.
+	FromGenesis bool `json:"fromGenesis,omitempty"`
+	// FromBlock is the first block of the scanned range, zero until it is located for the jobs scanning from the
+	// first activity of the address.
+	FromBlock int `json:"fromBlock"`
+	// ToBlock is the last block of the scanned range, it follows the polled blocks until the job completes.
+	ToBlock int `json:"toBlock"`
+	// ScannedBlock is the most recently scanned block.
+	ScannedBlock int `json:"scannedBlock"`
+	// Approximate tells the scanned range may start after the first activity of the address, for the jobs scanning
+	// from it. The range starts at the first block the address held ether in when it had sent no transaction yet, and
+	// a contract may have received ether and moved it out before. The range is also cut to the maximum number of
+	// backfilled blocks.
+	Approximate bool `json:"approximate,omitempty"`
+	// Status is the state of the job.
+	Status BackfillStatus `json:"status"`
+	// ScheduledAt is the time the job was scheduled.
+	ScheduledAt time.Time `json:"scheduledAt"`
 }
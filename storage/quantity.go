@@ -0,0 +1,105 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Quantity is an unsigned integer of arbitrary size, such as an amount of wei, encoded in JSON as a "0x" prefixed hex
+// string like the quantities of the Ethereum JSON-RPC API. A quantity is immutable, its copies share its value. The
+// zero quantity is zero.
+type Quantity struct {
+	value *big.Int
+}
+
+// NewQuantity returns the quantity holding the given value.
+func NewQuantity(value int64) *Quantity {
+	return &Quantity{value: big.NewInt(value)}
+}
+
+// ParseQuantity decodes a quantity, either hex encoded with a "0x" prefix or decimal.
+func ParseQuantity(s string) (*Quantity, error) {
+	value := new(big.Int)
+	var ok bool
+	if digits, hex := strings.CutPrefix(s, "0x"); hex {
+		_, ok = value.SetString(digits, 16)
+	} else {
+		_, ok = value.SetString(s, 10)
+	}
+	if !ok || value.Sign() < 0 {
+		return nil, fmt.Errorf("invalid quantity %q", s)
+	}
+	return &Quantity{value: value}, nil
+}
+
+// BigInt returns a copy of the value of the quantity.
+func (q Quantity) BigInt() *big.Int {
+	if q.value == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(q.value)
+}
+
+// Int64 returns the value of the quantity as an int64, which is undefined when it doesn't fit.
+func (q Quantity) Int64() int64 {
+	if q.value == nil {
+		return 0
+	}
+	return q.value.Int64()
+}
+
+// Uint64 returns the value of the quantity as an uint64, which is undefined when it doesn't fit.
+func (q Quantity) Uint64() uint64 {
+	if q.value == nil {
+		return 0
+	}
+	return q.value.Uint64()
+}
+
+// Sign returns 0 when the quantity is zero, and 1 otherwise.
+func (q Quantity) Sign() int {
+	if q.value == nil {
+		return 0
+	}
+	return q.value.Sign()
+}
+
+// Cmp compares the quantity with another one, returning -1, 0 or 1 when it is lower, equal or greater.
+func (q Quantity) Cmp(other Quantity) int {
+	return q.BigInt().Cmp(other.BigInt())
+}
+
+// String returns the decimal encoding of the quantity.
+func (q Quantity) String() string {
+	return q.BigInt().String()
+}
+
+// Hex returns the "0x" prefixed hex encoding of the quantity, without leading zeros.
+func (q Quantity) Hex() string {
+	return "0x" + q.BigInt().Text(16)
+}
+
+// MarshalJSON encodes the quantity as a hex string.
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.Hex())
+}
+
+// UnmarshalJSON decodes a hex or decimal string, a null quantity decodes to zero.
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == nil {
+		q.value = nil
+		return nil
+	}
+	parsed, err := ParseQuantity(*s)
+	if err != nil {
+		return err
+	}
+	q.value = parsed.value
+	return nil
+}
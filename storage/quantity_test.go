@@ -0,0 +1,77 @@
+package store_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+func TestParseQuantity(t *testing.T) {
+	for s, expected := range map[string]string{"0x0": "0x0", "0x01f4": "0x1f4", "500": "0x1f4",
+		"0xde0b6b3a7640000000": "0xde0b6b3a7640000000"} {
+		q, err := store.ParseQuantity(s)
+		if err != nil || q.Hex() != expected {
+			t.Errorf("Expected %s to parse to %s, got %v %v", s, expected, q, err)
+		}
+	}
+	for _, s := range []string{"", "0x", "0xzz", "-1", "1.5"} {
+		if _, err := store.ParseQuantity(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestTransactionDetailsJSON(t *testing.T) {
+	var details store.TransactionDetails
+	err := json.Unmarshal([]byte(`{"hash":"0xabc","type":"0x3","chainId":"0x1","nonce":"0x7","blockHash":"0xb1",
+		"blockNumber":"0x64","transactionIndex":"0x0","from":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"to":"0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359","value":"0xde0b6b3a7640000000","gas":"0x5208",
+		"gasPrice":"0x3b9aca00","maxFeePerGas":"0x77359400","maxPriorityFeePerGas":"0x3b9aca00","maxFeePerBlobGas":"0x1",
+		"input":"0xa9059cbb","accessList":[{"address":"0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359","storageKeys":["0x01"]}],
+		"blobVersionedHashes":["0x01ab"]}`), &details)
+	if err != nil {
+		t.Fatalf("Could not decode transaction: %v", err)
+	}
+	if details.TransactionType() != store.TransactionBlob || details.BlockNumber.Int64() != 100 ||
+		details.MaxFeePerGas.Int64() != 2000000000 || details.Input != "0xa9059cbb" ||
+		len(details.AccessList) != 1 || len(details.BlobVersionedHashes) != 1 {
+		t.Errorf("Expected every field to be decoded, got %+v", details)
+	}
+
+	tx := details.Slim()
+	if tx.Value != "0xde0b6b3a7640000000" || tx.BlockNumber != "0x64" || tx.BlockHeight() != 100 || tx.Nonce != "0x7" ||
+		tx.From != "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed" || tx.Details == nil {
+		t.Errorf("Expected the slim view of the transaction, got %+v", tx)
+	}
+
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		t.Fatalf("Could not encode transaction: %v", err)
+	}
+	var decoded store.TransactionDetails
+	if err := json.Unmarshal(encoded, &decoded); err != nil || decoded.Value.Cmp(details.Value) != 0 ||
+		decoded.ChainID.Int64() != 1 {
+		t.Errorf("Expected the transaction to survive a round trip, got %s %v", encoded, err)
+	}
+}
+
+func TestLegacyTransactionDetails(t *testing.T) {
+	// Pending legacy transactions carry no block, and contract creations no recipient.
+	var details store.TransactionDetails
+	err := json.Unmarshal([]byte(`{"hash":"0xabc","type":"0x0","nonce":"0x0","blockHash":null,"blockNumber":null,
+		"transactionIndex":null,"from":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed","to":null,"value":"0x0",
+		"gas":"0x5208","gasPrice":"0x1","input":"0x6080"}`), &details)
+	if err != nil {
+		t.Fatalf("Could not decode transaction: %v", err)
+	}
+	if details.BlockNumber != nil || details.To != "" || details.MaxFeePerGas != nil || details.Slim().BlockNumber != "" {
+		t.Errorf("Expected the missing fields to be empty, got %+v", details)
+	}
+	encoded, _ := json.Marshal(details)
+	var fields map[string]interface{}
+	json.Unmarshal(encoded, &fields)
+	if _, exists := fields["maxFeePerGas"]; exists {
+		t.Errorf("Expected the fields of other transaction types to be omitted, got %s", encoded)
+	}
+}
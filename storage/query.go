@@ -0,0 +1,196 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for cursors that were not returned by a previous query.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Direction tells how a transaction relates to the queried address.
+type Direction string
+
+const (
+	// DirectionIncoming selects the transactions received by the address.
+	DirectionIncoming Direction = "incoming"
+	// DirectionOutgoing selects the transactions sent by the address to another address.
+	DirectionOutgoing Direction = "outgoing"
+	// DirectionSelf selects the transactions sent by the address to itself.
+	DirectionSelf Direction = "self"
+)
+
+// TransactionQuery selects a page of the transactions of an address. The transactions are ordered by block, and by
+// hash within a block, which gives every transaction a stable position to resume from.
+type TransactionQuery struct {
+	// Address is the address the transactions are queried for.
+	Address Address
+	// FromBlock, when set, excludes the transactions of the blocks before it.
+	FromBlock int
+	// ToBlock, when set, excludes the transactions of the blocks after it.
+	ToBlock int
+	// FromTime, when set, excludes the transactions of the blocks produced before it.
+	FromTime time.Time
+	// ToTime, when set, excludes the transactions of the blocks produced after it.
+	ToTime time.Time
+	// Direction, when set, only selects the transactions of the given direction.
+	Direction Direction
+	// MinValue, when set, excludes the transactions transferring less wei.
+	MinValue *Quantity
+	// Descending lists the latest transactions first.
+	Descending bool
+	// Cursor is the cursor returned along with the previous page, empty for the first page.
+	Cursor string
+	// Limit is the maximum number of transactions of the page, zero for no limit.
+	Limit int
+	// Match, when set, is an additional condition the transactions must meet.
+	Match func(Transaction) bool
+}
+
+// TransactionPage is a page of the transactions selected by a query.
+type TransactionPage struct {
+	// Transactions are the transactions of the page.
+	Transactions []Transaction `json:"transactions"`
+	// NextCursor resumes the query after the page, it is empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Matches reports whether the transaction meets the filters of the query, the block range aside.
+func (q TransactionQuery) Matches(tx Transaction) bool {
+	if q.Direction != "" && tx.DirectionOf(q.Address) != q.Direction {
+		return false
+	}
+	if q.MinValue != nil {
+		value, err := ParseQuantity(tx.Value)
+		if err != nil || value.Cmp(*q.MinValue) < 0 {
+			return false
+		}
+	}
+	return q.Match == nil || q.Match(tx)
+}
+
+// DirectionOf tells how the transaction relates to the address it involves.
+func (t Transaction) DirectionOf(address Address) Direction {
+	switch {
+	case t.From == address && t.To == address:
+		return DirectionSelf
+	case t.From == address:
+		return DirectionOutgoing
+	default:
+		return DirectionIncoming
+	}
+}
+
+// position is the position of a transaction in the ordering of the queries.
+type position struct {
+	height int
+	hash   string
+}
+
+func positionOf(tx Transaction) position {
+	return position{height: tx.BlockHeight(), hash: strings.ToLower(tx.Hash)}
+}
+
+// before reports whether the position comes before the other one.
+func (p position) before(other position) bool {
+	if p.height != other.height {
+		return p.height < other.height
+	}
+	return p.hash < other.hash
+}
+
+// encodeCursor returns the opaque cursor resuming a query after the position.
+func encodeCursor(p position) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(p.height) + ":" + p.hash))
+}
+
+// decodeCursor decodes the position a cursor resumes after.
+func decodeCursor(cursor string) (position, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return position{}, ErrInvalidCursor
+	}
+	height, hash, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return position{}, ErrInvalidCursor
+	}
+	p := position{hash: hash}
+	if p.height, err = strconv.Atoi(height); err != nil {
+		return position{}, ErrInvalidCursor
+	}
+	return p, nil
+}
+
+// queryScanLimit is the number of transactions a page scans at most. A page of a query whose filters few transactions
+// match may then hold less transactions than its limit, or none, and resumes the scan with its cursor.
+const queryScanLimit = 10000
+
+// queryTransactions selects the page of the transactions a query scans, which are given by their number and an accessor
+// and must be ordered by position. A page with a limit scans up to queryScanLimit transactions.
+func queryTransactions(count int, at func(i int) Transaction, query TransactionQuery) (TransactionPage, error) {
+	lo, hi := 0, count
+	if query.FromBlock > 0 {
+		lo = sort.Search(count, func(i int) bool { return at(i).BlockHeight() >= query.FromBlock })
+	}
+	if query.ToBlock > 0 {
+		hi = sort.Search(count, func(i int) bool { return at(i).BlockHeight() > query.ToBlock })
+	}
+	if query.Cursor != "" {
+		after, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return TransactionPage{}, err
+		}
+		// The transaction of the cursor may have been rolled back since, the page resumes at its position anyway.
+		if query.Descending {
+			hi = min(hi, sort.Search(count, func(i int) bool { return !positionOf(at(i)).before(after) }))
+		} else {
+			lo = max(lo, sort.Search(count, func(i int) bool { return after.before(positionOf(at(i))) }))
+		}
+	}
+
+	page := TransactionPage{Transactions: []Transaction{}}
+	for i := 0; i < hi-lo; i++ {
+		index := lo + i
+		if query.Descending {
+			index = hi - 1 - i
+		}
+		tx := at(index)
+		if query.Matches(tx) {
+			page.Transactions = append(page.Transactions, tx)
+		}
+		// The page ends once full or once it scanned enough transactions, the cursor resumes after the last scanned one.
+		if query.Limit > 0 && (len(page.Transactions) == query.Limit || i+1 == queryScanLimit) {
+			if i < hi-lo-1 {
+				page.NextCursor = encodeCursor(positionOf(tx))
+			}
+			break
+		}
+	}
+	return page, nil
+}
+
+// searchTransaction returns the index of the first transaction not before the position in the ordered transactions.
+func searchTransaction(transactions []Transaction, p position) int {
+	return sort.Search(len(transactions), func(i int) bool { return !positionOf(transactions[i]).before(p) })
+}
+
+// insertTransaction inserts the transaction at its position in the ordered transactions.
+func insertTransaction(transactions []Transaction, tx Transaction) []Transaction {
+	p := positionOf(tx)
+	i := sort.Search(len(transactions), func(i int) bool { return p.before(positionOf(transactions[i])) })
+	transactions = append(transactions, Transaction{})
+	copy(transactions[i+1:], transactions[i:])
+	transactions[i] = tx
+	return transactions
+}
+
+// sortTransactions orders the transactions by position.
+func sortTransactions(transactions []Transaction) {
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return positionOf(transactions[i]).before(positionOf(transactions[j]))
+	})
+}
@@ -0,0 +1,182 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	store "github.com/mo-mohamed/txparser/storage"
+)
+
+// queryStore stores a transaction of the subscribed address per block from 1 to 10, alternatively received and sent,
+// and a self transfer in block 5. Each transaction transfers its block number in wei. Block n is produced 12n seconds
+// after queryEpoch.
+func queryStore(t *testing.T) *store.MemoryStore {
+	t.Helper()
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0x123"})
+	// The blocks are stored out of order, as a backfill does.
+	for _, block := range []int{6, 7, 8, 9, 10, 1, 2, 3, 4, 5} {
+		tx := store.Transaction{Hash: "0xa" + strconv.Itoa(block), From: "0x456", To: "0x123", Value: strconv.Itoa(block), BlockNumber: strconv.Itoa(block)}
+		if block%2 == 0 {
+			tx.From, tx.To = tx.To, tx.From
+		}
+		header := store.BlockHeader{Number: block, Timestamp: queryEpoch.Unix() + int64(12*block)}
+		memoryStore.SaveBlock(store.Block{BlockHeader: header, Transactions: []store.Transaction{tx}})
+	}
+	memoryStore.SaveTransactions([]store.Transaction{{Hash: "0xb5", From: "0x123", To: "0x123", Value: "0", BlockNumber: "5"}})
+	return memoryStore
+}
+
+// queryEpoch is the time the blocks of queryStore are produced from.
+var queryEpoch = time.Date(2024, 3, 13, 13, 0, 0, 0, time.UTC)
+
+// hashes lists the hashes of the transactions of a page.
+func hashes(page store.TransactionPage) []string {
+	var hashes []string
+	for _, tx := range page.Transactions {
+		hashes = append(hashes, tx.Hash)
+	}
+	return hashes
+}
+
+func TestQueryTransactionsPages(t *testing.T) {
+	memoryStore := queryStore(t)
+
+	var all []string
+	query := store.TransactionQuery{Address: "0x123", Limit: 4}
+	for pages := 0; pages < 5; pages++ {
+		page, err := memoryStore.QueryTransactions(query)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		all = append(all, hashes(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	expected := "[0xa1 0xa2 0xa3 0xa4 0xa5 0xb5 0xa6 0xa7 0xa8 0xa9 0xa10]"
+	if got := fmt.Sprint(all); got != expected {
+		t.Errorf("Expected the pages to list every transaction ordered by block, got %s", got)
+	}
+
+	page, _ := memoryStore.QueryTransactions(store.TransactionQuery{Address: "0x123", Descending: true, Limit: 3})
+	if got := fmt.Sprint(hashes(page)); got != "[0xa10 0xa9 0xa8]" || page.NextCursor == "" {
+		t.Errorf("Expected the latest transactions first, got %s", got)
+	}
+	page, _ = memoryStore.QueryTransactions(store.TransactionQuery{Address: "0x123", Descending: true, Limit: 3, Cursor: page.NextCursor})
+	if got := fmt.Sprint(hashes(page)); got != "[0xa7 0xa6 0xb5]" {
+		t.Errorf("Expected the next page of the latest transactions, got %s", got)
+	}
+}
+
+func TestQueryTransactionsFilters(t *testing.T) {
+	memoryStore := queryStore(t)
+	for name, test := range map[string]struct {
+		query    store.TransactionQuery
+		expected string
+	}{
+		"block range": {store.TransactionQuery{FromBlock: 4, ToBlock: 6}, "[0xa4 0xa5 0xb5 0xa6]"},
+		"incoming":    {store.TransactionQuery{Direction: store.DirectionIncoming}, "[0xa1 0xa3 0xa5 0xa7 0xa9]"},
+		"outgoing":    {store.TransactionQuery{Direction: store.DirectionOutgoing, ToBlock: 6}, "[0xa2 0xa4 0xa6]"},
+		"self":        {store.TransactionQuery{Direction: store.DirectionSelf}, "[0xb5]"},
+		"min value":   {store.TransactionQuery{MinValue: store.NewQuantity(9)}, "[0xa9 0xa10]"},
+	} {
+		test.query.Address = "0x123"
+		page, err := memoryStore.QueryTransactions(test.query)
+		if got := fmt.Sprint(hashes(page)); err != nil || got != test.expected {
+			t.Errorf("Expected %s to select %s, got %s %v", name, test.expected, got, err)
+		}
+	}
+}
+
+func TestQueryTransactionsTimeRange(t *testing.T) {
+	memoryStore := queryStore(t)
+	for name, test := range map[string]struct {
+		query    store.TransactionQuery
+		expected string
+	}{
+		// Blocks 4 to 6 are produced 48 to 72 seconds after the epoch.
+		"time range":   {store.TransactionQuery{FromTime: queryEpoch.Add(40 * time.Second), ToTime: queryEpoch.Add(80 * time.Second)}, "[0xa4 0xa5 0xb5 0xa6]"},
+		"exact times":  {store.TransactionQuery{FromTime: queryEpoch.Add(48 * time.Second), ToTime: queryEpoch.Add(72 * time.Second)}, "[0xa4 0xa5 0xb5 0xa6]"},
+		"with blocks":  {store.TransactionQuery{FromTime: queryEpoch.Add(40 * time.Second), ToBlock: 5}, "[0xa4 0xa5 0xb5]"},
+		"after last":   {store.TransactionQuery{FromTime: queryEpoch.Add(time.Hour)}, "[]"},
+		"before first": {store.TransactionQuery{ToTime: queryEpoch}, "[]"},
+	} {
+		test.query.Address = "0x123"
+		page, err := memoryStore.QueryTransactions(test.query)
+		if got := fmt.Sprint(hashes(page)); err != nil || got != test.expected {
+			t.Errorf("Expected %s to select %s, got %s %v", name, test.expected, got, err)
+		}
+	}
+
+	if number, ok := memoryStore.BlockAt(queryEpoch.Add(70 * time.Second)); !ok || number != 5 {
+		t.Errorf("Expected block 5 to be the latest produced 70 seconds after the epoch, got %d %v", number, ok)
+	}
+	memoryStore.RemoveBlock(10)
+	if _, ok := memoryStore.BlockTime(10); ok {
+		t.Error("Expected the time of a removed block to be forgotten")
+	}
+	if blockTime, ok := memoryStore.BlockTime(9); !ok || !blockTime.Equal(queryEpoch.Add(108*time.Second)) {
+		t.Errorf("Expected the time of block 9, got %v %v", blockTime, ok)
+	}
+}
+
+func TestQueryTransactionsCursor(t *testing.T) {
+	memoryStore := queryStore(t)
+	if _, err := memoryStore.QueryTransactions(store.TransactionQuery{Address: "0x123", Cursor: "not a cursor"}); !errors.Is(err, store.ErrInvalidCursor) {
+		t.Errorf("Expected an invalid cursor to be rejected, got %v", err)
+	}
+
+	// The page resumes at the position of the cursor even when its transaction got rolled back.
+	page, _ := memoryStore.QueryTransactions(store.TransactionQuery{Address: "0x123", Limit: 3})
+	memoryStore.RemoveBlock(3)
+	page, _ = memoryStore.QueryTransactions(store.TransactionQuery{Address: "0x123", Limit: 2, Cursor: page.NextCursor})
+	if got := fmt.Sprint(hashes(page)); got != "[0xa4 0xa5]" {
+		t.Errorf("Expected the page to resume after block 3, got %s", got)
+	}
+}
+
+func TestQueryTransactionsDirectionIndex(t *testing.T) {
+	memoryStore := queryStore(t)
+	memoryStore.RemoveBlock(4)
+	memoryStore.SaveBlock(store.Block{BlockHeader: store.BlockHeader{Number: 4}, Transactions: []store.Transaction{
+		{Hash: "0xc4", From: "0x456", To: "0x123", Value: "4", BlockNumber: "4"},
+	}})
+	for direction, expected := range map[store.Direction]string{
+		store.DirectionIncoming: "[0xa1 0xa3 0xc4 0xa5 0xa7 0xa9]",
+		store.DirectionOutgoing: "[0xa2 0xa6 0xa8 0xa10]",
+	} {
+		page, err := memoryStore.QueryTransactions(store.TransactionQuery{Address: "0x123", Direction: direction})
+		if got := fmt.Sprint(hashes(page)); err != nil || got != expected {
+			t.Errorf("Expected %s to select %s once block 4 was replaced, got %s %v", direction, expected, got, err)
+		}
+	}
+}
+
+func TestQueryTransactionsScanLimit(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.Subscribe(store.Subscription{Address: "0x123"})
+	var transactions []store.Transaction
+	for block := 1; block <= 10005; block++ {
+		transactions = append(transactions, store.Transaction{Hash: "0xa" + strconv.Itoa(block), From: "0x456", To: "0x123",
+			Value: "1", BlockNumber: strconv.Itoa(block)})
+	}
+	transactions[10004].Value = "100"
+	memoryStore.SaveTransactions(transactions)
+
+	// The first page scans as many transactions as it can without finding a match, the next one resumes the scan.
+	query := store.TransactionQuery{Address: "0x123", MinValue: store.NewQuantity(100), Limit: 10}
+	page, err := memoryStore.QueryTransactions(query)
+	if err != nil || len(page.Transactions) != 0 || page.NextCursor == "" {
+		t.Fatalf("Expected an empty page resuming the scan, got %s %q %v", hashes(page), page.NextCursor, err)
+	}
+	query.Cursor = page.NextCursor
+	page, _ = memoryStore.QueryTransactions(query)
+	if got := fmt.Sprint(hashes(page)); got != "[0xa10005]" || page.NextCursor != "" {
+		t.Errorf("Expected the matching transaction on the last page, got %s %q", got, page.NextCursor)
+	}
+}
@@ -0,0 +1,327 @@
+/*
+Package websocket implements the subset of the WebSocket protocol (RFC 6455) needed to exchange JSON-RPC messages:
+the opening handshake, text messages, fragmentation, ping, pong and close frames.
+*/
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Frame opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+const (
+	// acceptGUID is appended to the handshake key to compute the accept header.
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// maxMessageSize bounds the size of a received message.
+	maxMessageSize = 32 << 20
+	// closeTimeout bounds the time spent sending the close frame.
+	closeTimeout = time.Second
+)
+
+var (
+	// ErrClosed is returned when reading a connection closed by the peer.
+	ErrClosed = errors.New("websocket: connection closed")
+	// ErrMessageTooLarge is returned for a received message larger than the size limit.
+	ErrMessageTooLarge = errors.New("websocket: message too large")
+	// ErrProtocol is returned when the peer violates the protocol.
+	ErrProtocol = errors.New("websocket: protocol error")
+)
+
+// Conn is a WebSocket connection.
+type Conn struct {
+	// conn is the underlying network connection.
+	conn net.Conn
+	// reader buffers the frames read from the connection.
+	reader *bufio.Reader
+	// client tells whether the connection was dialed, clients mask the frames they send.
+	client bool
+	// writeMu serializes the frames written to the connection.
+	writeMu sync.Mutex
+}
+
+// Dial opens a WebSocket connection to a "ws" or "wss" url.
+func Dial(ctx context.Context, rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var port string
+	switch u.Scheme {
+	case "ws":
+		port = "80"
+	case "wss":
+		port = "443"
+	default:
+		return nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "wss" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	c, err := handshake(ctx, conn, u)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake sends the opening handshake over the connection and verifies the server accepted it.
+func handshake(ctx context.Context, conn net.Conn, u *url.URL) (*Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	request := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+		Host: u.Host,
+	}
+	if request.URL.Path == "" {
+		request.URL.Path = "/"
+	}
+	if err := request.Write(conn); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("websocket: handshake failed with status %d", response.StatusCode)
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, fmt.Errorf("%w: invalid Sec-WebSocket-Accept header", ErrProtocol)
+	}
+	return &Conn{conn: conn, reader: reader, client: true}, nil
+}
+
+// Upgrade answers the opening handshake of a client and takes over the connection of the request.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "Expected WebSocket handshake", http.StatusBadRequest)
+		return nil, fmt.Errorf("%w: not a WebSocket handshake", ErrProtocol)
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: connection cannot be hijacked")
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, reader: buffered.Reader}, nil
+}
+
+// acceptKey computes the accept header matching a handshake key.
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// ReadMessage reads the next text or binary message, answering the pings received meanwhile.
+// It returns ErrClosed once the peer closed the connection.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	started := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+		case opPong:
+		case opClose:
+			c.writeFrame(opClose, nil)
+			return nil, ErrClosed
+		case opText, opBinary, opContinuation:
+			if (opcode == opContinuation) != started {
+				return nil, fmt.Errorf("%w: unexpected frame opcode %d", ErrProtocol, opcode)
+			}
+			started = true
+			if len(message)+len(payload) > maxMessageSize {
+				return nil, ErrMessageTooLarge
+			}
+			message = append(message, payload...)
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown frame opcode %d", ErrProtocol, opcode)
+		}
+	}
+}
+
+// WriteMessage sends a text message.
+func (c *Conn) WriteMessage(message []byte) error {
+	return c.writeFrame(opText, message)
+}
+
+// SetReadDeadline sets the time reading the next message times out.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// Close sends a close frame and closes the connection.
+func (c *Conn) Close() error {
+	c.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+	c.writeFrame(opClose, nil)
+	return c.conn.Close()
+}
+
+// readFrame reads a single frame, unmasking its payload.
+func (c *Conn) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, ErrMessageTooLarge
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unfragmented frame, masking its payload when sent by a client.
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range frame[start:] {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(frame)
+	return err
+}
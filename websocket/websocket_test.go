@@ -0,0 +1,76 @@
+package websocket_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mo-mohamed/txparser/websocket"
+)
+
+// echoServer starts a WebSocket server sending back every message it receives, and closing the connection on "close".
+func echoServer(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil || string(message) == "close" {
+				return
+			}
+			conn.WriteMessage(message)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestEcho(t *testing.T) {
+	conn, err := websocket.Dial(context.Background(), echoServer(t))
+	if err != nil {
+		t.Fatalf("Expected connection to be opened, got %v", err)
+	}
+	defer conn.Close()
+
+	// The sizes cover the three payload length encodings.
+	for _, size := range []int{10, 300, 70000} {
+		message := bytes.Repeat([]byte("a"), size)
+		if err := conn.WriteMessage(message); err != nil {
+			t.Fatalf("Expected message to be sent, got %v", err)
+		}
+		echoed, err := conn.ReadMessage()
+		if err != nil || !bytes.Equal(echoed, message) {
+			t.Errorf("Expected message of %d bytes to be echoed, got %d bytes, %v", size, len(echoed), err)
+		}
+	}
+}
+
+func TestClosedByPeer(t *testing.T) {
+	conn, err := websocket.Dial(context.Background(), echoServer(t))
+	if err != nil {
+		t.Fatalf("Expected connection to be opened, got %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteMessage([]byte("close"))
+	if _, err := conn.ReadMessage(); !errors.Is(err, websocket.ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestDialRejected(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http")); err == nil {
+		t.Error("Expected handshake to fail")
+	}
+}